@@ -2,8 +2,14 @@ package api
 
 import (
 	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-jsonrpc/auth"
+	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/crypto"
 
 	"github.com/filecoin-project/lotus/chain/types"
@@ -23,6 +29,10 @@ const (
 	// Signing a deal proposal. signing raw cbor proposal bytes (MsgMeta.Extra is empty)
 	MTDealProposal = "dealproposal"
 
+	// Signing the sha256 digest of a file, see wallet.FileDigestBytes.
+	// MsgMeta.Extra contains the digest
+	MTFileDigest = "filedigest"
+
 	// TODO: Deals, Vouchers, VRF
 )
 
@@ -32,6 +42,11 @@ type MsgMeta struct {
 	// Additional data related to what is signed. Should be verifiable with the
 	// signed bytes (e.g. CID(Extra).Bytes() == toSign)
 	Extra []byte
+
+	// Free-form explanation of why the signature is requested (e.g. "Payroll
+	// batch #42"). Informational only, shown to operators and recorded with
+	// the signature.
+	Reason string `json:",omitempty"`
 }
 
 type WalletAPI interface {
@@ -45,3 +60,598 @@ type WalletAPI interface {
 	WalletImport(context.Context, *types.KeyInfo) (address.Address, error)
 	WalletDelete(context.Context, address.Address) error
 }
+
+// WalletDaemonAPI is the API served by the standalone lotus-wallet daemon. On
+// top of WalletAPI it exposes methods used to operate the daemon itself.
+type WalletDaemonAPI interface {
+	WalletAPI
+
+	// AuthVerify returns the permissions granted to a token.
+	AuthVerify(ctx context.Context, token string) ([]auth.Permission, error)
+	// AuthNew creates a token granting the given permissions.
+	AuthNew(ctx context.Context, perms []auth.Permission) ([]byte, error)
+	// AuthNewScoped creates a token granting the given permissions, further
+	// restricted by scopes (see TokenScope*).
+	AuthNewScoped(ctx context.Context, perms []auth.Permission, scopes []string) ([]byte, error)
+	// AuthList lists tokens issued by the daemon, including revoked ones.
+	AuthList(ctx context.Context) ([]TokenInfo, error)
+	// AuthRevoke revokes the token with the given ID.
+	AuthRevoke(ctx context.Context, id string) error
+
+	// WalletListPage lists addresses matching the filter, sorted by address.
+	WalletListPage(ctx context.Context, f WalletListFilter) (*WalletListPage, error)
+	// WalletBackends lists which backends hold the key of each address,
+	// sorted by address. Keys held by several backends are listed once per
+	// backend.
+	WalletBackends(ctx context.Context) ([]AddressBackend, error)
+	// WalletListOwned lists addresses like WalletList, along with the backend
+	// holding each key and a recent signature proving the wallet controls it.
+	// Proofs are produced in the background; addresses without one yet, and
+	// ledger keys unless enabled, are listed with a ProofError.
+	WalletListOwned(ctx context.Context) ([]OwnedAddress, error)
+	// WalletLedgerShow displays a ledger address on the device screen, for
+	// the user to compare with the address shown by the host.
+	WalletLedgerShow(ctx context.Context, addr address.Address) error
+
+	// WalletHDInit sets the seed hd accounts are derived from, which can only
+	// be set once.
+	WalletHDInit(ctx context.Context, mnemonic, passphrase string) error
+	// WalletNewHD derives and imports the secp256k1 key at the given BIP32
+	// path. An empty path derives the next account on m/44'/461'/0'/0/<index>.
+	WalletNewHD(ctx context.Context, path string) (address.Address, error)
+	// WalletHDList lists accounts derived from the hd seed.
+	WalletHDList(ctx context.Context) ([]HDAccount, error)
+	// WalletHDLabel sets the label of a derived account.
+	WalletHDLabel(ctx context.Context, addr address.Address, label string) error
+
+	// WalletDevNew creates a development key, marked by membership of the
+	// DevKeyGroup key group. It fails on mainnet.
+	WalletDevNew(ctx context.Context, typ types.KeyType) (address.Address, error)
+
+	// WalletServiceKeyNew generates a named key of a non-Filecoin key type,
+	// like an ed25519 service identity. Service keys have no address and
+	// can't be used by WalletSign. Only available when the daemon was started
+	// with service keys enabled.
+	WalletServiceKeyNew(ctx context.Context, name string, typ types.KeyType) (*ServiceKey, error)
+	// WalletServiceKeyList lists service keys.
+	WalletServiceKeyList(ctx context.Context) ([]ServiceKey, error)
+	// WalletServiceKeySign signs raw bytes with a service key.
+	WalletServiceKeySign(ctx context.Context, name string, data []byte) ([]byte, error)
+	// WalletServiceKeyDelete removes a service key.
+	WalletServiceKeyDelete(ctx context.Context, name string) error
+
+	// LogList lists the logging subsystems of the daemon.
+	LogList(ctx context.Context) ([]string, error)
+	// LogSetLevel sets the log level of a logging subsystem.
+	LogSetLevel(ctx context.Context, subsystem, level string) error
+
+	// Version returns the daemon version and the api version it advertises,
+	// like Common.Version of lotus nodes, for clients checking compatibility.
+	Version(ctx context.Context) (Version, error)
+	// Session returns a random UUID identifying this run of the daemon; it
+	// changes when the daemon restarts.
+	Session(ctx context.Context) (uuid.UUID, error)
+
+	// WalletHasMany is a batched WalletHas, answering for each of the given
+	// addresses in order.
+	WalletHasMany(ctx context.Context, addrs []address.Address) ([]bool, error)
+
+	// WalletBLSAggregateNew starts collecting partial BLS signatures over
+	// toSign from the given set of BLS signers.
+	WalletBLSAggregateNew(ctx context.Context, toSign []byte, signers []address.Address) (*BLSAggregate, error)
+	// WalletBLSAggregateSubmit records a partial signature from one of the
+	// signers. Once all shares have arrived the returned aggregate contains
+	// the combined signature.
+	WalletBLSAggregateSubmit(ctx context.Context, toSign []byte, signer address.Address, sig *crypto.Signature) (*BLSAggregate, error)
+	// WalletBLSAggregateGet returns the current state of an aggregation.
+	WalletBLSAggregateGet(ctx context.Context, toSign []byte) (*BLSAggregate, error)
+	// WalletBLSAggregateList lists all tracked aggregations.
+	WalletBLSAggregateList(ctx context.Context) ([]BLSAggregate, error)
+
+	// WalletInstancePubkey returns the public key of this wallet instance.
+	// Keys sealed to it can be passed to WalletImport, so that they never
+	// exist in plaintext outside of the wallet.
+	WalletInstancePubkey(ctx context.Context) ([]byte, error)
+
+	// WalletFindSignature looks up a signature produced by this wallet over
+	// the chain message with the given CID.
+	WalletFindSignature(ctx context.Context, c cid.Cid) (*SignatureRecord, error)
+	// WalletMsgSummary returns the decoded form of a payload this wallet was
+	// asked to sign, by the CID of the payload, or the raw sha256 CID of the
+	// signed bytes if they aren't a CID. Summaries are kept for as long as
+	// the daemon's summary retention settings allow.
+	WalletMsgSummary(ctx context.Context, c cid.Cid) (*MsgSummary, error)
+	// WalletJournalPrune removes signature records older than maxAge, then the
+	// oldest records until at most maxEntries remain. Zero disables a limit.
+	WalletJournalPrune(ctx context.Context, maxAge time.Duration, maxEntries int) (int, error)
+
+	// WalletPolicySet sets the signing policy of an address. A nil policy
+	// removes it, leaving the address unrestricted.
+	WalletPolicySet(ctx context.Context, addr address.Address, p *SigningPolicy) error
+	// WalletPolicyGet returns the signing policy of an address, or nil if it
+	// is unrestricted.
+	WalletPolicyGet(ctx context.Context, addr address.Address) (*SigningPolicy, error)
+	// WalletPolicyList lists all addresses with a signing policy.
+	WalletPolicyList(ctx context.Context) ([]AddressPolicy, error)
+
+	// WalletSpendGet returns the value signed by an address within the
+	// rolling window DailyLimit policies apply to.
+	WalletSpendGet(ctx context.Context, addr address.Address) (*SpendStatus, error)
+	// WalletSpendReset clears the spend counter of an address.
+	WalletSpendReset(ctx context.Context, addr address.Address) error
+
+	// WalletGroupSet creates or replaces a key group.
+	WalletGroupSet(ctx context.Context, g KeyGroup) error
+	// WalletGroupGet returns a key group by name.
+	WalletGroupGet(ctx context.Context, name string) (*KeyGroup, error)
+	// WalletGroupRemove removes a key group. Member keys are not affected.
+	WalletGroupRemove(ctx context.Context, name string) error
+	// WalletGroupFreeze freezes or unfreezes signing with all group members.
+	WalletGroupFreeze(ctx context.Context, name string, frozen bool) error
+	// WalletGroupList lists all key groups.
+	WalletGroupList(ctx context.Context) ([]KeyGroup, error)
+
+	// WalletApprovalList lists sign requests waiting for approval. Only used
+	// when the daemon runs with manual or threshold approvals.
+	WalletApprovalList(ctx context.Context) ([]PendingSign, error)
+	// WalletApprovalSubscribe streams sign requests waiting for approval:
+	// first the currently pending ones, then new ones as they arrive, so
+	// approver tools don't have to poll WalletApprovalList.
+	WalletApprovalSubscribe(ctx context.Context) (<-chan PendingSign, error)
+	// WalletApprovalDecide approves or rejects a pending sign request. When
+	// approvers are registered, it must be called with an approver token.
+	WalletApprovalDecide(ctx context.Context, id uint64, approve bool) error
+	// WalletApproverTokenNew creates a token identifying a registered
+	// approver, allowing it to list and decide pending sign requests.
+	WalletApproverTokenNew(ctx context.Context, name string) ([]byte, error)
+
+	// WalletAuditQuery returns audit log entries of sign, export and delete
+	// requests matching the filter, oldest first.
+	WalletAuditQuery(ctx context.Context, f AuditFilter) ([]AuditEntry, error)
+	// WalletSecurityEvents returns security relevant state changes, like keys
+	// being added or removed, policy changes, freezes and token issuance,
+	// matching the filter, oldest first.
+	WalletSecurityEvents(ctx context.Context, f SecurityEventFilter) ([]SecurityEvent, error)
+
+	// WalletCustodyReport describes the key custody controls currently in
+	// effect, derived from the daemon configuration and state.
+	WalletCustodyReport(ctx context.Context) (*CustodyReport, error)
+
+	// WalletStatus returns the state of the daemon and its signing backends,
+	// for operational health checks.
+	WalletStatus(ctx context.Context) (*WalletDaemonStatus, error)
+	// WalletBackendMaintenance puts a backend into maintenance mode, or takes
+	// it out of it. While in maintenance, requests for its addresses fail over
+	// to other backends holding the key, or fail right away.
+	WalletBackendMaintenance(ctx context.Context, backend string, on bool, reason string) error
+	// WalletRoutingGet returns the configuration routing requests to backends.
+	WalletRoutingGet(ctx context.Context) (*BackendRouting, error)
+	// WalletRoutingSet replaces the configuration routing requests to
+	// backends. It is kept across restarts.
+	WalletRoutingSet(ctx context.Context, r *BackendRouting) error
+	// WalletCidEncodings parses a CID in any multibase encoding, or bare hex,
+	// and returns its base32 and base16 forms, so that CIDs copied from
+	// explorers can be compared with the ones shown by the wallet.
+	WalletCidEncodings(ctx context.Context, c string) (*CidEncodings, error)
+	// WalletBackendAdd attaches a remote or ledger backend to the running
+	// wallet.
+	WalletBackendAdd(ctx context.Context, cfg BackendConfig) error
+	// WalletBackendRemove detaches a backend. Requests already using it are
+	// finished first.
+	WalletBackendRemove(ctx context.Context, backend string) error
+	// WalletBackendList lists attached backends.
+	WalletBackendList(ctx context.Context) ([]BackendInfo, error)
+	// WalletRemoteStatus returns the connection state of remote wallets,
+	// shards and their verifiers.
+	WalletRemoteStatus(ctx context.Context) ([]RemoteStatus, error)
+}
+
+// SigningPolicy constrains what an address may sign. Empty fields don't
+// restrict anything.
+type SigningPolicy struct {
+	// MsgMeta types which may be signed. Types the wallet can't decode are
+	// only signed when listed here, and never when the policy constrains
+	// chain messages.
+	AllowedTypes []MsgType
+	// Allowed recipients of chain messages
+	AllowedTo []address.Address
+	// Maximum value of a single chain message
+	MaxValue *types.BigInt
+	// Maximum gas fee cap of a single chain message
+	MaxGasFeeCap *types.BigInt
+	// Maximum total value of chain messages signed in a rolling 24h window
+	DailyLimit *types.BigInt
+	// Expected chain message volume per WindowPoSt deadline
+	DeadlineBudget *DeadlineBudget `json:",omitempty"`
+}
+
+// DeadlineBudget limits the chain messages signed per WindowPoSt deadline.
+// The budget refills continuously like a token bucket, so at most a deadline
+// worth of messages can be signed in a burst.
+type DeadlineBudget struct {
+	// Maximum number of messages, 0 for no limit
+	Messages int
+	// Maximum total gas limit of messages, 0 for no limit
+	Gas int64
+	// Only log and count requests exceeding the budget instead of denying
+	// them
+	AlertOnly bool
+}
+
+// KeyGroup is a named set of addresses managed together. A group policy
+// applies to every member in addition to the member's own policy, and no
+// member of a frozen group can sign.
+type KeyGroup struct {
+	Name    string
+	Members []address.Address
+	Frozen  bool
+	Policy  *SigningPolicy
+}
+
+// PendingSign is a sign request waiting for operator approval
+type PendingSign struct {
+	ID     uint64
+	Signer address.Address
+	Type   MsgType
+	Reason string
+	Caller string
+
+	// CID of the signed object, if the signed bytes are a CID
+	Cid *cid.Cid
+	// Decoded message, for chain messages
+	Message *types.Message
+	// Payload summary from the handler of the message type, for other types
+	Summary string `json:",omitempty"`
+
+	Received time.Time
+
+	// Approvers who approved the request so far, and how many approvals are
+	// needed
+	Approvals []string
+	Required  int
+}
+
+type SpendStatus struct {
+	Address address.Address
+	Window  time.Duration
+
+	// Total value of messages signed within the window
+	Spent types.BigInt
+	Count int
+
+	// Lowest DailyLimit from the address and key group policies, if any
+	Limit *types.BigInt
+}
+
+type AddressPolicy struct {
+	Address address.Address
+	Policy  SigningPolicy
+}
+
+type SignatureRecord struct {
+	Cid       cid.Cid
+	Signer    address.Address
+	Signature crypto.Signature
+	Time      time.Time
+
+	// Remote address of the api client which requested the signature
+	Caller string
+	// MsgMeta.Reason given by the caller
+	Reason string `json:",omitempty"`
+}
+
+// WalletListFilter selects addresses listed by WalletListPage. Zero values
+// don't restrict anything.
+type WalletListFilter struct {
+	// "local", "ledger" or "remote"
+	Backend  string
+	Protocol *address.Protocol
+
+	// Skip this many matching addresses, then return at most Limit
+	Offset int
+	Limit  int
+}
+
+type WalletListPage struct {
+	Addresses []address.Address
+	// Number of addresses matching the filter, ignoring Offset and Limit
+	Total int
+}
+
+type AddressBackend struct {
+	Address address.Address
+	Backend string
+	// Address of a remote wallet, shard or ledger emulator holding the key
+	Endpoint string `json:",omitempty"`
+}
+
+// OwnedAddress is an address listed with proof of possession of its key
+type OwnedAddress struct {
+	Address address.Address
+	// Backend holding the key: "local", "ledger" or "remote"
+	Backend string
+
+	// Signature over wallet.OwnershipProofBytes(Address, ProofTime). Not set
+	// when the backend can't produce one, ProofError says why.
+	Proof      *crypto.Signature `json:",omitempty"`
+	ProofTime  time.Time
+	ProofError string `json:",omitempty"`
+}
+
+// CustodyReport describes the key custody controls in effect on a wallet
+// daemon, for auditors
+type CustodyReport struct {
+	Generated      time.Time
+	Version        string
+	InstancePubkey []byte
+
+	// Where the local backend keeps keys: "repo" or "vault"
+	Keystore string
+	// Whether the api is served over TLS, requires client certificates,
+	// requires tokens, and requires signed requests
+	TLS            bool
+	TLSClientAuth  bool
+	AuthRequired   bool
+	RequestSigning bool
+	ActiveTokens   int
+	RevokedTokens  int
+	ApproverTokens int
+	Approvals      *CustodyApprovals `json:",omitempty"`
+	PolicyCount    int
+	FrozenGroups   []string
+	Keys           []CustodyKey
+}
+
+// CustodyApprovals are the sign request approval settings of a daemon
+type CustodyApprovals struct {
+	// Every sign request needs approval
+	All bool
+	// Chain messages with a value above this need approval
+	Threshold *types.BigInt `json:",omitempty"`
+	Approvers []string
+	Required  int
+}
+
+// CustodyKey describes the controls applying to one key
+type CustodyKey struct {
+	Address address.Address
+	// Backend holding the key, like "local", "ledger", "remote" or a shard
+	Backend string
+	// The key has its own signing policy
+	Policy bool
+	// Key groups the key is a member of, and whether any of them is frozen
+	Groups []string `json:",omitempty"`
+	Frozen bool
+}
+
+// ServiceKey is a named key of a non-Filecoin key type
+type ServiceKey struct {
+	Name      string
+	Type      types.KeyType
+	PublicKey []byte
+}
+
+// HDAccount is a key derived from the hd seed of the wallet
+type HDAccount struct {
+	Address address.Address
+	Path    string
+	Label   string
+}
+
+// DevKeyGroup is the key group holding keys created with WalletDevNew
+const DevKeyGroup = "dev"
+
+// TokenScopeHasOnly restricts a token from listing wallet addresses, it can
+// only check for specific ones with WalletHas
+const TokenScopeHasOnly = "has-only"
+
+// TokenInfo describes an api token issued by the wallet daemon
+type TokenInfo struct {
+	ID     string
+	Perms  []auth.Permission
+	Scopes []string `json:",omitempty"`
+	// Set on approver tokens
+	Approver string `json:",omitempty"`
+
+	// Remote address of the api client which created the token
+	CreatedBy string
+	Created   time.Time
+	// Approximate time the token was last used
+	LastUsed time.Time
+
+	Revoked *time.Time `json:",omitempty"`
+}
+
+// AuditEntry records a sign, export or delete request made to the wallet
+type AuditEntry struct {
+	Time time.Time
+	// "sign", "export" or "delete"
+	Op      string
+	Caller  string
+	Address address.Address
+
+	// Sign requests only
+	Type   MsgType  `json:",omitempty"`
+	Reason string   `json:",omitempty"`
+	Cid    *cid.Cid `json:",omitempty"`
+
+	// Decoded chain message fields
+	To        *address.Address `json:",omitempty"`
+	Method    abi.MethodNum    `json:",omitempty"`
+	Value     *types.BigInt    `json:",omitempty"`
+	GasFeeCap *types.BigInt    `json:",omitempty"`
+
+	// Payload summary from the handler of the message type, for types other
+	// than chain messages
+	Summary string `json:",omitempty"`
+
+	// Empty if the request succeeded
+	Error string `json:",omitempty"`
+}
+
+// MsgSummary is the decoded form of a signed payload
+type MsgSummary struct {
+	Type MsgType
+	// CID of the signed object, if the signed bytes are a CID
+	Cid *cid.Cid `json:",omitempty"`
+	// Decoded message, for chain messages
+	Message *types.Message `json:",omitempty"`
+	// Payload summary from the handler of the message type, for other types
+	Summary string `json:",omitempty"`
+}
+
+// AuditFilter selects audit entries. Zero values don't restrict anything.
+type AuditFilter struct {
+	Address *address.Address
+
+	// Entries recorded at or after Since, and before Until
+	Since time.Time
+	Until time.Time
+
+	// Return at most this many of the most recent matching entries
+	Limit int
+}
+
+// SecurityEvent is a security relevant change of wallet daemon state
+type SecurityEvent struct {
+	Time time.Time
+	// See wallet.SecEv*
+	Kind   string
+	Caller string
+
+	// The changed object: an address, key group name or token ID
+	Subject string
+	Detail  string `json:",omitempty"`
+}
+
+// SecurityEventFilter selects security events. Zero values don't restrict
+// anything.
+type SecurityEventFilter struct {
+	Kinds []string
+
+	// Events recorded at or after Since, and before Until
+	Since time.Time
+	Until time.Time
+
+	// Return at most this many of the most recent matching events
+	Limit int
+}
+
+type WalletDaemonStatus struct {
+	// Version of the daemon, like build.UserVersion
+	Version string
+	Started time.Time
+	Uptime  time.Duration
+
+	// Api clients connected over websocket
+	WSClients int
+	// Sign requests waiting for approval, 0 unless approvals are enabled
+	PendingApprovals int
+	// Key groups with signing frozen, signing with their members is locked
+	// until they are unfrozen
+	FrozenGroups []string
+
+	Backends []BackendStatus
+}
+
+type BackendStatus struct {
+	Name string
+
+	// "ok", "degraded" or "maintenance". Degraded backends are skipped until
+	// a background probe succeeds, backends in maintenance until maintenance
+	// is turned off.
+	State string
+	// Set by the operator when starting maintenance
+	MaintenanceReason string `json:",omitempty"`
+
+	// Consecutive failed requests
+	Failures  int
+	LastError string
+
+	// When a degraded backend will be probed next
+	NextProbe time.Time
+
+	// Addresses recorded for the backend in the backend index, as the
+	// backend listed or served them. This is not a live count, keys added or
+	// removed outside of this daemon show up once the backend is listed.
+	IndexedKeys int
+}
+
+// BackendRouting configures which backends handle requests. Empty fields
+// keep the default routing.
+type BackendRouting struct {
+	// Order addresses are looked up on backends in. Backends not listed are
+	// tried after the listed ones, in the default order.
+	Priority []string
+	// Backend creating and importing keys, per key type
+	NewKeys map[types.KeyType]string
+	// Addresses which are only looked up on, and imported into, one backend
+	Pins []AddressPin
+}
+
+type AddressPin struct {
+	Address address.Address
+	Backend string
+}
+
+type BackendConfig struct {
+	// "remote" or "ledger"
+	Type string
+
+	// Api info (token:multiaddr) of a remote wallet, and of independent
+	// upstreams which must confirm its answers
+	RemoteInfo   string   `json:",omitempty"`
+	RemoteVerify []string `json:",omitempty"`
+
+	// Address of a speculos ledger emulator to use instead of a device
+	Speculos string `json:",omitempty"`
+}
+
+type BackendInfo struct {
+	Name string
+	// Address of a remote wallet or ledger emulator
+	Endpoint string `json:",omitempty"`
+}
+
+type RemoteStatus struct {
+	// Backend or shard the connection belongs to
+	Backend string
+	// "remote" or "verifier"
+	Role string
+	Addr string
+
+	// "connected", "not-connected" (lazy backends before first use) or
+	// "unreachable"
+	State     string
+	LastError string `json:",omitempty"`
+	// Last time the remote answered
+	LastSeen time.Time
+	// Whether requests are currently sent to this remote, when failing over
+	// between several
+	Active bool
+}
+
+type CidEncodings struct {
+	Cid     cid.Cid
+	Version uint64
+	Codec   string
+
+	// CIDv1 encodings; for v0 CIDs these are of the equivalent v1 CID
+	Base32 string
+	Base16 string
+}
+
+type BLSAggregate struct {
+	ToSign  []byte
+	Signers []address.Address
+
+	// Address derived from the aggregated public keys of all signers. The
+	// final signature verifies against this address.
+	Address address.Address
+
+	// Signers which already submitted their share
+	Received []address.Address
+
+	// Aggregated signature, set once shares from all signers have arrived
+	Signature *crypto.Signature
+}
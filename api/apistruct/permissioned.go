@@ -42,3 +42,10 @@ func PermissionedWalletAPI(a api.WalletAPI) api.WalletAPI {
 	auth.PermissionedProxy(AllPermissions, DefaultPerms, a, &out.Internal)
 	return &out
 }
+
+func PermissionedWalletDaemonAPI(a api.WalletDaemonAPI) api.WalletDaemonAPI {
+	var out WalletDaemonStruct
+	auth.PermissionedProxy(AllPermissions, DefaultPerms, a, &out.Internal)
+	auth.PermissionedProxy(AllPermissions, DefaultPerms, a, &out.WalletStruct.Internal)
+	return &out
+}
@@ -458,6 +458,86 @@ type WalletStruct struct {
 	}
 }
 
+type WalletDaemonStruct struct {
+	WalletStruct
+
+	Internal struct {
+		AuthVerify    func(ctx context.Context, token string) ([]auth.Permission, error)                  `perm:"read"`
+		AuthNew       func(ctx context.Context, perms []auth.Permission) ([]byte, error)                  `perm:"admin"`
+		AuthNewScoped func(ctx context.Context, perms []auth.Permission, scopes []string) ([]byte, error) `perm:"admin"`
+		AuthList      func(ctx context.Context) ([]api.TokenInfo, error)                                  `perm:"admin"`
+		AuthRevoke    func(ctx context.Context, id string) error                                          `perm:"admin"`
+
+		LogList     func(context.Context) ([]string, error)     `perm:"admin"`
+		LogSetLevel func(context.Context, string, string) error `perm:"admin"`
+
+		Version func(context.Context) (api.Version, error) `perm:"read"`
+		Session func(context.Context) (uuid.UUID, error)   `perm:"read"`
+
+		WalletListPage  func(context.Context, api.WalletListFilter) (*api.WalletListPage, error) `perm:"write"`
+		WalletBackends  func(context.Context) ([]api.AddressBackend, error)                      `perm:"write"`
+		WalletListOwned func(context.Context) ([]api.OwnedAddress, error)                        `perm:"write"`
+		WalletHasMany   func(context.Context, []address.Address) ([]bool, error)                 `perm:"write"`
+
+		WalletLedgerShow func(context.Context, address.Address) error `perm:"write"`
+
+		WalletHDInit  func(context.Context, string, string) error            `perm:"admin"`
+		WalletNewHD   func(context.Context, string) (address.Address, error) `perm:"write"`
+		WalletHDList  func(context.Context) ([]api.HDAccount, error)         `perm:"write"`
+		WalletHDLabel func(context.Context, address.Address, string) error   `perm:"write"`
+
+		WalletDevNew func(context.Context, types.KeyType) (address.Address, error) `perm:"write"`
+
+		WalletServiceKeyNew    func(context.Context, string, types.KeyType) (*api.ServiceKey, error) `perm:"write"`
+		WalletServiceKeyList   func(context.Context) ([]api.ServiceKey, error)                       `perm:"read"`
+		WalletServiceKeySign   func(context.Context, string, []byte) ([]byte, error)                 `perm:"sign"`
+		WalletServiceKeyDelete func(context.Context, string) error                                   `perm:"admin"`
+
+		WalletBLSAggregateNew    func(context.Context, []byte, []address.Address) (*api.BLSAggregate, error)                  `perm:"sign"`
+		WalletBLSAggregateSubmit func(context.Context, []byte, address.Address, *crypto.Signature) (*api.BLSAggregate, error) `perm:"sign"`
+		WalletBLSAggregateGet    func(context.Context, []byte) (*api.BLSAggregate, error)                                     `perm:"read"`
+		WalletBLSAggregateList   func(context.Context) ([]api.BLSAggregate, error)                                            `perm:"write"`
+
+		WalletInstancePubkey func(context.Context) ([]byte, error) `perm:"read"`
+
+		WalletFindSignature func(context.Context, cid.Cid) (*api.SignatureRecord, error) `perm:"admin"`
+		WalletMsgSummary    func(context.Context, cid.Cid) (*api.MsgSummary, error)      `perm:"admin"`
+		WalletJournalPrune  func(context.Context, time.Duration, int) (int, error)       `perm:"admin"`
+
+		WalletPolicySet  func(context.Context, address.Address, *api.SigningPolicy) error   `perm:"admin"`
+		WalletPolicyGet  func(context.Context, address.Address) (*api.SigningPolicy, error) `perm:"read"`
+		WalletPolicyList func(context.Context) ([]api.AddressPolicy, error)                 `perm:"write"`
+
+		WalletSpendGet   func(context.Context, address.Address) (*api.SpendStatus, error) `perm:"read"`
+		WalletSpendReset func(context.Context, address.Address) error                     `perm:"admin"`
+
+		WalletGroupSet    func(context.Context, api.KeyGroup) error            `perm:"admin"`
+		WalletGroupGet    func(context.Context, string) (*api.KeyGroup, error) `perm:"write"`
+		WalletGroupRemove func(context.Context, string) error                  `perm:"admin"`
+		WalletGroupFreeze func(context.Context, string, bool) error            `perm:"admin"`
+		WalletGroupList   func(context.Context) ([]api.KeyGroup, error)        `perm:"write"`
+
+		WalletApprovalList      func(context.Context) ([]api.PendingSign, error)      `perm:"read"` // checks approver identity itself
+		WalletApprovalSubscribe func(context.Context) (<-chan api.PendingSign, error) `perm:"read"` // checks approver identity itself
+		WalletApprovalDecide    func(context.Context, uint64, bool) error             `perm:"read"` // checks approver identity itself
+		WalletApproverTokenNew  func(context.Context, string) ([]byte, error)         `perm:"admin"`
+
+		WalletAuditQuery     func(context.Context, api.AuditFilter) ([]api.AuditEntry, error)            `perm:"admin"`
+		WalletSecurityEvents func(context.Context, api.SecurityEventFilter) ([]api.SecurityEvent, error) `perm:"admin"`
+		WalletCustodyReport  func(context.Context) (*api.CustodyReport, error)                           `perm:"admin"`
+
+		WalletStatus             func(context.Context) (*api.WalletDaemonStatus, error)   `perm:"admin"`
+		WalletBackendMaintenance func(context.Context, string, bool, string) error        `perm:"admin"`
+		WalletRoutingGet         func(context.Context) (*api.BackendRouting, error)       `perm:"write"`
+		WalletRoutingSet         func(context.Context, *api.BackendRouting) error         `perm:"admin"`
+		WalletCidEncodings       func(context.Context, string) (*api.CidEncodings, error) `perm:"read"`
+		WalletBackendAdd         func(context.Context, api.BackendConfig) error           `perm:"admin"`
+		WalletBackendRemove      func(context.Context, string) error                      `perm:"admin"`
+		WalletBackendList        func(context.Context) ([]api.BackendInfo, error)         `perm:"read"`
+		WalletRemoteStatus       func(context.Context) ([]api.RemoteStatus, error)        `perm:"read"`
+	}
+}
+
 // CommonStruct
 
 func (c *CommonStruct) AuthVerify(ctx context.Context, token string) ([]auth.Permission, error) {
@@ -1794,9 +1874,238 @@ func (c *WalletStruct) WalletDelete(ctx context.Context, addr address.Address) e
 	return c.Internal.WalletDelete(ctx, addr)
 }
 
+func (c *WalletDaemonStruct) AuthVerify(ctx context.Context, token string) ([]auth.Permission, error) {
+	return c.Internal.AuthVerify(ctx, token)
+}
+
+func (c *WalletDaemonStruct) AuthNew(ctx context.Context, perms []auth.Permission) ([]byte, error) {
+	return c.Internal.AuthNew(ctx, perms)
+}
+
+func (c *WalletDaemonStruct) AuthNewScoped(ctx context.Context, perms []auth.Permission, scopes []string) ([]byte, error) {
+	return c.Internal.AuthNewScoped(ctx, perms, scopes)
+}
+
+func (c *WalletDaemonStruct) AuthList(ctx context.Context) ([]api.TokenInfo, error) {
+	return c.Internal.AuthList(ctx)
+}
+
+func (c *WalletDaemonStruct) AuthRevoke(ctx context.Context, id string) error {
+	return c.Internal.AuthRevoke(ctx, id)
+}
+
+func (c *WalletDaemonStruct) LogList(ctx context.Context) ([]string, error) {
+	return c.Internal.LogList(ctx)
+}
+
+func (c *WalletDaemonStruct) LogSetLevel(ctx context.Context, group, level string) error {
+	return c.Internal.LogSetLevel(ctx, group, level)
+}
+
+func (c *WalletDaemonStruct) Version(ctx context.Context) (api.Version, error) {
+	return c.Internal.Version(ctx)
+}
+
+func (c *WalletDaemonStruct) Session(ctx context.Context) (uuid.UUID, error) {
+	return c.Internal.Session(ctx)
+}
+
+func (c *WalletDaemonStruct) WalletListPage(ctx context.Context, f api.WalletListFilter) (*api.WalletListPage, error) {
+	return c.Internal.WalletListPage(ctx, f)
+}
+
+func (c *WalletDaemonStruct) WalletBackends(ctx context.Context) ([]api.AddressBackend, error) {
+	return c.Internal.WalletBackends(ctx)
+}
+
+func (c *WalletDaemonStruct) WalletListOwned(ctx context.Context) ([]api.OwnedAddress, error) {
+	return c.Internal.WalletListOwned(ctx)
+}
+
+func (c *WalletDaemonStruct) WalletLedgerShow(ctx context.Context, addr address.Address) error {
+	return c.Internal.WalletLedgerShow(ctx, addr)
+}
+
+func (c *WalletDaemonStruct) WalletHDInit(ctx context.Context, mnemonic, passphrase string) error {
+	return c.Internal.WalletHDInit(ctx, mnemonic, passphrase)
+}
+
+func (c *WalletDaemonStruct) WalletNewHD(ctx context.Context, path string) (address.Address, error) {
+	return c.Internal.WalletNewHD(ctx, path)
+}
+
+func (c *WalletDaemonStruct) WalletHDList(ctx context.Context) ([]api.HDAccount, error) {
+	return c.Internal.WalletHDList(ctx)
+}
+
+func (c *WalletDaemonStruct) WalletHDLabel(ctx context.Context, addr address.Address, label string) error {
+	return c.Internal.WalletHDLabel(ctx, addr, label)
+}
+
+func (c *WalletDaemonStruct) WalletDevNew(ctx context.Context, typ types.KeyType) (address.Address, error) {
+	return c.Internal.WalletDevNew(ctx, typ)
+}
+
+func (c *WalletDaemonStruct) WalletServiceKeyNew(ctx context.Context, name string, typ types.KeyType) (*api.ServiceKey, error) {
+	return c.Internal.WalletServiceKeyNew(ctx, name, typ)
+}
+
+func (c *WalletDaemonStruct) WalletServiceKeyList(ctx context.Context) ([]api.ServiceKey, error) {
+	return c.Internal.WalletServiceKeyList(ctx)
+}
+
+func (c *WalletDaemonStruct) WalletServiceKeySign(ctx context.Context, name string, data []byte) ([]byte, error) {
+	return c.Internal.WalletServiceKeySign(ctx, name, data)
+}
+
+func (c *WalletDaemonStruct) WalletServiceKeyDelete(ctx context.Context, name string) error {
+	return c.Internal.WalletServiceKeyDelete(ctx, name)
+}
+
+func (c *WalletDaemonStruct) WalletHasMany(ctx context.Context, addrs []address.Address) ([]bool, error) {
+	return c.Internal.WalletHasMany(ctx, addrs)
+}
+
+func (c *WalletDaemonStruct) WalletBLSAggregateNew(ctx context.Context, toSign []byte, signers []address.Address) (*api.BLSAggregate, error) {
+	return c.Internal.WalletBLSAggregateNew(ctx, toSign, signers)
+}
+
+func (c *WalletDaemonStruct) WalletBLSAggregateSubmit(ctx context.Context, toSign []byte, signer address.Address, sig *crypto.Signature) (*api.BLSAggregate, error) {
+	return c.Internal.WalletBLSAggregateSubmit(ctx, toSign, signer, sig)
+}
+
+func (c *WalletDaemonStruct) WalletBLSAggregateGet(ctx context.Context, toSign []byte) (*api.BLSAggregate, error) {
+	return c.Internal.WalletBLSAggregateGet(ctx, toSign)
+}
+
+func (c *WalletDaemonStruct) WalletBLSAggregateList(ctx context.Context) ([]api.BLSAggregate, error) {
+	return c.Internal.WalletBLSAggregateList(ctx)
+}
+
+func (c *WalletDaemonStruct) WalletInstancePubkey(ctx context.Context) ([]byte, error) {
+	return c.Internal.WalletInstancePubkey(ctx)
+}
+
+func (c *WalletDaemonStruct) WalletFindSignature(ctx context.Context, mc cid.Cid) (*api.SignatureRecord, error) {
+	return c.Internal.WalletFindSignature(ctx, mc)
+}
+
+func (c *WalletDaemonStruct) WalletMsgSummary(ctx context.Context, mc cid.Cid) (*api.MsgSummary, error) {
+	return c.Internal.WalletMsgSummary(ctx, mc)
+}
+
+func (c *WalletDaemonStruct) WalletJournalPrune(ctx context.Context, maxAge time.Duration, maxEntries int) (int, error) {
+	return c.Internal.WalletJournalPrune(ctx, maxAge, maxEntries)
+}
+
+func (c *WalletDaemonStruct) WalletPolicySet(ctx context.Context, addr address.Address, p *api.SigningPolicy) error {
+	return c.Internal.WalletPolicySet(ctx, addr, p)
+}
+
+func (c *WalletDaemonStruct) WalletPolicyGet(ctx context.Context, addr address.Address) (*api.SigningPolicy, error) {
+	return c.Internal.WalletPolicyGet(ctx, addr)
+}
+
+func (c *WalletDaemonStruct) WalletPolicyList(ctx context.Context) ([]api.AddressPolicy, error) {
+	return c.Internal.WalletPolicyList(ctx)
+}
+
+func (c *WalletDaemonStruct) WalletSpendGet(ctx context.Context, addr address.Address) (*api.SpendStatus, error) {
+	return c.Internal.WalletSpendGet(ctx, addr)
+}
+
+func (c *WalletDaemonStruct) WalletSpendReset(ctx context.Context, addr address.Address) error {
+	return c.Internal.WalletSpendReset(ctx, addr)
+}
+
+func (c *WalletDaemonStruct) WalletGroupSet(ctx context.Context, g api.KeyGroup) error {
+	return c.Internal.WalletGroupSet(ctx, g)
+}
+
+func (c *WalletDaemonStruct) WalletGroupGet(ctx context.Context, name string) (*api.KeyGroup, error) {
+	return c.Internal.WalletGroupGet(ctx, name)
+}
+
+func (c *WalletDaemonStruct) WalletGroupRemove(ctx context.Context, name string) error {
+	return c.Internal.WalletGroupRemove(ctx, name)
+}
+
+func (c *WalletDaemonStruct) WalletGroupFreeze(ctx context.Context, name string, frozen bool) error {
+	return c.Internal.WalletGroupFreeze(ctx, name, frozen)
+}
+
+func (c *WalletDaemonStruct) WalletGroupList(ctx context.Context) ([]api.KeyGroup, error) {
+	return c.Internal.WalletGroupList(ctx)
+}
+
+func (c *WalletDaemonStruct) WalletApprovalList(ctx context.Context) ([]api.PendingSign, error) {
+	return c.Internal.WalletApprovalList(ctx)
+}
+
+func (c *WalletDaemonStruct) WalletApprovalSubscribe(ctx context.Context) (<-chan api.PendingSign, error) {
+	return c.Internal.WalletApprovalSubscribe(ctx)
+}
+
+func (c *WalletDaemonStruct) WalletApprovalDecide(ctx context.Context, id uint64, approve bool) error {
+	return c.Internal.WalletApprovalDecide(ctx, id, approve)
+}
+
+func (c *WalletDaemonStruct) WalletApproverTokenNew(ctx context.Context, name string) ([]byte, error) {
+	return c.Internal.WalletApproverTokenNew(ctx, name)
+}
+
+func (c *WalletDaemonStruct) WalletAuditQuery(ctx context.Context, f api.AuditFilter) ([]api.AuditEntry, error) {
+	return c.Internal.WalletAuditQuery(ctx, f)
+}
+
+func (c *WalletDaemonStruct) WalletSecurityEvents(ctx context.Context, f api.SecurityEventFilter) ([]api.SecurityEvent, error) {
+	return c.Internal.WalletSecurityEvents(ctx, f)
+}
+
+func (c *WalletDaemonStruct) WalletCustodyReport(ctx context.Context) (*api.CustodyReport, error) {
+	return c.Internal.WalletCustodyReport(ctx)
+}
+
+func (c *WalletDaemonStruct) WalletStatus(ctx context.Context) (*api.WalletDaemonStatus, error) {
+	return c.Internal.WalletStatus(ctx)
+}
+
+func (c *WalletDaemonStruct) WalletBackendMaintenance(ctx context.Context, backend string, on bool, reason string) error {
+	return c.Internal.WalletBackendMaintenance(ctx, backend, on, reason)
+}
+
+func (c *WalletDaemonStruct) WalletRoutingGet(ctx context.Context) (*api.BackendRouting, error) {
+	return c.Internal.WalletRoutingGet(ctx)
+}
+
+func (c *WalletDaemonStruct) WalletRoutingSet(ctx context.Context, r *api.BackendRouting) error {
+	return c.Internal.WalletRoutingSet(ctx, r)
+}
+
+func (c *WalletDaemonStruct) WalletCidEncodings(ctx context.Context, s string) (*api.CidEncodings, error) {
+	return c.Internal.WalletCidEncodings(ctx, s)
+}
+
+func (c *WalletDaemonStruct) WalletBackendAdd(ctx context.Context, cfg api.BackendConfig) error {
+	return c.Internal.WalletBackendAdd(ctx, cfg)
+}
+
+func (c *WalletDaemonStruct) WalletBackendRemove(ctx context.Context, backend string) error {
+	return c.Internal.WalletBackendRemove(ctx, backend)
+}
+
+func (c *WalletDaemonStruct) WalletBackendList(ctx context.Context) ([]api.BackendInfo, error) {
+	return c.Internal.WalletBackendList(ctx)
+}
+
+func (c *WalletDaemonStruct) WalletRemoteStatus(ctx context.Context) ([]api.RemoteStatus, error) {
+	return c.Internal.WalletRemoteStatus(ctx)
+}
+
 var _ api.Common = &CommonStruct{}
 var _ api.FullNode = &FullNodeStruct{}
 var _ api.StorageMiner = &StorageMinerStruct{}
 var _ api.WorkerAPI = &WorkerStruct{}
 var _ api.GatewayAPI = &GatewayStruct{}
 var _ api.WalletAPI = &WalletStruct{}
+var _ api.WalletDaemonAPI = &WalletDaemonStruct{}
@@ -108,3 +108,17 @@ func NewWalletRPC(ctx context.Context, addr string, requestHeader http.Header) (
 
 	return &res, closer, err
 }
+
+// NewWalletDaemonRPC creates a new http jsonrpc client for the lotus-wallet daemon.
+func NewWalletDaemonRPC(ctx context.Context, addr string, requestHeader http.Header) (api.WalletDaemonAPI, jsonrpc.ClientCloser, error) {
+	var res apistruct.WalletDaemonStruct
+	closer, err := jsonrpc.NewMergeClient(ctx, addr, "Filecoin",
+		[]interface{}{
+			&res.WalletStruct.Internal,
+			&res.Internal,
+		},
+		requestHeader,
+	)
+
+	return &res, closer, err
+}
@@ -0,0 +1,199 @@
+package wallet
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"go.opencensus.io/stats"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/metrics"
+)
+
+const dsAddrWatchPrefix = "/addrwatch/"
+
+var dsAddrWatchBaseline = datastore.NewKey("/addrwatch-baseline")
+
+// AddressWatch alerts when addresses show up in WalletList which weren't
+// created or imported through this daemon, e.g. keys added to a remote wallet
+// behind its back. In a custody wallet a new unknown address is a serious
+// signal, so every one is logged at error level, recorded in the security log
+// and counted in the wallet/unexpected_address metric. The first check after
+// enabling the watch records the existing addresses without alerting.
+type AddressWatch struct {
+	api.WalletAPI
+
+	ds     datastore.Datastore
+	seclog *SecurityLog
+	index  *BackendIndex
+
+	lk       sync.Mutex
+	known    map[address.Address]struct{}
+	baseline bool
+}
+
+func NewAddressWatch(under api.WalletAPI, ds datastore.Datastore, seclog *SecurityLog, index *BackendIndex) (*AddressWatch, error) {
+	baseline, err := ds.Has(dsAddrWatchBaseline)
+	if err != nil {
+		return nil, xerrors.Errorf("checking address watch baseline: %w", err)
+	}
+
+	res, err := ds.Query(query.Query{Prefix: dsAddrWatchPrefix, KeysOnly: true})
+	if err != nil {
+		return nil, xerrors.Errorf("querying known addresses: %w", err)
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, xerrors.Errorf("listing known addresses: %w", err)
+	}
+
+	known := map[address.Address]struct{}{}
+	for _, e := range entries {
+		a, err := address.NewFromString(strings.TrimPrefix(e.Key, dsAddrWatchPrefix))
+		if err != nil {
+			log.Warnw("skipping invalid known address entry", "key", e.Key, "error", err)
+			continue
+		}
+		known[a] = struct{}{}
+	}
+
+	return &AddressWatch{
+		WalletAPI: under,
+		ds:        ds,
+		seclog:    seclog,
+		index:     index,
+		known:     known,
+		baseline:  baseline,
+	}, nil
+}
+
+func addrWatchKey(addr address.Address) datastore.Key {
+	return datastore.NewKey(dsAddrWatchPrefix + addr.String())
+}
+
+func (w *AddressWatch) expect(addr address.Address) {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	if _, ok := w.known[addr]; ok {
+		return
+	}
+	if err := w.ds.Put(addrWatchKey(addr), seenNow()); err != nil {
+		log.Warnw("recording known address", "address", addr, "error", err)
+		return
+	}
+	w.known[addr] = struct{}{}
+}
+
+func (w *AddressWatch) WalletNew(ctx context.Context, typ types.KeyType) (address.Address, error) {
+	a, err := w.WalletAPI.WalletNew(ctx, typ)
+	if err == nil {
+		w.expect(a)
+	}
+	return a, err
+}
+
+func (w *AddressWatch) WalletImport(ctx context.Context, ki *types.KeyInfo) (address.Address, error) {
+	a, err := w.WalletAPI.WalletImport(ctx, ki)
+	if err == nil {
+		w.expect(a)
+	}
+	return a, err
+}
+
+func (w *AddressWatch) WalletDelete(ctx context.Context, addr address.Address) error {
+	if err := w.WalletAPI.WalletDelete(ctx, addr); err != nil {
+		return err
+	}
+
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	if err := w.ds.Delete(addrWatchKey(addr)); err != nil {
+		log.Warnw("removing known address", "address", addr, "error", err)
+	}
+	delete(w.known, addr)
+	return nil
+}
+
+func (w *AddressWatch) WalletList(ctx context.Context) ([]address.Address, error) {
+	l, err := w.WalletAPI.WalletList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	w.check(ctx, l)
+	return l, nil
+}
+
+// check compares listed addresses with the known ones, alerting on new ones
+func (w *AddressWatch) check(ctx context.Context, listed []address.Address) {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	var unexpected []address.Address
+	for _, a := range listed {
+		if _, ok := w.known[a]; ok {
+			continue
+		}
+		if err := w.ds.Put(addrWatchKey(a), seenNow()); err != nil {
+			log.Warnw("recording known address", "address", a, "error", err)
+			continue
+		}
+		w.known[a] = struct{}{}
+		unexpected = append(unexpected, a)
+	}
+
+	if !w.baseline {
+		if err := w.ds.Put(dsAddrWatchBaseline, seenNow()); err != nil {
+			log.Warnw("recording address watch baseline", "error", err)
+			return
+		}
+		w.baseline = true
+		log.Infow("recorded address watch baseline", "addresses", len(listed))
+		return
+	}
+
+	for _, a := range unexpected {
+		backend := "unknown"
+		if k, ok := w.index.Lookup(a); ok {
+			backend = k.Backend
+		}
+
+		log.Errorw("UNEXPECTED ADDRESS appeared in wallet, it wasn't created or imported through this daemon", "address", a, "backend", backend)
+		w.seclog.Record(ctx, SecEvAddressAppeared, a.String(), "backend "+backend)
+	}
+	if len(unexpected) > 0 {
+		stats.Record(ctx, metrics.WalletUnexpectedAddress.M(int64(len(unexpected))))
+	}
+}
+
+// Run lists addresses every interval, so that unexpected addresses are
+// noticed without waiting for a client to list them.
+func (w *AddressWatch) Run(ctx context.Context, interval time.Duration) {
+	for {
+		if _, err := w.WalletList(ctx); err != nil {
+			log.Warnw("address watch: listing addresses", "error", err)
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// seenNow is stored with known addresses for reference when inspecting the
+// datastore
+func seenNow() []byte {
+	return []byte(time.Now().UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,335 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"go.opencensus.io/trace"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+var dsApprovalPrefix = "/approvals/"
+
+var ErrSignRejected = xerrors.New("sign request rejected by operator")
+
+type approverKey struct{}
+
+// WithApprover marks the request as made by the named approver.
+func WithApprover(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, approverKey{}, name)
+}
+
+// ApproverFromContext returns the approver set with WithApprover, or an empty
+// string.
+func ApproverFromContext(ctx context.Context) string {
+	a, _ := ctx.Value(approverKey{}).(string)
+	return a
+}
+
+type ApprovalConfig struct {
+	// Hold every sign request for approval
+	All bool
+	// Hold chain messages with a value above this for approval, and requests
+	// which can't be decoded
+	Threshold *types.BigInt
+
+	// Names of approvers allowed to decide on requests. When empty, any
+	// operator with admin access can decide.
+	Approvers []string
+	// Number of distinct approvals needed before a request is signed
+	Required int
+
+	// Called for every request put up for approval, optional
+	OnPending func(api.PendingSign)
+}
+
+// ApprovalWallet holds sign requests in a pending queue until enough
+// approvals arrive, or one approver rejects them. Requests are dropped from
+// the queue when the caller gives up waiting. Decisions are recorded in the
+// datastore.
+type ApprovalWallet struct {
+	api.WalletAPI
+
+	cfg       ApprovalConfig
+	approvers map[string]struct{}
+	ds        datastore.Datastore
+	summaries *MsgSummaries
+
+	lk      sync.Mutex
+	next    uint64
+	pending map[uint64]*pendingSign
+	subs    map[chan api.PendingSign]struct{}
+}
+
+// approvalSubBuffer is the number of requests buffered for a subscriber on
+// top of the initially pending ones. Requests arriving while the buffer is
+// full are dropped for that subscriber.
+const approvalSubBuffer = 64
+
+type pendingSign struct {
+	info     api.PendingSign
+	decision chan error
+}
+
+// approvalRecord is stored for every decision on a sign request
+type approvalRecord struct {
+	Request  api.PendingSign
+	Approver string
+	Approve  bool
+	Time     time.Time
+}
+
+func NewApprovalWallet(under api.WalletAPI, ds datastore.Datastore, summaries *MsgSummaries, cfg ApprovalConfig) *ApprovalWallet {
+	if cfg.Required < 1 {
+		cfg.Required = 1
+	}
+
+	approvers := map[string]struct{}{}
+	for _, a := range cfg.Approvers {
+		approvers[a] = struct{}{}
+	}
+
+	return &ApprovalWallet{
+		WalletAPI: under,
+		cfg:       cfg,
+		approvers: approvers,
+		ds:        ds,
+		summaries: summaries,
+		pending:   map[uint64]*pendingSign{},
+		subs:      map[chan api.PendingSign]struct{}{},
+	}
+}
+
+// Config returns the approval settings
+func (a *ApprovalWallet) Config() ApprovalConfig {
+	return a.cfg
+}
+
+// IsApprover returns whether the name is a registered approver
+func (a *ApprovalWallet) IsApprover(name string) bool {
+	_, ok := a.approvers[name]
+	return ok
+}
+
+// needsApproval returns whether a request is held. With a threshold, requests
+// which don't decode as a chain message are held too: their bytes could be a
+// relabeled message CID of any value. Only other types whose handler decodes
+// the request, like block headers, can't carry a message and go through.
+func (a *ApprovalWallet) needsApproval(msg *types.Message, toSign []byte, meta api.MsgMeta) bool {
+	if a.cfg.All {
+		return true
+	}
+	if a.cfg.Threshold == nil {
+		return false
+	}
+	if msg != nil {
+		return msg.Value.GreaterThan(*a.cfg.Threshold)
+	}
+
+	h, ok := MsgTypeHandlerOf(meta.Type)
+	if !ok || meta.Type == api.MTChainMsg {
+		return true
+	}
+	_, err := h.Decode(toSign, meta)
+	return err != nil
+}
+
+func (a *ApprovalWallet) WalletSign(ctx context.Context, signer address.Address, toSign []byte, meta api.MsgMeta) (*crypto.Signature, error) {
+	info := api.PendingSign{
+		Signer:   signer,
+		Type:     meta.Type,
+		Reason:   meta.Reason,
+		Caller:   CallerFromContext(ctx),
+		Received: time.Now(),
+		Required: a.cfg.Required,
+	}
+	sum, err := a.summaries.Summarize(toSign, meta)
+	if err != nil {
+		return nil, err
+	}
+	info.Cid = sum.Cid
+	info.Message = sum.Message
+	info.Summary = sum.Summary
+
+	if !a.needsApproval(info.Message, toSign, meta) {
+		return a.WalletAPI.WalletSign(ctx, signer, toSign, meta)
+	}
+
+	ps := &pendingSign{decision: make(chan error, 1)}
+
+	a.lk.Lock()
+	a.next++
+	info.ID = a.next
+	ps.info = info
+	a.pending[info.ID] = ps
+	a.publish(info)
+	a.lk.Unlock()
+
+	log.Infow("sign request waiting for approval", "id", info.ID, "signer", signer, "type", meta.Type, "reason", meta.Reason, "required", a.cfg.Required)
+	if a.cfg.OnPending != nil {
+		a.cfg.OnPending(info)
+	}
+
+	if err := a.wait(ctx, ps); err != nil {
+		return nil, err
+	}
+
+	return a.WalletAPI.WalletSign(ctx, signer, toSign, meta)
+}
+
+func (a *ApprovalWallet) wait(ctx context.Context, ps *pendingSign) error {
+	_, span := trace.StartSpan(ctx, "ApprovalWallet.wait")
+	defer span.End()
+	span.AddAttributes(trace.Int64Attribute("id", int64(ps.info.ID)))
+
+	select {
+	case err := <-ps.decision:
+		return err
+	case <-ctx.Done():
+		a.lk.Lock()
+		delete(a.pending, ps.info.ID)
+		a.lk.Unlock()
+
+		log.Warnw("sign request abandoned before approval", "id", ps.info.ID, "signer", ps.info.Signer)
+		return ctx.Err()
+	}
+}
+
+// WalletApprovalSubscribe returns a channel receiving the requests pending at
+// the time of the call, followed by new requests as they are put up for
+// approval. The channel is closed when ctx is done.
+func (a *ApprovalWallet) WalletApprovalSubscribe(ctx context.Context) (<-chan api.PendingSign, error) {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	ids := make([]uint64, 0, len(a.pending))
+	for id := range a.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	ch := make(chan api.PendingSign, len(ids)+approvalSubBuffer)
+	for _, id := range ids {
+		ch <- a.pending[id].info
+	}
+	a.subs[ch] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+
+		a.lk.Lock()
+		delete(a.subs, ch)
+		a.lk.Unlock()
+		close(ch)
+	}()
+
+	return ch, nil
+}
+
+// publish sends a new pending request to subscribers. Must be called with
+// a.lk held.
+func (a *ApprovalWallet) publish(info api.PendingSign) {
+	for ch := range a.subs {
+		select {
+		case ch <- info:
+		default:
+			log.Warnw("approval subscriber not keeping up, dropping request notification", "id", info.ID)
+		}
+	}
+}
+
+func (a *ApprovalWallet) WalletApprovalList(ctx context.Context) ([]api.PendingSign, error) {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	out := make([]api.PendingSign, 0, len(a.pending))
+	for _, ps := range a.pending {
+		info := ps.info
+		info.Approvals = append([]string(nil), ps.info.Approvals...)
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
+
+	return out, nil
+}
+
+// WalletApprovalDecide records a decision of the approver set in the context.
+// A single rejection rejects the request; it is approved once the configured
+// number of distinct approvers approved it.
+func (a *ApprovalWallet) WalletApprovalDecide(ctx context.Context, id uint64, approve bool) error {
+	approver := ApproverFromContext(ctx)
+	if len(a.approvers) > 0 && !a.IsApprover(approver) {
+		return xerrors.Errorf("sign requests must be decided with a registered approver token")
+	}
+	if approver == "" {
+		approver = "operator"
+	}
+
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	ps, ok := a.pending[id]
+	if !ok {
+		return xerrors.Errorf("no pending sign request with id %d", id)
+	}
+
+	for _, prev := range ps.info.Approvals {
+		if prev == approver {
+			return xerrors.Errorf("%s already approved request %d", approver, id)
+		}
+	}
+
+	if err := a.record(approvalRecord{
+		Request:  ps.info,
+		Approver: approver,
+		Approve:  approve,
+		Time:     time.Now(),
+	}); err != nil {
+		return err
+	}
+
+	log.Infow("sign request decision", "id", id, "signer", ps.info.Signer, "approver", approver, "approved", approve)
+
+	if !approve {
+		delete(a.pending, id)
+		ps.decision <- xerrors.Errorf("%w (by %s)", ErrSignRejected, approver)
+		return nil
+	}
+
+	ps.info.Approvals = append(ps.info.Approvals, approver)
+	if len(ps.info.Approvals) >= a.cfg.Required {
+		delete(a.pending, id)
+		ps.decision <- nil
+	}
+
+	return nil
+}
+
+func (a *ApprovalWallet) record(rec approvalRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return xerrors.Errorf("marshaling approval record: %w", err)
+	}
+
+	// request ids restart with the daemon, the receive time keeps keys unique
+	k := fmt.Sprintf("%s%d-%d/%s", dsApprovalPrefix, rec.Request.Received.UnixNano(), rec.Request.ID, rec.Approver)
+	if err := a.ds.Put(datastore.NewKey(k), b); err != nil {
+		return xerrors.Errorf("recording approval: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func newTestApprovalWallet(t *testing.T, cfg ApprovalConfig) (*ApprovalWallet, address.Address) {
+	lw, err := NewWallet(NewMemKeyStore())
+	require.NoError(t, err)
+
+	signer, err := lw.WalletNew(context.Background(), types.KTSecp256k1)
+	require.NoError(t, err)
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	return NewApprovalWallet(lw, ds, NewMsgSummaries(ds), cfg), signer
+}
+
+func TestApprovalConcurrentDecisions(t *testing.T) {
+	ctx := context.Background()
+	aw, signer := newTestApprovalWallet(t, ApprovalConfig{
+		All:       true,
+		Approvers: []string{"alice", "bob"},
+		Required:  2,
+	})
+
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pending, err := aw.WalletApprovalSubscribe(sctx)
+	require.NoError(t, err)
+
+	const n = 20
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			_, err := aw.WalletSign(ctx, signer, []byte(fmt.Sprintf("data %d", i)), api.MsgMeta{Type: api.MTUnknown})
+			errs <- err
+		}(i)
+	}
+
+	// both approvers decide on every request at the same time, while the
+	// queue is listed
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		ps := <-pending
+		for _, name := range []string{"alice", "bob"} {
+			wg.Add(1)
+			go func(id uint64, name string) {
+				defer wg.Done()
+				assert.NoError(t, aw.WalletApprovalDecide(WithApprover(ctx, name), id, true))
+				_, err := aw.WalletApprovalList(ctx)
+				assert.NoError(t, err)
+			}(ps.ID, name)
+		}
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	left, err := aw.WalletApprovalList(ctx)
+	require.NoError(t, err)
+	require.Empty(t, left)
+}
+
+func TestApprovalAbandonRacesDecision(t *testing.T) {
+	ctx := context.Background()
+	aw, signer := newTestApprovalWallet(t, ApprovalConfig{All: true})
+
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pending, err := aw.WalletApprovalSubscribe(sctx)
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		rctx, rcancel := context.WithCancel(ctx)
+		errs := make(chan error, 1)
+		go func(i int) {
+			_, err := aw.WalletSign(rctx, signer, []byte(fmt.Sprintf("data %d", i)), api.MsgMeta{Type: api.MTUnknown})
+			errs <- err
+		}(i)
+
+		ps := <-pending
+
+		// the caller gives up while the operator approves
+		go rcancel()
+		derr := aw.WalletApprovalDecide(ctx, ps.ID, true)
+
+		err := <-errs
+		if err != nil {
+			require.True(t, xerrors.Is(err, context.Canceled), err)
+		}
+		if derr != nil {
+			require.Error(t, err, "request was signed although approving it failed")
+		}
+	}
+
+	left, err := aw.WalletApprovalList(ctx)
+	require.NoError(t, err)
+	require.Empty(t, left)
+}
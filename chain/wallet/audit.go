@@ -0,0 +1,296 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+var dsAuditPrefix = "/audit/"
+
+const (
+	AuditOpSign   = "sign"
+	AuditOpExport = "export"
+	AuditOpDelete = "delete"
+)
+
+// AuditWallet records every sign, export and delete request, including failed
+// ones, in the datastore. Entries are keyed by day and time, so that queries
+// by time range only read the days in the range.
+type AuditWallet struct {
+	api.WalletAPI
+
+	ds        datastore.Datastore
+	summaries *MsgSummaries
+	seq       uint64
+}
+
+func NewAuditWallet(under api.WalletAPI, ds datastore.Datastore, summaries *MsgSummaries) *AuditWallet {
+	return &AuditWallet{
+		WalletAPI: under,
+		ds:        ds,
+		summaries: summaries,
+	}
+}
+
+func (a *AuditWallet) WalletSign(ctx context.Context, signer address.Address, toSign []byte, meta api.MsgMeta) (*crypto.Signature, error) {
+	e := api.AuditEntry{
+		Op:      AuditOpSign,
+		Address: signer,
+		Type:    meta.Type,
+		Reason:  meta.Reason,
+	}
+	if sum, err := a.summaries.Summarize(toSign, meta); err == nil {
+		e.Cid = sum.Cid
+		e.Summary = sum.Summary
+		if msg := sum.Message; msg != nil {
+			e.To = &msg.To
+			e.Method = msg.Method
+			e.Value = &msg.Value
+			e.GasFeeCap = &msg.GasFeeCap
+		}
+	}
+
+	sig, err := a.WalletAPI.WalletSign(ctx, signer, toSign, meta)
+	a.record(ctx, e, err)
+	return sig, err
+}
+
+func (a *AuditWallet) WalletExport(ctx context.Context, addr address.Address) (*types.KeyInfo, error) {
+	ki, err := a.WalletAPI.WalletExport(ctx, addr)
+	a.record(ctx, api.AuditEntry{Op: AuditOpExport, Address: addr}, err)
+	return ki, err
+}
+
+func (a *AuditWallet) WalletDelete(ctx context.Context, addr address.Address) error {
+	err := a.WalletAPI.WalletDelete(ctx, addr)
+	a.record(ctx, api.AuditEntry{Op: AuditOpDelete, Address: addr}, err)
+	return err
+}
+
+func (a *AuditWallet) record(ctx context.Context, e api.AuditEntry, opErr error) {
+	e.Time = time.Now()
+	e.Caller = CallerFromContext(ctx)
+	if opErr != nil {
+		e.Error = opErr.Error()
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		log.Errorw("marshaling audit entry", "error", err)
+		return
+	}
+
+	if err := a.ds.Put(auditKey(e.Time, atomic.AddUint64(&a.seq, 1)), b); err != nil {
+		// the operation already happened, don't fail the request
+		log.Errorw("recording audit entry", "op", e.Op, "address", e.Address, "error", err)
+	}
+}
+
+// auditDay returns the day audit entries recorded at t are kept under
+func auditDay(t time.Time) int64 {
+	return t.UnixNano() / int64(24*time.Hour)
+}
+
+func auditDayPrefix(day int64) string {
+	return fmt.Sprintf("%s%08d/", dsAuditPrefix, day)
+}
+
+// auditKey returns the key of an audit entry. Days and times are zero-padded
+// so that keys sort by time; the sequence number keeps entries recorded
+// within the same nanosecond apart.
+func auditKey(t time.Time, seq uint64) datastore.Key {
+	return datastore.NewKey(fmt.Sprintf("%s%020d-%d", auditDayPrefix(auditDay(t)), t.UnixNano(), seq))
+}
+
+// auditKeyTime returns the time an audit entry was recorded at from its key
+func auditKeyTime(k string) (time.Time, error) {
+	name := datastore.NewKey(k).BaseNamespace()
+	ns, err := strconv.ParseInt(strings.SplitN(name, "-", 2)[0], 10, 64)
+	if err != nil {
+		return time.Time{}, xerrors.Errorf("malformed audit key %s", k)
+	}
+	return time.Unix(0, ns), nil
+}
+
+// firstAuditDay returns the day of the oldest audit entry
+func (a *AuditWallet) firstAuditDay() (int64, bool, error) {
+	res, err := a.ds.Query(query.Query{
+		Prefix:   dsAuditPrefix,
+		KeysOnly: true,
+		Orders:   []query.Order{query.OrderByKey{}},
+		Limit:    1,
+	})
+	if err != nil {
+		return 0, false, xerrors.Errorf("querying audit log: %w", err)
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return 0, false, xerrors.Errorf("querying audit log: %w", err)
+	}
+	if len(entries) == 0 {
+		return 0, false, nil
+	}
+
+	t, err := auditKeyTime(entries[0].Key)
+	if err != nil {
+		return 0, false, err
+	}
+	return auditDay(t), true, nil
+}
+
+// WalletAuditQuery returns audit entries matching the filter, oldest first.
+// With a limit set, the most recent matching entries are returned.
+func (a *AuditWallet) WalletAuditQuery(ctx context.Context, f api.AuditFilter) ([]api.AuditEntry, error) {
+	var out []api.AuditEntry
+
+	// scan reads the entries under prefix, returning false once it got past
+	// Until
+	scan := func(prefix string) (bool, error) {
+		res, err := a.ds.Query(query.Query{
+			Prefix: prefix,
+			Orders: []query.Order{query.OrderByKey{}},
+		})
+		if err != nil {
+			return false, xerrors.Errorf("querying audit log: %w", err)
+		}
+		defer res.Close() //nolint:errcheck
+
+		for r := range res.Next() {
+			if r.Error != nil {
+				return false, xerrors.Errorf("iterating audit log: %w", r.Error)
+			}
+
+			var e api.AuditEntry
+			if err := json.Unmarshal(r.Value, &e); err != nil {
+				return false, xerrors.Errorf("unmarshaling audit entry %s: %w", r.Key, err)
+			}
+
+			if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+				return false, nil
+			}
+			if f.Address != nil && e.Address != *f.Address {
+				continue
+			}
+			if !f.Since.IsZero() && e.Time.Before(f.Since) {
+				continue
+			}
+
+			out = append(out, e)
+			if f.Limit > 0 && len(out) > f.Limit {
+				out = out[1:]
+			}
+		}
+		return true, nil
+	}
+
+	if f.Since.IsZero() {
+		if _, err := scan(dsAuditPrefix); err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+
+	// start at the day of Since, or of the oldest entry if that is later
+	first, ok, err := a.firstAuditDay()
+	if err != nil || !ok {
+		return nil, err
+	}
+	day := auditDay(f.Since)
+	if first > day {
+		day = first
+	}
+	last := auditDay(time.Now())
+	if !f.Until.IsZero() && auditDay(f.Until) < last {
+		last = auditDay(f.Until)
+	}
+
+	for ; day <= last; day++ {
+		more, err := scan(auditDayPrefix(day))
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			break
+		}
+	}
+
+	return out, nil
+}
+
+// Prune removes entries older than maxAge, and then the oldest entries until
+// at most maxEntries remain. Zero values disable the respective limit.
+// Returns the number of removed entries.
+func (a *AuditWallet) Prune(ctx context.Context, maxAge time.Duration, maxEntries int) (int, error) {
+	res, err := a.ds.Query(query.Query{
+		Prefix:   dsAuditPrefix,
+		KeysOnly: true,
+		Orders:   []query.Order{query.OrderByKey{}},
+	})
+	if err != nil {
+		return 0, xerrors.Errorf("querying audit log: %w", err)
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return 0, xerrors.Errorf("iterating audit log: %w", err)
+	}
+
+	// keys sort by time
+	drop := 0
+	if maxAge > 0 {
+		cutoff := time.Now().Add(-maxAge)
+		for drop < len(entries) {
+			t, err := auditKeyTime(entries[drop].Key)
+			if err != nil {
+				log.Warnw("malformed audit key, treating as oldest", "key", entries[drop].Key, "error", err)
+			} else if !t.Before(cutoff) {
+				break
+			}
+			drop++
+		}
+	}
+	if maxEntries > 0 && len(entries)-drop > maxEntries {
+		drop = len(entries) - maxEntries
+	}
+
+	for _, e := range entries[:drop] {
+		if err := a.ds.Delete(datastore.NewKey(e.Key)); err != nil {
+			return 0, xerrors.Errorf("removing audit entry: %w", err)
+		}
+	}
+
+	return drop, nil
+}
+
+// RunRetention prunes the audit log periodically until the context is
+// cancelled.
+func (a *AuditWallet) RunRetention(ctx context.Context, interval, maxAge time.Duration, maxEntries int) {
+	for {
+		n, err := a.Prune(ctx, maxAge, maxEntries)
+		if err != nil {
+			log.Errorw("pruning audit log", "error", err)
+		} else if n > 0 {
+			log.Infow("pruned audit log", "removed", n)
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+func TestAuditQueryAcrossDays(t *testing.T) {
+	ctx := context.Background()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	aw := NewAuditWallet(nil, ds, NewMsgSummaries(ds))
+
+	start := time.Now().Add(-72 * time.Hour)
+	var times []time.Time
+	for i := 0; i < 12; i++ {
+		times = append(times, start.Add(time.Duration(i)*6*time.Hour))
+	}
+
+	for i, tm := range times {
+		b, err := json.Marshal(api.AuditEntry{Op: AuditOpSign, Time: tm})
+		require.NoError(t, err)
+		require.NoError(t, ds.Put(auditKey(tm, uint64(i)), b))
+	}
+
+	all, err := aw.WalletAuditQuery(ctx, api.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, all, len(times))
+	for i, e := range all {
+		require.True(t, e.Time.Equal(times[i]))
+	}
+
+	got, err := aw.WalletAuditQuery(ctx, api.AuditFilter{Since: times[3], Until: times[9]})
+	require.NoError(t, err)
+	require.Len(t, got, 6)
+	require.True(t, got[0].Time.Equal(times[3]))
+	require.True(t, got[5].Time.Equal(times[8]))
+
+	got, err = aw.WalletAuditQuery(ctx, api.AuditFilter{Since: times[3], Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.True(t, got[1].Time.Equal(times[11]))
+
+	n, err := aw.Prune(ctx, 48*time.Hour+time.Minute, 0)
+	require.NoError(t, err)
+	require.Equal(t, 4, n)
+
+	n, err = aw.Prune(ctx, 0, 3)
+	require.NoError(t, err)
+	require.Equal(t, 5, n)
+
+	left, err := aw.WalletAuditQuery(ctx, api.AuditFilter{Since: start})
+	require.NoError(t, err)
+	require.Len(t, left, 3)
+	require.True(t, left[0].Time.Equal(times[9]))
+}
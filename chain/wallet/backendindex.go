@@ -0,0 +1,196 @@
+package wallet
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+const dsBackendIndexPrefix = "/backend-index/"
+
+// confirmedTTL is how long a backend is trusted to still hold an address
+// after it last confirmed it, without asking it again
+const confirmedTTL = 30 * time.Second
+
+// IndexedKey records which backend last served an address
+type IndexedKey struct {
+	Backend string
+	Type    types.KeyType `json:",omitempty"`
+	Seen    time.Time
+}
+
+// BackendIndex remembers the backend holding each address across restarts,
+// so that requests go straight to that backend instead of probing slow
+// backends (ledger, remote) first. Entries are hints: a backend which no
+// longer has the key is skipped and the address looked up again. A nil
+// *BackendIndex remembers nothing.
+type BackendIndex struct {
+	ds datastore.Datastore
+
+	lk   sync.RWMutex
+	keys map[address.Address]IndexedKey
+	// when each address was last confirmed by its backend, not persisted
+	confirmed map[address.Address]time.Time
+}
+
+// NewBackendIndex loads the index from the datastore
+func NewBackendIndex(ds datastore.Datastore) (*BackendIndex, error) {
+	res, err := ds.Query(query.Query{Prefix: dsBackendIndexPrefix})
+	if err != nil {
+		return nil, xerrors.Errorf("querying backend index: %w", err)
+	}
+	defer res.Close() //nolint:errcheck
+
+	keys := map[address.Address]IndexedKey{}
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, xerrors.Errorf("iterating backend index: %w", r.Error)
+		}
+
+		a, err := address.NewFromString(strings.TrimPrefix(r.Key, dsBackendIndexPrefix))
+		if err != nil {
+			log.Warnw("skipping invalid backend index entry", "key", r.Key, "error", err)
+			continue
+		}
+
+		var k IndexedKey
+		if err := json.Unmarshal(r.Value, &k); err != nil {
+			return nil, xerrors.Errorf("unmarshaling backend index entry %s: %w", r.Key, err)
+		}
+		keys[a] = k
+	}
+
+	return &BackendIndex{
+		ds:        ds,
+		keys:      keys,
+		confirmed: map[address.Address]time.Time{},
+	}, nil
+}
+
+func backendIndexKey(addr address.Address) datastore.Key {
+	return datastore.NewKey(dsBackendIndexPrefix + addr.String())
+}
+
+// Lookup returns the backend which last served the address
+func (x *BackendIndex) Lookup(addr address.Address) (IndexedKey, bool) {
+	if x == nil {
+		return IndexedKey{}, false
+	}
+
+	x.lk.RLock()
+	defer x.lk.RUnlock()
+
+	k, ok := x.keys[addr]
+	return k, ok
+}
+
+// recent returns the backend which confirmed holding the address within the
+// last confirmedTTL
+func (x *BackendIndex) recent(addr address.Address) (string, bool) {
+	if x == nil {
+		return "", false
+	}
+
+	x.lk.RLock()
+	defer x.lk.RUnlock()
+
+	t, ok := x.confirmed[addr]
+	if !ok || time.Since(t) > confirmedTTL {
+		return "", false
+	}
+	return x.keys[addr].Backend, true
+}
+
+// put records the backend of an address. The datastore is only written when
+// the backend changes, or the last write is over a day old.
+func (x *BackendIndex) put(addr address.Address, backend string) {
+	if x == nil {
+		return
+	}
+
+	x.lk.Lock()
+	defer x.lk.Unlock()
+
+	now := time.Now()
+	if old, ok := x.keys[addr]; ok && old.Backend == backend && now.Sub(old.Seen) < 24*time.Hour {
+		x.confirmed[addr] = now
+		return
+	}
+
+	k := IndexedKey{
+		Backend: backend,
+		Type:    addrKeyType(addr, backend),
+		Seen:    now,
+	}
+
+	b, err := json.Marshal(k)
+	if err != nil {
+		log.Errorw("marshaling backend index entry", "address", addr, "error", err)
+		return
+	}
+	if err := x.ds.Put(backendIndexKey(addr), b); err != nil {
+		log.Warnw("writing backend index entry", "address", addr, "error", err)
+		return
+	}
+	x.keys[addr] = k
+	x.confirmed[addr] = now
+}
+
+func (x *BackendIndex) remove(addr address.Address) {
+	if x == nil {
+		return
+	}
+
+	x.lk.Lock()
+	defer x.lk.Unlock()
+
+	delete(x.confirmed, addr)
+	if _, ok := x.keys[addr]; !ok {
+		return
+	}
+	if err := x.ds.Delete(backendIndexKey(addr)); err != nil {
+		log.Warnw("removing backend index entry", "address", addr, "error", err)
+		return
+	}
+	delete(x.keys, addr)
+}
+
+// Counts returns the number of indexed addresses per backend
+func (x *BackendIndex) Counts() map[string]int {
+	out := map[string]int{}
+	if x == nil {
+		return out
+	}
+
+	x.lk.RLock()
+	defer x.lk.RUnlock()
+
+	for _, k := range x.keys {
+		out[k.Backend]++
+	}
+	return out
+}
+
+// addrKeyType guesses the key type from the address protocol
+func addrKeyType(addr address.Address, backend string) types.KeyType {
+	switch addr.Protocol() {
+	case address.SECP256K1:
+		if backend == "ledger" {
+			return types.KTSecp256k1Ledger
+		}
+		return types.KTSecp256k1
+	case address.BLS:
+		return types.KTBLS
+	default:
+		return ""
+	}
+}
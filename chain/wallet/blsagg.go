@@ -0,0 +1,234 @@
+package wallet
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/lib/sigs"
+	"github.com/filecoin-project/lotus/lib/sigs/bls"
+)
+
+var dsBLSAggPrefix = "/blsagg/"
+
+// BLSAggregator collects partial BLS signatures from multiple keys over the
+// same bytes and combines them once all shares have arrived. The combined
+// signature verifies against the address of the aggregated public keys.
+type BLSAggregator struct {
+	ds datastore.Datastore
+
+	lk sync.Mutex
+}
+
+type blsAggregateState struct {
+	api.BLSAggregate
+
+	// Shares[i] was submitted by Received[i]
+	Shares []crypto.Signature
+}
+
+func NewBLSAggregator(ds datastore.Datastore) *BLSAggregator {
+	return &BLSAggregator{ds: ds}
+}
+
+func (b *BLSAggregator) WalletBLSAggregateNew(ctx context.Context, toSign []byte, signers []address.Address) (*api.BLSAggregate, error) {
+	if len(toSign) == 0 {
+		return nil, xerrors.Errorf("nothing to sign")
+	}
+	if len(signers) == 0 {
+		return nil, xerrors.Errorf("no signers given")
+	}
+
+	seen := map[address.Address]struct{}{}
+	pks := make([][]byte, 0, len(signers))
+	for _, s := range signers {
+		if s.Protocol() != address.BLS {
+			return nil, xerrors.Errorf("signer %s is not a BLS address", s)
+		}
+		if _, ok := seen[s]; ok {
+			return nil, xerrors.Errorf("duplicate signer %s", s)
+		}
+		seen[s] = struct{}{}
+		pks = append(pks, s.Payload())
+	}
+
+	pk, err := bls.AggregatePublicKeys(pks)
+	if err != nil {
+		return nil, err
+	}
+	aggAddr, err := address.NewBLSAddress(pk)
+	if err != nil {
+		return nil, xerrors.Errorf("creating aggregate address: %w", err)
+	}
+
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	st, err := b.get(toSign)
+	switch {
+	case err == nil:
+		if st.Address != aggAddr {
+			return nil, xerrors.Errorf("aggregation over these bytes already exists with a different set of signers")
+		}
+		return &st.BLSAggregate, nil
+	case xerrors.Is(err, datastore.ErrNotFound):
+	default:
+		return nil, err
+	}
+
+	st = &blsAggregateState{
+		BLSAggregate: api.BLSAggregate{
+			ToSign:  toSign,
+			Signers: signers,
+			Address: aggAddr,
+		},
+	}
+	if err := b.put(st); err != nil {
+		return nil, err
+	}
+
+	return &st.BLSAggregate, nil
+}
+
+func (b *BLSAggregator) WalletBLSAggregateSubmit(ctx context.Context, toSign []byte, signer address.Address, sig *crypto.Signature) (*api.BLSAggregate, error) {
+	if sig == nil || sig.Type != crypto.SigTypeBLS {
+		return nil, xerrors.Errorf("expected a BLS signature")
+	}
+
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	st, err := b.get(toSign)
+	if err != nil {
+		return nil, xerrors.Errorf("getting aggregation: %w", err)
+	}
+
+	expected := false
+	for _, s := range st.Signers {
+		if s == signer {
+			expected = true
+			break
+		}
+	}
+	if !expected {
+		return nil, xerrors.Errorf("%s is not a signer of this aggregation", signer)
+	}
+	for _, r := range st.Received {
+		if r == signer {
+			return nil, xerrors.Errorf("share from %s was already submitted", signer)
+		}
+	}
+
+	if err := sigs.Verify(sig, signer, toSign); err != nil {
+		return nil, xerrors.Errorf("verifying share from %s: %w", signer, err)
+	}
+
+	st.Received = append(st.Received, signer)
+	st.Shares = append(st.Shares, *sig)
+
+	if len(st.Received) == len(st.Signers) {
+		shares := make([][]byte, len(st.Shares))
+		for i, s := range st.Shares {
+			shares[i] = s.Data
+		}
+
+		agg, err := bls.AggregateSignatures(shares)
+		if err != nil {
+			return nil, err
+		}
+
+		aggSig := &crypto.Signature{
+			Type: crypto.SigTypeBLS,
+			Data: agg,
+		}
+		if err := sigs.Verify(aggSig, st.Address, toSign); err != nil {
+			return nil, xerrors.Errorf("verifying aggregated signature: %w", err)
+		}
+
+		st.Signature = aggSig
+	}
+
+	if err := b.put(st); err != nil {
+		return nil, err
+	}
+
+	return &st.BLSAggregate, nil
+}
+
+func (b *BLSAggregator) WalletBLSAggregateGet(ctx context.Context, toSign []byte) (*api.BLSAggregate, error) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	st, err := b.get(toSign)
+	if err != nil {
+		return nil, xerrors.Errorf("getting aggregation: %w", err)
+	}
+
+	return &st.BLSAggregate, nil
+}
+
+func (b *BLSAggregator) WalletBLSAggregateList(ctx context.Context) ([]api.BLSAggregate, error) {
+	res, err := b.ds.Query(query.Query{Prefix: dsBLSAggPrefix})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close() // nolint:errcheck
+
+	var out []api.BLSAggregate
+	for {
+		res, ok := res.NextSync()
+		if !ok {
+			break
+		}
+		if res.Error != nil {
+			return nil, res.Error
+		}
+
+		var st blsAggregateState
+		if err := json.Unmarshal(res.Value, &st); err != nil {
+			return nil, xerrors.Errorf("unmarshaling aggregation: %w", err)
+		}
+
+		out = append(out, st.BLSAggregate)
+	}
+
+	return out, nil
+}
+
+func (b *BLSAggregator) get(toSign []byte) (*blsAggregateState, error) {
+	v, err := b.ds.Get(blsAggKey(toSign))
+	if err != nil {
+		return nil, err
+	}
+
+	var st blsAggregateState
+	if err := json.Unmarshal(v, &st); err != nil {
+		return nil, xerrors.Errorf("unmarshaling aggregation: %w", err)
+	}
+
+	return &st, nil
+}
+
+func (b *BLSAggregator) put(st *blsAggregateState) error {
+	v, err := json.Marshal(st)
+	if err != nil {
+		return xerrors.Errorf("marshaling aggregation: %w", err)
+	}
+
+	return b.ds.Put(blsAggKey(st.ToSign), v)
+}
+
+func blsAggKey(toSign []byte) datastore.Key {
+	h := sha256.Sum256(toSign)
+	return datastore.NewKey(dsBLSAggPrefix + hex.EncodeToString(h[:]))
+}
@@ -0,0 +1,235 @@
+package wallet
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/lib/retryhint"
+	"github.com/filecoin-project/lotus/metrics"
+)
+
+const (
+	BackendOK          = "ok"
+	BackendDegraded    = "degraded"
+	BackendMaintenance = "maintenance"
+)
+
+var (
+	ErrBackendDegraded    = xerrors.New("wallet backend is degraded")
+	ErrBackendMaintenance = xerrors.New("wallet backend is in maintenance")
+)
+
+type BreakerConfig struct {
+	// Number of consecutive failures after which a backend is marked
+	// degraded, at least 1
+	Threshold int
+	// How long a degraded backend is skipped before it is probed again, must
+	// be positive
+	Cooldown time.Duration
+}
+
+// Breakers tracks failures of wallet backends. Backends which fail repeatedly
+// are marked degraded and skipped, while being probed in the background until
+// they recover. A nil *Breakers never skips anything.
+type Breakers struct {
+	cfg BreakerConfig
+
+	lk     sync.Mutex
+	states map[string]*breakerState
+}
+
+type breakerState struct {
+	failures      int
+	lastErr       error
+	degradedUntil time.Time
+	probing       bool
+
+	maintenance       bool
+	maintenanceReason string
+}
+
+func NewBreakers(cfg BreakerConfig) *Breakers {
+	return &Breakers{
+		cfg:    cfg,
+		states: map[string]*breakerState{},
+	}
+}
+
+// recordState exports the state of a backend as a metric
+func recordState(name string, st *breakerState, threshold int) {
+	var v int64
+	switch {
+	case st.maintenance:
+		v = 2
+	case st.failures >= threshold:
+		v = 1
+	}
+
+	ctx, _ := tag.New(context.Background(), tag.Upsert(metrics.WalletBackend, name))
+	stats.Record(ctx, metrics.WalletBackendState.M(v))
+}
+
+func (b *Breakers) state(name string) *breakerState {
+	st, ok := b.states[name]
+	if !ok {
+		st = &breakerState{}
+		b.states[name] = st
+	}
+	return st
+}
+
+// allow returns ErrBackendMaintenance or ErrBackendDegraded if requests to
+// the backend should be skipped, with a hint to retry after the next probe
+// for degraded backends.
+func (b *Breakers) allow(name string) error {
+	if b == nil {
+		return nil
+	}
+
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	st := b.state(name)
+	if st.maintenance {
+		if st.maintenanceReason != "" {
+			return xerrors.Errorf("%s: %w (%s)", name, ErrBackendMaintenance, st.maintenanceReason)
+		}
+		return xerrors.Errorf("%s: %w", name, ErrBackendMaintenance)
+	}
+	if st.failures >= b.cfg.Threshold {
+		after := time.Until(st.degradedUntil)
+		if after <= 0 {
+			// probe in progress
+			after = b.cfg.Cooldown
+		}
+		return retryhint.Later(after, xerrors.Errorf("%s: %w (last error: %s)", name, ErrBackendDegraded, st.lastErr))
+	}
+	return nil
+}
+
+// record updates backend state with the result of a request. When the backend
+// becomes degraded a background probe is started.
+func (b *Breakers) record(name string, w api.WalletAPI, err error) {
+	if b == nil {
+		return
+	}
+
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	st := b.state(name)
+	if err == nil {
+		st.failures = 0
+		st.lastErr = nil
+		return
+	}
+
+	st.failures++
+	st.lastErr = err
+	if st.failures < b.cfg.Threshold || st.probing {
+		return
+	}
+
+	log.Errorw("marking wallet backend as degraded", "backend", name, "failures", st.failures, "error", err)
+	ctx, _ := tag.New(context.Background(), tag.Upsert(metrics.WalletBackend, name))
+	stats.Record(ctx, metrics.WalletBackendTrips.M(1))
+	recordState(name, st, b.cfg.Threshold)
+	st.degradedUntil = time.Now().Add(b.cfg.Cooldown)
+	st.probing = true
+	go b.probe(name, w)
+}
+
+func (b *Breakers) probe(name string, w api.WalletAPI) {
+	for {
+		time.Sleep(b.cfg.Cooldown)
+
+		ctx, cancel := context.WithTimeout(context.Background(), b.cfg.Cooldown)
+		_, err := w.WalletList(ctx)
+		cancel()
+
+		b.lk.Lock()
+		st := b.state(name)
+		if err == nil {
+			log.Infow("wallet backend recovered", "backend", name)
+			st.failures = 0
+			st.lastErr = nil
+			st.probing = false
+			recordState(name, st, b.cfg.Threshold)
+			b.lk.Unlock()
+			return
+		}
+
+		log.Warnw("wallet backend still degraded", "backend", name, "error", err)
+		st.lastErr = err
+		st.degradedUntil = time.Now().Add(b.cfg.Cooldown)
+		b.lk.Unlock()
+	}
+}
+
+// SetMaintenance puts a backend into maintenance mode, or takes it out of it.
+// Requests skip backends in maintenance, failing over to other backends which
+// hold the key.
+func (b *Breakers) SetMaintenance(name string, on bool, reason string) error {
+	if b == nil {
+		return xerrors.Errorf("backend state tracking is not enabled")
+	}
+
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	st := b.state(name)
+	st.maintenance = on
+	st.maintenanceReason = ""
+	if on {
+		st.maintenanceReason = reason
+		log.Warnw("wallet backend in maintenance", "backend", name, "reason", reason)
+	} else {
+		log.Infow("wallet backend maintenance ended", "backend", name)
+	}
+	recordState(name, st, b.cfg.Threshold)
+	return nil
+}
+
+// Status returns the state of all backends seen so far.
+func (b *Breakers) Status() []api.BackendStatus {
+	if b == nil {
+		return nil
+	}
+
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	out := make([]api.BackendStatus, 0, len(b.states))
+	for name, st := range b.states {
+		bs := api.BackendStatus{
+			Name:     name,
+			State:    BackendOK,
+			Failures: st.failures,
+		}
+		if st.lastErr != nil {
+			bs.LastError = st.lastErr.Error()
+		}
+		if st.failures >= b.cfg.Threshold {
+			bs.State = BackendDegraded
+			bs.NextProbe = st.degradedUntil
+		}
+		if st.maintenance {
+			bs.State = BackendMaintenance
+			bs.MaintenanceReason = st.maintenanceReason
+		}
+		out = append(out, bs)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+
+	return out
+}
@@ -0,0 +1,117 @@
+package wallet
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/lib/retryhint"
+)
+
+// fakeBackend is a backend whose answers can be changed while it is in use
+type fakeBackend struct {
+	api.WalletAPI
+
+	lk    sync.Mutex
+	have  bool
+	err   error
+	delay time.Duration
+}
+
+func newFakeBackend(have bool, err error) *fakeBackend {
+	return &fakeBackend{have: have, err: err}
+}
+
+func (f *fakeBackend) set(have bool, err error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	f.have, f.err = have, err
+}
+
+func (f *fakeBackend) WalletHas(ctx context.Context, addr address.Address) (bool, error) {
+	f.lk.Lock()
+	have, err, delay := f.have, f.err, f.delay
+	f.lk.Unlock()
+
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+	return have, err
+}
+
+func (f *fakeBackend) WalletList(ctx context.Context) ([]address.Address, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	return nil, f.err
+}
+
+func TestBreakersDegradeAndRecover(t *testing.T) {
+	b := NewBreakers(BreakerConfig{Threshold: 2, Cooldown: 10 * time.Millisecond})
+	fb := newFakeBackend(false, xerrors.New("down"))
+
+	b.record("remote", fb, xerrors.New("down"))
+	require.NoError(t, b.allow("remote"))
+	b.record("remote", fb, xerrors.New("down"))
+
+	err := b.allow("remote")
+	require.True(t, xerrors.Is(err, ErrBackendDegraded), err)
+	_, ok := retryhint.After(err)
+	require.True(t, ok)
+	require.Equal(t, BackendDegraded, b.Status()[0].State)
+
+	// the background probe lets requests through again once the backend
+	// answers
+	fb.set(false, nil)
+	require.Eventually(t, func() bool {
+		return b.allow("remote") == nil
+	}, 5*time.Second, 5*time.Millisecond)
+
+	st := b.Status()[0]
+	require.Equal(t, BackendOK, st.State)
+	require.Equal(t, 0, st.Failures)
+	require.Empty(t, st.LastError)
+}
+
+func TestBreakersSuccessResetsFailures(t *testing.T) {
+	b := NewBreakers(BreakerConfig{Threshold: 2, Cooldown: time.Hour})
+	fb := newFakeBackend(false, nil)
+
+	b.record("remote", fb, xerrors.New("down"))
+	b.record("remote", fb, nil)
+	b.record("remote", fb, xerrors.New("down"))
+	require.NoError(t, b.allow("remote"))
+	require.Equal(t, 1, b.Status()[0].Failures)
+}
+
+func TestBreakersMaintenance(t *testing.T) {
+	b := NewBreakers(BreakerConfig{Threshold: 2, Cooldown: time.Hour})
+
+	require.NoError(t, b.SetMaintenance("ledger", true, "firmware update"))
+	err := b.allow("ledger")
+	require.True(t, xerrors.Is(err, ErrBackendMaintenance), err)
+	require.Contains(t, err.Error(), "firmware update")
+
+	st := b.Status()[0]
+	require.Equal(t, BackendMaintenance, st.State)
+	require.Equal(t, "firmware update", st.MaintenanceReason)
+
+	require.NoError(t, b.SetMaintenance("ledger", false, ""))
+	require.NoError(t, b.allow("ledger"))
+	require.Equal(t, BackendOK, b.Status()[0].State)
+
+	// without breakers nothing is skipped
+	var none *Breakers
+	require.NoError(t, none.allow("ledger"))
+	require.Error(t, none.SetMaintenance("ledger", true, ""))
+}
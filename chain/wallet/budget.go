@@ -0,0 +1,193 @@
+package wallet
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/retryhint"
+	"github.com/filecoin-project/lotus/metrics"
+)
+
+// DeadlineWindow is the wall-clock length of a WindowPoSt deadline, the
+// period over which a DeadlineBudget refills
+func DeadlineWindow() time.Duration {
+	return time.Duration(miner.WPoStChallengeWindow) * time.Duration(build.BlockDelaySecs) * time.Second
+}
+
+// combineBudgets returns a budget at least as strict as both budgets
+func combineBudgets(a, b *api.DeadlineBudget) *api.DeadlineBudget {
+	if a == nil {
+		return b
+	}
+
+	out := *a
+	if b.Messages > 0 && (out.Messages == 0 || b.Messages < out.Messages) {
+		out.Messages = b.Messages
+	}
+	if b.Gas > 0 && (out.Gas == 0 || b.Gas < out.Gas) {
+		out.Gas = b.Gas
+	}
+	out.AlertOnly = a.AlertOnly && b.AlertOnly
+	return &out
+}
+
+type budgetBucket struct {
+	msgs, gas float64
+	last      time.Time
+
+	// messages charged to the bucket in the last window, so that signing a
+	// message again isn't charged twice
+	charged map[cid.Cid]*budgetCharge
+}
+
+// budgetCharge is a message charged to a bucket
+type budgetCharge struct {
+	at  time.Time
+	gas int64
+
+	// sign requests for the message in flight, and whether one of them got
+	// signed
+	requests int
+	signed   bool
+}
+
+// deadlineBudgets holds a token bucket per signer. Buckets only live in
+// memory, so they start full when the wallet restarts.
+type deadlineBudgets struct {
+	lk      sync.Mutex
+	buckets map[address.Address]*budgetBucket
+}
+
+// refill adds tokens for the time passed since the last request, capping the
+// bucket at the budget
+func refill(tokens float64, capacity int64, elapsed, window time.Duration) float64 {
+	tokens += float64(capacity) * float64(elapsed) / float64(window)
+	if tokens > float64(capacity) {
+		tokens = float64(capacity)
+	}
+	return tokens
+}
+
+// refillTime returns how long it takes for a bucket to refill from tokens to
+// need
+func refillTime(tokens, need float64, capacity int64, window time.Duration) time.Duration {
+	return time.Duration((need - tokens) / float64(capacity) * float64(window))
+}
+
+// take consumes tokens for the message from the bucket of the signer. If the
+// budget is exceeded it fails with ErrPolicyViolation, unless the budget is
+// alert-only. The error has a retry-after hint when the bucket will refill
+// enough for the message.
+//
+// Messages charged within the last window aren't charged again. The caller
+// must call the returned function once the message is signed or signing
+// failed; the tokens are refunded when none of the requests for the message
+// got signed.
+func (d *deadlineBudgets) take(ctx context.Context, signer address.Address, b api.DeadlineBudget, msg *types.Message) (func(signed bool), error) {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+
+	if d.buckets == nil {
+		d.buckets = map[address.Address]*budgetBucket{}
+	}
+
+	now := time.Now()
+	bk, ok := d.buckets[signer]
+	if !ok {
+		bk = &budgetBucket{msgs: float64(b.Messages), gas: float64(b.Gas), last: now}
+		d.buckets[signer] = bk
+	}
+
+	window := DeadlineWindow()
+	elapsed := now.Sub(bk.last)
+	bk.msgs = refill(bk.msgs, int64(b.Messages), elapsed, window)
+	bk.gas = refill(bk.gas, b.Gas, elapsed, window)
+	bk.last = now
+
+	for c, ch := range bk.charged {
+		if ch.requests == 0 && now.Sub(ch.at) >= window {
+			delete(bk.charged, c)
+		}
+	}
+	mcid := msg.Cid()
+	if ch, ok := bk.charged[mcid]; ok {
+		ch.requests++
+		return d.chargeDone(bk, b, mcid, ch), nil
+	}
+
+	var err error
+	switch {
+	case b.Messages > 0 && bk.msgs < 1:
+		err = xerrors.Errorf("%w: deadline budget of %d messages per %s exceeded", ErrPolicyViolation, b.Messages, window)
+		err = retryhint.Later(refillTime(bk.msgs, 1, int64(b.Messages), window), err)
+	case b.Gas > 0 && bk.gas < float64(msg.GasLimit):
+		err = xerrors.Errorf("%w: gas limit %d exceeds the remaining deadline budget of %.0f gas (%d per %s)", ErrPolicyViolation, msg.GasLimit, bk.gas, b.Gas, window)
+		if msg.GasLimit <= b.Gas {
+			err = retryhint.Later(refillTime(bk.gas, float64(msg.GasLimit), b.Gas, window), err)
+		}
+	}
+
+	if err != nil {
+		if tctx, terr := tag.New(ctx, tag.Upsert(metrics.WalletSigner, signer.String())); terr == nil {
+			ctx = tctx
+		}
+		stats.Record(ctx, metrics.WalletDeadlineBudgetExceeded.M(1))
+
+		if !b.AlertOnly {
+			return nil, err
+		}
+		log.Errorw("deadline budget exceeded, signing anyway as the budget is alert-only", "signer", signer, "error", err)
+		return func(bool) {}, nil
+	}
+
+	if b.Messages > 0 {
+		bk.msgs--
+	}
+	if b.Gas > 0 {
+		bk.gas -= float64(msg.GasLimit)
+	}
+
+	if bk.charged == nil {
+		bk.charged = map[cid.Cid]*budgetCharge{}
+	}
+	ch := &budgetCharge{at: now, gas: msg.GasLimit, requests: 1}
+	bk.charged[mcid] = ch
+	return d.chargeDone(bk, b, mcid, ch), nil
+}
+
+// chargeDone returns the function a sign request calls when it is done with a
+// charge
+func (d *deadlineBudgets) chargeDone(bk *budgetBucket, b api.DeadlineBudget, mcid cid.Cid, ch *budgetCharge) func(signed bool) {
+	return func(signed bool) {
+		d.lk.Lock()
+		defer d.lk.Unlock()
+
+		ch.requests--
+		ch.signed = ch.signed || signed
+		if ch.requests > 0 || ch.signed {
+			return
+		}
+
+		// nothing got signed, give the tokens back
+		delete(bk.charged, mcid)
+		if b.Messages > 0 {
+			bk.msgs = math.Min(bk.msgs+1, float64(b.Messages))
+		}
+		if b.Gas > 0 {
+			bk.gas = math.Min(bk.gas+float64(ch.gas), float64(b.Gas))
+		}
+	}
+}
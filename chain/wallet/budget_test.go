@@ -0,0 +1,72 @@
+package wallet
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+func TestDeadlineBudgetConcurrentSigns(t *testing.T) {
+	ctx := context.Background()
+	pw, signer := newTestPolicyWallet(t)
+
+	require.NoError(t, pw.WalletPolicySet(ctx, signer, &api.SigningPolicy{
+		DeadlineBudget: &api.DeadlineBudget{Messages: 5},
+	}))
+
+	const n = 20
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		msg := testMessage(t, signer, 1)
+		msg.Nonce = uint64(i)
+		meta := chainMsgMeta(t, msg)
+		go func() {
+			_, err := pw.WalletSign(ctx, signer, msg.Cid().Bytes(), meta)
+			errs <- err
+		}()
+	}
+
+	signed := 0
+	for i := 0; i < n; i++ {
+		err := <-errs
+		if err == nil {
+			signed++
+			continue
+		}
+		require.True(t, xerrors.Is(err, ErrPolicyViolation), err)
+	}
+	require.Equal(t, 5, signed)
+}
+
+func TestDeadlineBudgetSameMessageChargedOnce(t *testing.T) {
+	ctx := context.Background()
+	pw, signer := newTestPolicyWallet(t)
+
+	require.NoError(t, pw.WalletPolicySet(ctx, signer, &api.SigningPolicy{
+		DeadlineBudget: &api.DeadlineBudget{Messages: 1},
+	}))
+
+	msg := testMessage(t, signer, 1)
+	meta := chainMsgMeta(t, msg)
+
+	const n = 10
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := pw.WalletSign(ctx, signer, msg.Cid().Bytes(), meta)
+			errs <- err
+		}()
+	}
+	for i := 0; i < n; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	other := testMessage(t, signer, 1)
+	other.Nonce = 1
+	_, err := pw.WalletSign(ctx, signer, other.Cid().Bytes(), chainMsgMeta(t, other))
+	require.True(t, xerrors.Is(err, ErrPolicyViolation), err)
+}
@@ -0,0 +1,54 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	mbase "github.com/multiformats/go-multibase"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+// ParseCid parses a CID in any multibase encoding. Bare hex of the binary
+// CID, as shown by some explorers, is accepted too.
+func ParseCid(s string) (cid.Cid, error) {
+	s = strings.TrimSpace(s)
+
+	c, err := cid.Decode(s)
+	if err == nil {
+		return c, nil
+	}
+
+	if b, herr := hex.DecodeString(strings.TrimPrefix(strings.ToLower(s), "0x")); herr == nil {
+		if hc, cerr := cid.Cast(b); cerr == nil {
+			return hc, nil
+		}
+	}
+
+	return cid.Undef, xerrors.Errorf("parsing cid %q (expected a multibase string like bafy.../f0171..., or hex): %w", s, err)
+}
+
+// DescribeCid returns the common encodings of a CID
+func DescribeCid(c cid.Cid) (*api.CidEncodings, error) {
+	out := &api.CidEncodings{
+		Cid:     c,
+		Version: c.Version(),
+		Codec:   cid.CodecToStr[c.Type()],
+	}
+
+	if c.Version() == 0 {
+		// v0 CIDs only have one encoding, show the equivalent v1 ones
+		c = cid.NewCidV1(c.Type(), c.Hash())
+	}
+
+	var err error
+	if out.Base32, err = c.StringOfBase(mbase.Base32); err != nil {
+		return nil, err
+	}
+	if out.Base16, err = c.StringOfBase(mbase.Base16); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,84 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/lib/sigs"
+)
+
+// FileSignature is a detached signature over the sha256 digest of a file
+type FileSignature struct {
+	Signer address.Address
+	// hex encoded sha256 digest of the file
+	SHA256    string
+	Signature *crypto.Signature
+
+	// Informational, not covered by the signature
+	Name   string    `json:",omitempty"`
+	Time   time.Time `json:",omitempty"`
+	Reason string    `json:",omitempty"`
+}
+
+// FileDigestBytes returns the bytes signed for a file digest. The prefix keeps
+// them from being valid messages, blocks or CIDs.
+func FileDigestBytes(digest []byte) []byte {
+	return []byte(fmt.Sprintf("lotus-wallet file signature\nsha256:%x", digest))
+}
+
+// FileDigest returns the sha256 digest of the reader contents
+func FileDigest(r io.Reader) ([]byte, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, xerrors.Errorf("hashing file: %w", err)
+	}
+	return h.Sum(nil), nil
+}
+
+// SignFileDigest signs a file digest with the given key, as an MTFileDigest
+// request so that signing policies apply.
+func SignFileDigest(ctx context.Context, w api.WalletAPI, signer address.Address, digest []byte, reason string) (*FileSignature, error) {
+	sig, err := w.WalletSign(ctx, signer, FileDigestBytes(digest), api.MsgMeta{
+		Type:   api.MTFileDigest,
+		Extra:  digest,
+		Reason: reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileSignature{
+		Signer:    signer,
+		SHA256:    hex.EncodeToString(digest),
+		Signature: sig,
+		Time:      time.Now(),
+		Reason:    reason,
+	}, nil
+}
+
+// VerifyFileSignature checks that fs is a valid signature over digest
+func VerifyFileSignature(fs *FileSignature, digest []byte) error {
+	sd, err := hex.DecodeString(fs.SHA256)
+	if err != nil {
+		return xerrors.Errorf("decoding signed digest: %w", err)
+	}
+	if !bytes.Equal(sd, digest) {
+		return xerrors.Errorf("file digest %x doesn't match signed digest %s", digest, fs.SHA256)
+	}
+	if fs.Signature == nil {
+		return xerrors.Errorf("no signature")
+	}
+
+	return sigs.Verify(fs.Signature, fs.Signer, FileDigestBytes(digest))
+}
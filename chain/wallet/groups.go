@@ -0,0 +1,107 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+var dsKeyGroupPrefix = "/keygroup/"
+
+func keyGroupKey(name string) datastore.Key {
+	return datastore.NewKey(dsKeyGroupPrefix + name)
+}
+
+func (p *PolicyWallet) WalletGroupSet(ctx context.Context, g api.KeyGroup) error {
+	if g.Name == "" {
+		return xerrors.Errorf("group name can't be empty")
+	}
+
+	b, err := json.Marshal(g)
+	if err != nil {
+		return xerrors.Errorf("marshaling key group: %w", err)
+	}
+
+	return p.ds.Put(keyGroupKey(g.Name), b)
+}
+
+func (p *PolicyWallet) WalletGroupGet(ctx context.Context, name string) (*api.KeyGroup, error) {
+	b, err := p.ds.Get(keyGroupKey(name))
+	if err == datastore.ErrNotFound {
+		return nil, xerrors.Errorf("key group %q not found", name)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("getting key group: %w", err)
+	}
+
+	var g api.KeyGroup
+	if err := json.Unmarshal(b, &g); err != nil {
+		return nil, xerrors.Errorf("unmarshaling key group: %w", err)
+	}
+
+	return &g, nil
+}
+
+func (p *PolicyWallet) WalletGroupRemove(ctx context.Context, name string) error {
+	return p.ds.Delete(keyGroupKey(name))
+}
+
+func (p *PolicyWallet) WalletGroupFreeze(ctx context.Context, name string, frozen bool) error {
+	g, err := p.WalletGroupGet(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	log.Warnw("changing key group freeze state", "group", name, "frozen", frozen)
+	g.Frozen = frozen
+	return p.WalletGroupSet(ctx, *g)
+}
+
+func (p *PolicyWallet) WalletGroupList(ctx context.Context) ([]api.KeyGroup, error) {
+	res, err := p.ds.Query(query.Query{Prefix: dsKeyGroupPrefix})
+	if err != nil {
+		return nil, xerrors.Errorf("querying key groups: %w", err)
+	}
+	defer res.Close() //nolint:errcheck
+
+	var out []api.KeyGroup
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, xerrors.Errorf("iterating key groups: %w", r.Error)
+		}
+
+		var g api.KeyGroup
+		if err := json.Unmarshal(r.Value, &g); err != nil {
+			return nil, xerrors.Errorf("unmarshaling key group %s: %w", r.Key, err)
+		}
+		out = append(out, g)
+	}
+
+	return out, nil
+}
+
+// groupsOf returns the key groups the address is a member of
+func (p *PolicyWallet) groupsOf(ctx context.Context, addr address.Address) ([]api.KeyGroup, error) {
+	all, err := p.WalletGroupList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []api.KeyGroup
+	for _, g := range all {
+		for _, m := range g.Members {
+			if m == addr {
+				out = append(out, g)
+				break
+			}
+		}
+	}
+	return out, nil
+}
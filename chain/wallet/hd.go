@@ -0,0 +1,256 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// KTHDSeed marks a KeyInfo holding the BIP39 seed of an HDWallet
+const KTHDSeed types.KeyType = "hd-seed"
+
+var (
+	dsHDSeedKey       = datastore.NewKey("/hd/seed")
+	dsHDAccountPrefix = "/hd/account/"
+)
+
+func hdAccountKey(addr address.Address) datastore.Key {
+	return datastore.NewKey(dsHDAccountPrefix + addr.String())
+}
+
+// HDWallet derives secp256k1 keys from a single seed, so that one backup of
+// the seed covers all accounts. The seed is kept in the datastore, sealed to
+// the wallet instance key. Derived keys are imported into the underlying
+// wallet, and the derivation path of each account is recorded along with an
+// optional label.
+type HDWallet struct {
+	under api.WalletAPI
+	ds    datastore.Datastore
+	ik    *InstanceKey
+
+	lk sync.Mutex
+}
+
+func NewHDWallet(under api.WalletAPI, ds datastore.Datastore, ik *InstanceKey) *HDWallet {
+	return &HDWallet{
+		under: under,
+		ds:    ds,
+		ik:    ik,
+	}
+}
+
+// WalletHDInit sets the seed accounts are derived from. It can only be set
+// once.
+func (h *HDWallet) WalletHDInit(ctx context.Context, mnemonic, passphrase string) error {
+	seed, err := SeedFromMnemonic(mnemonic, passphrase)
+	if err != nil {
+		return err
+	}
+
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	has, err := h.ds.Has(dsHDSeedKey)
+	if err != nil {
+		return xerrors.Errorf("checking for hd seed: %w", err)
+	}
+	if has {
+		return xerrors.Errorf("hd seed is already set")
+	}
+
+	sealed, err := WrapKey(h.ik.PublicKey(), &types.KeyInfo{Type: KTHDSeed, PrivateKey: seed})
+	if err != nil {
+		return xerrors.Errorf("sealing hd seed: %w", err)
+	}
+
+	b, err := json.Marshal(sealed)
+	if err != nil {
+		return err
+	}
+
+	log.Warnw("setting hd seed")
+	return h.ds.Put(dsHDSeedKey, b)
+}
+
+func (h *HDWallet) seed() ([]byte, error) {
+	b, err := h.ds.Get(dsHDSeedKey)
+	if err == datastore.ErrNotFound {
+		return nil, xerrors.Errorf("hd seed is not set, run 'wallet hd init' first")
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("getting hd seed: %w", err)
+	}
+
+	var sealed types.KeyInfo
+	if err := json.Unmarshal(b, &sealed); err != nil {
+		return nil, xerrors.Errorf("unmarshaling hd seed: %w", err)
+	}
+
+	ki, err := h.ik.Unwrap(&sealed)
+	if err != nil {
+		return nil, xerrors.Errorf("opening hd seed: %w", err)
+	}
+	if ki.Type != KTHDSeed {
+		return nil, xerrors.Errorf("unexpected hd seed key type %s", ki.Type)
+	}
+
+	return ki.PrivateKey, nil
+}
+
+// WalletNewHD derives the key at the given path and imports it. An empty
+// path derives the account after the highest index used on the default path
+// m/44'/461'/0'/0/<index>.
+func (h *HDWallet) WalletNewHD(ctx context.Context, path string) (address.Address, error) {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	seed, err := h.seed()
+	if err != nil {
+		return address.Undef, err
+	}
+
+	var p []uint32
+	if path == "" {
+		accts, err := h.WalletHDList(ctx)
+		if err != nil {
+			return address.Undef, err
+		}
+		p = MnemonicPath(nextHDIndex(accts))
+	} else if p, err = ParsePath(path); err != nil {
+		return address.Undef, err
+	}
+
+	k, err := KeyFromSeed(seed, p)
+	if err != nil {
+		return address.Undef, err
+	}
+
+	a, err := h.under.WalletImport(ctx, &k.KeyInfo)
+	if err != nil {
+		return address.Undef, xerrors.Errorf("importing derived key: %w", err)
+	}
+
+	acct := api.HDAccount{Address: a, Path: FormatPath(p)}
+	if old, err := h.account(a); err == nil {
+		acct.Label = old.Label
+	}
+
+	return a, h.putAccount(acct)
+}
+
+// nextHDIndex returns the index after the highest one used on the default
+// derivation path
+func nextHDIndex(accts []api.HDAccount) uint32 {
+	prefix := strings.TrimSuffix(FormatPath(MnemonicPath(0)), "0")
+
+	var next uint32
+	for _, a := range accts {
+		if !strings.HasPrefix(a.Path, prefix) {
+			continue
+		}
+		p, err := ParsePath(a.Path)
+		if err != nil || len(p) != len(MnemonicPath(0)) {
+			continue
+		}
+		if idx := p[len(p)-1]; idx >= next {
+			next = idx + 1
+		}
+	}
+	return next
+}
+
+func (h *HDWallet) account(addr address.Address) (*api.HDAccount, error) {
+	b, err := h.ds.Get(hdAccountKey(addr))
+	if err == datastore.ErrNotFound {
+		return nil, xerrors.Errorf("%s is not an hd account", addr)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("getting hd account: %w", err)
+	}
+
+	var a api.HDAccount
+	if err := json.Unmarshal(b, &a); err != nil {
+		return nil, xerrors.Errorf("unmarshaling hd account: %w", err)
+	}
+	return &a, nil
+}
+
+func (h *HDWallet) putAccount(a api.HDAccount) error {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return xerrors.Errorf("marshaling hd account: %w", err)
+	}
+	return h.ds.Put(hdAccountKey(a.Address), b)
+}
+
+// WalletHDLabel sets the label of a derived account
+func (h *HDWallet) WalletHDLabel(ctx context.Context, addr address.Address, label string) error {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	a, err := h.account(addr)
+	if err != nil {
+		return err
+	}
+
+	a.Label = label
+	return h.putAccount(*a)
+}
+
+// WalletHDList lists derived accounts, ordered by derivation path
+func (h *HDWallet) WalletHDList(ctx context.Context) ([]api.HDAccount, error) {
+	res, err := h.ds.Query(query.Query{Prefix: dsHDAccountPrefix})
+	if err != nil {
+		return nil, xerrors.Errorf("querying hd accounts: %w", err)
+	}
+	defer res.Close() //nolint:errcheck
+
+	type sorted struct {
+		acct api.HDAccount
+		path []uint32
+	}
+	var accts []sorted
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, xerrors.Errorf("iterating hd accounts: %w", r.Error)
+		}
+
+		var a api.HDAccount
+		if err := json.Unmarshal(r.Value, &a); err != nil {
+			return nil, xerrors.Errorf("unmarshaling hd account %s: %w", r.Key, err)
+		}
+
+		p, err := ParsePath(a.Path)
+		if err != nil {
+			return nil, xerrors.Errorf("hd account %s: %w", a.Address, err)
+		}
+		accts = append(accts, sorted{acct: a, path: p})
+	}
+
+	sort.Slice(accts, func(i, j int) bool {
+		pi, pj := accts[i].path, accts[j].path
+		for k := 0; k < len(pi) && k < len(pj); k++ {
+			if pi[k] != pj[k] {
+				return pi[k] < pj[k]
+			}
+		}
+		return len(pi) < len(pj)
+	})
+
+	out := make([]api.HDAccount, len(accts))
+	for i, a := range accts {
+		out[i] = a.acct
+	}
+	return out, nil
+}
@@ -0,0 +1,179 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"go.opencensus.io/stats"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/metrics"
+)
+
+var dsSigJournalPrefix = "/sigjournal/"
+
+type callerKey struct{}
+
+// WithCaller attaches a description of the party making a request (e.g. its
+// remote address) to the context, so it can be recorded with signatures.
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the caller set with WithCaller, or an empty string.
+func CallerFromContext(ctx context.Context) string {
+	c, _ := ctx.Value(callerKey{}).(string)
+	return c
+}
+
+// SignatureJournal records signatures produced over chain messages, keyed by
+// message CID, so that it is possible to find out whether, when, and for whom
+// a message was signed.
+type SignatureJournal struct {
+	api.WalletAPI
+
+	ds datastore.Datastore
+}
+
+func NewSignatureJournal(under api.WalletAPI, ds datastore.Datastore) *SignatureJournal {
+	return &SignatureJournal{
+		WalletAPI: under,
+		ds:        ds,
+	}
+}
+
+func (j *SignatureJournal) WalletSign(ctx context.Context, signer address.Address, toSign []byte, meta api.MsgMeta) (*crypto.Signature, error) {
+	sig, err := j.WalletAPI.WalletSign(ctx, signer, toSign, meta)
+	if err != nil || meta.Type != api.MTChainMsg {
+		return sig, err
+	}
+
+	// toSign is the message CID for chain messages
+	_, c, cerr := cid.CidFromBytes(toSign)
+	if cerr != nil {
+		log.Warnw("not journaling signature, signing bytes are not a cid", "error", cerr)
+		return sig, err
+	}
+
+	rec := api.SignatureRecord{
+		Cid:       c,
+		Signer:    signer,
+		Signature: *sig,
+		Time:      time.Now(),
+		Caller:    CallerFromContext(ctx),
+		Reason:    meta.Reason,
+	}
+	if jerr := j.put(rec); jerr != nil {
+		// the signature was already produced, don't fail the request
+		log.Errorw("journaling signature", "cid", c, "error", jerr)
+	}
+
+	return sig, nil
+}
+
+func (j *SignatureJournal) put(rec api.SignatureRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return xerrors.Errorf("marshaling signature record: %w", err)
+	}
+
+	return j.ds.Put(datastore.NewKey(dsSigJournalPrefix+rec.Cid.String()), b)
+}
+
+func (j *SignatureJournal) WalletFindSignature(ctx context.Context, c cid.Cid) (*api.SignatureRecord, error) {
+	b, err := j.ds.Get(datastore.NewKey(dsSigJournalPrefix + c.String()))
+	if err == datastore.ErrNotFound {
+		return nil, xerrors.Errorf("no signature recorded for message %s", c)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("getting signature record: %w", err)
+	}
+
+	var rec api.SignatureRecord
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return nil, xerrors.Errorf("unmarshaling signature record: %w", err)
+	}
+
+	return &rec, nil
+}
+
+// WalletJournalPrune removes records older than maxAge, and then the oldest
+// records until at most maxEntries remain. Zero values disable the respective
+// limit. Returns the number of removed records.
+func (j *SignatureJournal) WalletJournalPrune(ctx context.Context, maxAge time.Duration, maxEntries int) (int, error) {
+	res, err := j.ds.Query(query.Query{Prefix: dsSigJournalPrefix})
+	if err != nil {
+		return 0, xerrors.Errorf("querying signature journal: %w", err)
+	}
+	defer res.Close() //nolint:errcheck
+
+	type entry struct {
+		key string
+		t   time.Time
+	}
+	var entries []entry
+	for r := range res.Next() {
+		if r.Error != nil {
+			return 0, xerrors.Errorf("iterating signature journal: %w", r.Error)
+		}
+
+		var rec api.SignatureRecord
+		if err := json.Unmarshal(r.Value, &rec); err != nil {
+			log.Warnw("malformed signature record, treating as oldest", "key", r.Key, "error", err)
+		}
+		entries = append(entries, entry{key: r.Key, t: rec.Time})
+	}
+
+	sort.Slice(entries, func(i, k int) bool {
+		return entries[i].t.Before(entries[k].t)
+	})
+
+	drop := 0
+	if maxAge > 0 {
+		cutoff := time.Now().Add(-maxAge)
+		for drop < len(entries) && entries[drop].t.Before(cutoff) {
+			drop++
+		}
+	}
+	if maxEntries > 0 && len(entries)-drop > maxEntries {
+		drop = len(entries) - maxEntries
+	}
+
+	for _, e := range entries[:drop] {
+		if err := j.ds.Delete(datastore.NewKey(e.key)); err != nil {
+			return 0, xerrors.Errorf("removing signature record: %w", err)
+		}
+	}
+
+	stats.Record(ctx, metrics.WalletJournalEntries.M(int64(len(entries)-drop)), metrics.WalletJournalPruned.M(int64(drop)))
+
+	return drop, nil
+}
+
+// RunRetention prunes the journal periodically until the context is
+// cancelled.
+func (j *SignatureJournal) RunRetention(ctx context.Context, interval, maxAge time.Duration, maxEntries int) {
+	for {
+		n, err := j.WalletJournalPrune(ctx, maxAge, maxEntries)
+		if err != nil {
+			log.Errorw("pruning signature journal", "error", err)
+		} else if n > 0 {
+			log.Infow("pruned signature journal", "removed", n)
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"encoding/json"
+
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+const (
+	// KTWrapped marks a KeyInfo which PrivateKey holds another json-encoded
+	// KeyInfo, sealed to a wallet instance key
+	KTWrapped types.KeyType = "wrapped"
+
+	KTInstanceKey    types.KeyType = "x25519-instance"
+	KInstanceKeyName               = "instance-key"
+)
+
+// InstanceKey is a curve25519 keypair identifying a wallet instance. Key
+// material can be sealed to its public key so that it never exists in
+// plaintext outside of the wallet.
+type InstanceKey struct {
+	pub  [32]byte
+	priv [32]byte
+}
+
+// LoadInstanceKey loads the instance key from the keystore, generating it if
+// it doesn't exist yet.
+func LoadInstanceKey(ks types.KeyStore) (*InstanceKey, error) {
+	ki, err := ks.Get(KInstanceKeyName)
+	if err == nil {
+		if ki.Type != KTInstanceKey || len(ki.PrivateKey) != 64 {
+			return nil, xerrors.Errorf("malformed instance key in keystore")
+		}
+
+		var k InstanceKey
+		copy(k.pub[:], ki.PrivateKey[:32])
+		copy(k.priv[:], ki.PrivateKey[32:])
+		return &k, nil
+	}
+	if !xerrors.Is(err, types.ErrKeyInfoNotFound) {
+		return nil, xerrors.Errorf("getting instance key: %w", err)
+	}
+
+	pub, priv, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, xerrors.Errorf("generating instance key: %w", err)
+	}
+
+	ki = types.KeyInfo{
+		Type:       KTInstanceKey,
+		PrivateKey: append(append([]byte{}, pub[:]...), priv[:]...),
+	}
+	if err := ks.Put(KInstanceKeyName, ki); err != nil {
+		return nil, xerrors.Errorf("saving instance key: %w", err)
+	}
+
+	return &InstanceKey{pub: *pub, priv: *priv}, nil
+}
+
+// PublicKey returns the public part of the instance key.
+func (k *InstanceKey) PublicKey() []byte {
+	return append([]byte{}, k.pub[:]...)
+}
+
+// Unwrap opens a KTWrapped KeyInfo sealed to this instance key.
+func (k *InstanceKey) Unwrap(wrapped *types.KeyInfo) (*types.KeyInfo, error) {
+	if wrapped.Type != KTWrapped {
+		return nil, xerrors.Errorf("key info is not wrapped")
+	}
+
+	b, ok := box.OpenAnonymous(nil, wrapped.PrivateKey, &k.pub, &k.priv)
+	if !ok {
+		return nil, xerrors.Errorf("failed to open wrapped key; was it sealed to this wallet instance?")
+	}
+
+	var ki types.KeyInfo
+	if err := json.Unmarshal(b, &ki); err != nil {
+		return nil, xerrors.Errorf("unmarshaling wrapped key info: %w", err)
+	}
+
+	return &ki, nil
+}
+
+// WrapKey seals a KeyInfo to the given instance public key.
+func WrapKey(instancePub []byte, ki *types.KeyInfo) (*types.KeyInfo, error) {
+	if len(instancePub) != 32 {
+		return nil, xerrors.Errorf("expected 32 byte instance public key, got %d", len(instancePub))
+	}
+	var pub [32]byte
+	copy(pub[:], instancePub)
+
+	b, err := json.Marshal(ki)
+	if err != nil {
+		return nil, xerrors.Errorf("marshaling key info: %w", err)
+	}
+
+	sealed, err := box.SealAnonymous(nil, b, &pub, rand.Reader)
+	if err != nil {
+		return nil, xerrors.Errorf("sealing key info: %w", err)
+	}
+
+	return &types.KeyInfo{
+		Type:       KTWrapped,
+		PrivateKey: sealed,
+	}, nil
+}
@@ -0,0 +1,49 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func TestWrapKeyRoundTrip(t *testing.T) {
+	ks := NewMemKeyStore()
+	ik, err := LoadInstanceKey(ks)
+	require.NoError(t, err)
+
+	// the instance key is kept across loads
+	again, err := LoadInstanceKey(ks)
+	require.NoError(t, err)
+	require.Equal(t, ik.PublicKey(), again.PublicKey())
+
+	ki := &types.KeyInfo{Type: types.KTSecp256k1, PrivateKey: []byte("not a real key, but 32 bytes ..")}
+	wrapped, err := WrapKey(ik.PublicKey(), ki)
+	require.NoError(t, err)
+	require.Equal(t, KTWrapped, wrapped.Type)
+	require.NotContains(t, string(wrapped.PrivateKey), string(ki.PrivateKey))
+
+	unwrapped, err := again.Unwrap(wrapped)
+	require.NoError(t, err)
+	require.Equal(t, ki, unwrapped)
+}
+
+func TestUnwrapOtherInstance(t *testing.T) {
+	ik, err := LoadInstanceKey(NewMemKeyStore())
+	require.NoError(t, err)
+	other, err := LoadInstanceKey(NewMemKeyStore())
+	require.NoError(t, err)
+
+	wrapped, err := WrapKey(other.PublicKey(), &types.KeyInfo{Type: types.KTBLS, PrivateKey: make([]byte, 32)})
+	require.NoError(t, err)
+
+	_, err = ik.Unwrap(wrapped)
+	require.Error(t, err)
+
+	_, err = ik.Unwrap(&types.KeyInfo{Type: types.KTBLS, PrivateKey: make([]byte, 32)})
+	require.Error(t, err)
+
+	_, err = WrapKey([]byte("short"), &types.KeyInfo{})
+	require.Error(t, err)
+}
@@ -0,0 +1,38 @@
+// +build !noledger
+
+package ledgerwallet
+
+import (
+	ledgerfil "github.com/whyrusleeping/ledger-filecoin-go"
+)
+
+// device is a connection to the Filecoin app on a ledger
+type device interface {
+	GetAddressPubKeySECP256K1(path []uint32) (pubkey []byte, addrByte []byte, addrString string, err error)
+	// ShowAddressPubKeySECP256K1 also displays the address on the device
+	ShowAddressPubKeySECP256K1(path []uint32) (pubkey []byte, addrByte []byte, addrString string, err error)
+	// SignSECP256K1 returns a 65 byte R|S|V signature
+	SignSECP256K1(path []uint32, msg []byte) ([]byte, error)
+	Close() error
+}
+
+// hidDevice is a ledger connected over USB
+type hidDevice struct {
+	*ledgerfil.LedgerFilecoin
+}
+
+func openHID() (device, error) {
+	fl, err := ledgerfil.FindLedgerFilecoinApp()
+	if err != nil {
+		return nil, err
+	}
+	return hidDevice{fl}, nil
+}
+
+func (d hidDevice) SignSECP256K1(path []uint32, msg []byte) ([]byte, error) {
+	sig, err := d.LedgerFilecoin.SignSECP256K1(path, msg)
+	if err != nil {
+		return nil, err
+	}
+	return sig.SignatureBytes(), nil
+}
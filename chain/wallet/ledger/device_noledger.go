@@ -0,0 +1,23 @@
+// +build noledger
+
+package ledgerwallet
+
+import (
+	"golang.org/x/xerrors"
+)
+
+// device is a connection to the Filecoin app on a ledger
+type device interface {
+	GetAddressPubKeySECP256K1(path []uint32) (pubkey []byte, addrByte []byte, addrString string, err error)
+	// ShowAddressPubKeySECP256K1 also displays the address on the device
+	ShowAddressPubKeySECP256K1(path []uint32) (pubkey []byte, addrByte []byte, addrString string, err error)
+	// SignSECP256K1 returns a 65 byte R|S|V signature
+	SignSECP256K1(path []uint32, msg []byte) ([]byte, error)
+	Close() error
+}
+
+// openHID fails in builds without USB ledger support. The Speculos emulator
+// still works, as it is reached over TCP.
+func openHID() (device, error) {
+	return nil, xerrors.Errorf("ledger devices are not supported by this build (built with the noledger tag)")
+}
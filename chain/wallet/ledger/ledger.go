@@ -10,7 +10,7 @@ import (
 	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/query"
 	logging "github.com/ipfs/go-log"
-	ledgerfil "github.com/whyrusleeping/ledger-filecoin-go"
+	"go.opencensus.io/trace"
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-address"
@@ -24,11 +24,12 @@ import (
 var log = logging.Logger("wallet-ledger")
 
 type LedgerWallet struct {
-	ds datastore.Datastore
+	ds   datastore.Datastore
+	open func() (device, error)
 }
 
 func NewWallet(ds dtypes.MetadataDS) *LedgerWallet {
-	return &LedgerWallet{ds}
+	return &LedgerWallet{ds: ds, open: openHID}
 }
 
 type LedgerKeyInfo struct {
@@ -44,7 +45,9 @@ func (lw LedgerWallet) WalletSign(ctx context.Context, signer address.Address, t
 		return nil, err
 	}
 
-	fl, err := ledgerfil.FindLedgerFilecoinApp()
+	_, span := trace.StartSpan(ctx, "LedgerWallet.findDevice")
+	fl, err := lw.open()
+	span.End()
 	if err != nil {
 		return nil, err
 	}
@@ -69,14 +72,17 @@ func (lw LedgerWallet) WalletSign(ctx context.Context, signer address.Address, t
 		}
 	}
 
+	// waits for the user to confirm on the device
+	_, span = trace.StartSpan(ctx, "LedgerWallet.deviceSign")
 	sig, err := fl.SignSECP256K1(ki.Path, meta.Extra)
+	span.End()
 	if err != nil {
 		return nil, err
 	}
 
 	return &crypto.Signature{
 		Type: crypto.SigTypeSecp256k1,
-		Data: sig.SignatureBytes(),
+		Data: sig,
 	}, nil
 }
 
@@ -197,7 +203,7 @@ func (lw LedgerWallet) WalletNew(ctx context.Context, t types.KeyType) (address.
 		}
 	}
 
-	fl, err := ledgerfil.FindLedgerFilecoinApp()
+	fl, err := lw.open()
 	if err != nil {
 		return address.Undef, xerrors.Errorf("finding ledger: %w", err)
 	}
@@ -227,6 +233,42 @@ func (lw LedgerWallet) WalletNew(ctx context.Context, t types.KeyType) (address.
 	return lw.importKey(lki)
 }
 
+// ShowAddress displays the address on the device screen, so the user can
+// check it against the address shown by the host. Fails if the device derives
+// a different address for the stored path, e.g. because a different device or
+// seed is connected.
+func (lw LedgerWallet) ShowAddress(ctx context.Context, addr address.Address) error {
+	ki, err := lw.getKeyInfo(addr)
+	if err == datastore.ErrNotFound {
+		return xerrors.Errorf("%s is not a ledger address", addr)
+	}
+	if err != nil {
+		return err
+	}
+
+	fl, err := lw.open()
+	if err != nil {
+		return xerrors.Errorf("finding ledger: %w", err)
+	}
+	defer fl.Close() // nolint:errcheck
+
+	log.Infof("showing address %s on ledger device", addr)
+	_, _, shown, err := fl.ShowAddressPubKeySECP256K1(ki.Path)
+	if err != nil {
+		return xerrors.Errorf("showing address on ledger: %w", err)
+	}
+
+	sa, err := address.NewFromString(shown)
+	if err != nil {
+		return xerrors.Errorf("parsing address from ledger: %w", err)
+	}
+	if sa != addr {
+		return xerrors.Errorf("ledger derived address %s for %s, is the right device connected?", sa, addr)
+	}
+
+	return nil
+}
+
 func (lw *LedgerWallet) Get() api.WalletAPI {
 	if lw == nil {
 		return nil
@@ -240,3 +282,19 @@ var dsLedgerPrefix = "/ledgerkey/"
 func keyForAddr(addr address.Address) datastore.Key {
 	return datastore.NewKey(dsLedgerPrefix + addr.String())
 }
+
+// CheckDevice makes sure a ledger with the Filecoin app open is connected.
+func CheckDevice() error {
+	return NewWallet(nil).CheckDevice()
+}
+
+// CheckDevice makes sure the device of this wallet is reachable, with the
+// Filecoin app open.
+func (lw LedgerWallet) CheckDevice() error {
+	fl, err := lw.open()
+	if err != nil {
+		return xerrors.Errorf("finding ledger: %w", err)
+	}
+
+	return fl.Close()
+}
@@ -0,0 +1,182 @@
+package ledgerwallet
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"time"
+
+	ledgerfil "github.com/whyrusleeping/ledger-filecoin-go"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
+)
+
+const (
+	speculosDialTimeout = 5 * time.Second
+	// chunk size of messages sent for signing, as used by ledger-filecoin-go
+	speculosChunkSize = 250
+	apduOK            = 0x9000
+)
+
+// NewSpeculosWallet returns a ledger wallet talking to the Filecoin app
+// running in the Speculos emulator, which listens for APDUs on addr
+// (host:port). It only exists to exercise the ledger code paths in automated
+// tests, never use it for keys holding funds.
+func NewSpeculosWallet(ds dtypes.MetadataDS, addr string) *LedgerWallet {
+	return &LedgerWallet{
+		ds: ds,
+		open: func() (device, error) {
+			d, err := dialSpeculos(addr)
+			if err != nil {
+				return nil, err
+			}
+			return d, nil
+		},
+	}
+}
+
+// speculosDevice exchanges APDUs with Speculos over TCP. Commands are sent
+// prefixed with their 4 byte big endian length. Responses are prefixed with
+// the length of the data, and followed by the 2 byte status word.
+type speculosDevice struct {
+	conn net.Conn
+}
+
+func dialSpeculos(addr string) (*speculosDevice, error) {
+	conn, err := net.DialTimeout("tcp", addr, speculosDialTimeout)
+	if err != nil {
+		return nil, xerrors.Errorf("connecting to speculos: %w", err)
+	}
+	d := &speculosDevice{conn: conn}
+
+	resp, err := d.exchange([]byte{ledgerfil.CLA, ledgerfil.INSGetVersion, 0, 0, 0})
+	if err != nil {
+		_ = conn.Close()
+		return nil, xerrors.Errorf("getting app version, is the Filecoin app loaded?: %w", err)
+	}
+	if len(resp) < 4 {
+		_ = conn.Close()
+		return nil, xerrors.Errorf("invalid version response")
+	}
+
+	ver := ledgerfil.VersionInfo{AppMode: resp[0], Major: resp[1], Minor: resp[2], Patch: resp[3]}
+	if err := ledgerfil.CheckVersion(ver, ledgerfil.VersionInfo{Major: 0, Minor: 3, Patch: 0}); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (d *speculosDevice) exchange(apdu []byte) ([]byte, error) {
+	msg := make([]byte, 4+len(apdu))
+	binary.BigEndian.PutUint32(msg, uint32(len(apdu)))
+	copy(msg[4:], apdu)
+	if _, err := d.conn.Write(msg); err != nil {
+		return nil, xerrors.Errorf("sending apdu: %w", err)
+	}
+
+	var hdr [4]byte
+	if _, err := io.ReadFull(d.conn, hdr[:]); err != nil {
+		return nil, xerrors.Errorf("reading response length: %w", err)
+	}
+
+	resp := make([]byte, binary.BigEndian.Uint32(hdr[:])+2)
+	if _, err := io.ReadFull(d.conn, resp); err != nil {
+		return nil, xerrors.Errorf("reading response: %w", err)
+	}
+
+	data, sw := resp[:len(resp)-2], binary.BigEndian.Uint16(resp[len(resp)-2:])
+	if sw != apduOK {
+		return data, xerrors.Errorf("apdu failed with status %#04x", sw)
+	}
+	return data, nil
+}
+
+func (d *speculosDevice) address(path []uint32, show bool) ([]byte, []byte, string, error) {
+	pathBytes, err := ledgerfil.GetBip44bytes(path, ledgerfil.HardenCount)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	var p1 byte
+	if show {
+		p1 = 1
+	}
+
+	resp, err := d.exchange(append([]byte{ledgerfil.CLA, ledgerfil.INSGetAddrSECP256K1, p1, 0, byte(len(pathBytes))}, pathBytes...))
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	// pubkey (65) | len | address bytes | len | address string
+	const pubkeyLen = 65
+	if len(resp) < pubkeyLen+1 {
+		return nil, nil, "", xerrors.Errorf("invalid address response")
+	}
+	pubkey, rest := resp[:pubkeyLen], resp[pubkeyLen:]
+
+	abLen := int(rest[0])
+	if len(rest) < 1+abLen+1 {
+		return nil, nil, "", xerrors.Errorf("invalid address response")
+	}
+	addrByte, rest := rest[1:1+abLen], rest[1+abLen:]
+
+	asLen := int(rest[0])
+	if len(rest) < 1+asLen {
+		return nil, nil, "", xerrors.Errorf("invalid address response")
+	}
+
+	return pubkey, addrByte, string(rest[1 : 1+asLen]), nil
+}
+
+func (d *speculosDevice) GetAddressPubKeySECP256K1(path []uint32) ([]byte, []byte, string, error) {
+	return d.address(path, false)
+}
+
+func (d *speculosDevice) ShowAddressPubKeySECP256K1(path []uint32) ([]byte, []byte, string, error) {
+	return d.address(path, true)
+}
+
+func (d *speculosDevice) SignSECP256K1(path []uint32, msg []byte) ([]byte, error) {
+	pathBytes, err := ledgerfil.GetBip44bytes(path, ledgerfil.HardenCount)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := [][]byte{pathBytes}
+	for len(msg) > 0 {
+		n := speculosChunkSize
+		if n > len(msg) {
+			n = len(msg)
+		}
+		chunks = append(chunks, msg[:n])
+		msg = msg[n:]
+	}
+
+	var resp []byte
+	for i, c := range chunks {
+		p1 := byte(ledgerfil.PayloadChunkAdd)
+		switch {
+		case i == 0:
+			p1 = ledgerfil.PayloadChunkInit
+		case i == len(chunks)-1:
+			p1 = ledgerfil.PayloadChunkLast
+		}
+
+		if resp, err = d.exchange(append([]byte{ledgerfil.CLA, ledgerfil.INSSignSECP256K1, p1, 0, byte(len(c))}, c...)); err != nil {
+			return nil, xerrors.Errorf("signing: %w", err)
+		}
+	}
+
+	// R (32) | S (32) | V (1) | DER signature
+	if len(resp) < 66 {
+		return nil, xerrors.Errorf("signature response too short")
+	}
+	return resp[:65], nil
+}
+
+func (d *speculosDevice) Close() error {
+	return d.conn.Close()
+}
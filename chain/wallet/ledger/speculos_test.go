@@ -0,0 +1,62 @@
+package ledgerwallet
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/sigs"
+	_ "github.com/filecoin-project/lotus/lib/sigs/secp"
+)
+
+// TestSpeculos runs against the Filecoin app in a Speculos emulator started
+// with automation rules which approve all prompts, e.g.
+//
+//	speculos --model nanos --automation file:approve.json --apdu-port 9999 filecoin.elf
+//	LOTUS_TEST_SPECULOS=127.0.0.1:9999 go test ./chain/wallet/ledger/
+func TestSpeculos(t *testing.T) {
+	addr := os.Getenv("LOTUS_TEST_SPECULOS")
+	if addr == "" {
+		t.Skip("set LOTUS_TEST_SPECULOS to the apdu address of a speculos emulator to run")
+	}
+
+	ctx := context.Background()
+	lw := NewSpeculosWallet(dssync.MutexWrap(datastore.NewMapDatastore()), addr)
+	require.NoError(t, lw.CheckDevice())
+
+	a, err := lw.WalletNew(ctx, types.KTSecp256k1Ledger)
+	require.NoError(t, err)
+
+	l, err := lw.WalletList(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []address.Address{a}, l)
+
+	require.NoError(t, lw.ShowAddress(ctx, a))
+
+	to, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+
+	msg := &types.Message{
+		To:         to,
+		From:       a,
+		Value:      types.FromFil(1),
+		GasLimit:   1000000,
+		GasFeeCap:  big.NewInt(100000),
+		GasPremium: big.NewInt(1000),
+	}
+	mb, err := msg.Serialize()
+	require.NoError(t, err)
+
+	sig, err := lw.WalletSign(ctx, a, msg.Cid().Bytes(), api.MsgMeta{Type: api.MTChainMsg, Extra: mb})
+	require.NoError(t, err)
+	require.NoError(t, sigs.Verify(sig, a, msg.Cid().Bytes()))
+}
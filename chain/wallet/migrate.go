@@ -0,0 +1,200 @@
+package wallet
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/lib/backupds"
+)
+
+// RepoVersion is the layout version of the wallet metadata datastore
+// (journal, audit log, policies, approvals, ...) this build reads and writes.
+const RepoVersion = 1
+
+var dsVersionKey = datastore.NewKey("/version")
+
+// Migration upgrades the metadata datastore from version To-1 to To
+type Migration struct {
+	To   int
+	Name string
+	Up   func(ds datastore.Batching) error
+}
+
+// migrations must be ordered by version, with one entry per version up to
+// RepoVersion
+var migrations = []Migration{
+	{
+		// all layouts up to this version were created before the version
+		// marker existed, so there is nothing to convert
+		To:   1,
+		Name: "add repo version marker",
+		Up:   func(datastore.Batching) error { return nil },
+	},
+}
+
+// GetRepoVersion returns the layout version of the datastore. Datastores
+// created before versioning was introduced are at version 0.
+func GetRepoVersion(ds datastore.Batching) (int, error) {
+	b, err := ds.Get(dsVersionKey)
+	if err == datastore.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, xerrors.Errorf("getting repo version: %w", err)
+	}
+
+	v, err := strconv.Atoi(string(b))
+	if err != nil {
+		return 0, xerrors.Errorf("parsing repo version %q: %w", string(b), err)
+	}
+	return v, nil
+}
+
+func setRepoVersion(ds datastore.Batching, v int) error {
+	return ds.Put(dsVersionKey, []byte(strconv.Itoa(v)))
+}
+
+// MigrateRepo upgrades the datastore to RepoVersion. Before running any
+// migration the datastore is backed up into backupDir, and if a migration
+// fails the datastore is restored from that backup. The path of the backup is
+// returned, or an empty string if nothing had to be migrated.
+func MigrateRepo(ds datastore.Batching, backupDir string) (string, error) {
+	from, err := GetRepoVersion(ds)
+	if err != nil {
+		return "", err
+	}
+
+	if from > RepoVersion {
+		return "", xerrors.Errorf("repo version %d is newer than the version supported by this build (%d), upgrade lotus-wallet or roll back to a backup", from, RepoVersion)
+	}
+	if from == RepoVersion {
+		return "", nil
+	}
+
+	empty, err := isEmpty(ds)
+	if err != nil {
+		return "", err
+	}
+	if empty {
+		// fresh repo, nothing to convert
+		return "", setRepoVersion(ds, RepoVersion)
+	}
+
+	backup, err := BackupRepo(ds, backupDir, from)
+	if err != nil {
+		return "", xerrors.Errorf("backing up repo before migration: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.To <= from {
+			continue
+		}
+
+		log.Infow("migrating wallet repo", "to", m.To, "migration", m.Name)
+		if err := m.Up(ds); err != nil {
+			if rerr := RestoreRepo(ds, backup); rerr != nil {
+				return backup, xerrors.Errorf("migration to version %d (%s) failed (%s), rolling back from %s also failed: %w", m.To, m.Name, err, backup, rerr)
+			}
+			return backup, xerrors.Errorf("migration to version %d (%s) failed, rolled back to version %d: %w", m.To, m.Name, from, err)
+		}
+
+		if err := setRepoVersion(ds, m.To); err != nil {
+			return backup, xerrors.Errorf("setting repo version %d: %w", m.To, err)
+		}
+	}
+
+	return backup, nil
+}
+
+// BackupRepo writes a backup of the datastore into dir, named after the
+// version it is at.
+func BackupRepo(ds datastore.Batching, dir string, version int) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", xerrors.Errorf("creating backup dir: %w", err)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("metadata-v%d-%d.cbor", version, time.Now().Unix()))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", xerrors.Errorf("creating backup file: %w", err)
+	}
+
+	if err := backupds.Wrap(ds).Backup(f); err != nil {
+		_ = f.Close()
+		return "", xerrors.Errorf("writing backup: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return "", xerrors.Errorf("syncing backup: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", xerrors.Errorf("closing backup: %w", err)
+	}
+
+	return path, nil
+}
+
+// RestoreRepo replaces all datastore contents with the given backup
+func RestoreRepo(ds datastore.Batching, backup string) error {
+	f, err := os.Open(backup)
+	if err != nil {
+		return xerrors.Errorf("opening backup: %w", err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	return restoreRepoFrom(ds, f)
+}
+
+func restoreRepoFrom(ds datastore.Batching, r io.ReadSeeker) error {
+	// check the backup is readable before removing anything
+	if err := backupds.ReadBackup(r, func(datastore.Key, []byte) error { return nil }); err != nil {
+		return xerrors.Errorf("verifying backup: %w", err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	res, err := ds.Query(query.Query{KeysOnly: true})
+	if err != nil {
+		return xerrors.Errorf("querying datastore: %w", err)
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return xerrors.Errorf("listing datastore keys: %w", err)
+	}
+
+	b, err := ds.Batch()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := b.Delete(datastore.NewKey(e.Key)); err != nil {
+			return xerrors.Errorf("deleting %s: %w", e.Key, err)
+		}
+	}
+	if err := b.Commit(); err != nil {
+		return xerrors.Errorf("clearing datastore: %w", err)
+	}
+
+	return backupds.RestoreInto(r, ds)
+}
+
+func isEmpty(ds datastore.Batching) (bool, error) {
+	res, err := ds.Query(query.Query{KeysOnly: true, Limit: 1})
+	if err != nil {
+		return false, xerrors.Errorf("querying datastore: %w", err)
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return false, xerrors.Errorf("querying datastore: %w", err)
+	}
+	return len(entries) == 0, nil
+}
@@ -0,0 +1,183 @@
+package wallet
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/tyler-smith/go-bip39"
+	"golang.org/x/xerrors"
+
+	crypto "github.com/filecoin-project/go-crypto"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// FilecoinCoinType is the SLIP-0044 coin type of Filecoin
+const FilecoinCoinType = 461
+
+const hardened = uint32(0x80000000)
+
+// secp256k1 group order
+var secpN, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+
+// NewMnemonic generates a BIP39 mnemonic of 12 or 24 words.
+func NewMnemonic(words int) (string, error) {
+	var bits int
+	switch words {
+	case 12:
+		bits = 128
+	case 24:
+		bits = 256
+	default:
+		return "", xerrors.Errorf("mnemonics must have 12 or 24 words, not %d", words)
+	}
+
+	entropy, err := bip39.NewEntropy(bits)
+	if err != nil {
+		return "", xerrors.Errorf("generating entropy: %w", err)
+	}
+
+	return bip39.NewMnemonic(entropy)
+}
+
+// MnemonicPath returns the BIP44 derivation path of the index-th Filecoin
+// key, m/44'/461'/0'/0/index.
+func MnemonicPath(index uint32) []uint32 {
+	return []uint32{44 | hardened, FilecoinCoinType | hardened, 0 | hardened, 0, index}
+}
+
+// FormatPath formats a derivation path as m/44'/461'/...
+func FormatPath(path []uint32) string {
+	parts := []string{"m"}
+	for _, p := range path {
+		if p&hardened != 0 {
+			parts = append(parts, fmt.Sprintf("%d'", p&^hardened))
+		} else {
+			parts = append(parts, strconv.FormatUint(uint64(p), 10))
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// ParsePath parses a derivation path formatted like m/44'/461'/0'/0/0
+func ParsePath(s string) ([]uint32, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || parts[0] != "m" {
+		return nil, xerrors.Errorf("derivation path %q must start with m/", s)
+	}
+
+	out := make([]uint32, 0, len(parts)-1)
+	for _, p := range parts[1:] {
+		var h uint32
+		if strings.HasSuffix(p, "'") {
+			h = hardened
+			p = strings.TrimSuffix(p, "'")
+		}
+
+		i, err := strconv.ParseUint(p, 10, 31)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid derivation path element %q in %q", p, s)
+		}
+		out = append(out, uint32(i)|h)
+	}
+
+	return out, nil
+}
+
+// SeedFromMnemonic returns the BIP39 seed of a mnemonic and optional
+// passphrase.
+func SeedFromMnemonic(mnemonic, passphrase string) ([]byte, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+	if err != nil {
+		return nil, xerrors.Errorf("invalid mnemonic: %w", err)
+	}
+	return seed, nil
+}
+
+// KeyFromMnemonic derives the secp256k1 key at the given BIP32 path from a
+// BIP39 mnemonic and optional passphrase.
+func KeyFromMnemonic(mnemonic, passphrase string, path []uint32) (*Key, error) {
+	seed, err := SeedFromMnemonic(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return KeyFromSeed(seed, path)
+}
+
+// KeyFromSeed derives the secp256k1 key at the given BIP32 path from a seed
+func KeyFromSeed(seed []byte, path []uint32) (*Key, error) {
+	pk, err := deriveSecp256k1(seed, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKey(types.KeyInfo{
+		Type:       types.KTSecp256k1,
+		PrivateKey: pk,
+	})
+}
+
+// deriveSecp256k1 derives a private key following BIP32
+func deriveSecp256k1(seed []byte, path []uint32) ([]byte, error) {
+	key, chain := hmacSHA512([]byte("Bitcoin seed"), seed)
+	if !validSecpKey(key) {
+		return nil, xerrors.Errorf("seed gives an invalid master key")
+	}
+
+	for _, idx := range path {
+		var data []byte
+		if idx&hardened != 0 {
+			data = append([]byte{0}, key...)
+		} else {
+			data = compressPubkey(crypto.PublicKey(key))
+		}
+		data = append(data, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(data[len(data)-4:], idx)
+
+		il, ir := hmacSHA512(chain, data)
+		if !validSecpKey(il) {
+			return nil, xerrors.Errorf("derivation at %s gives an invalid key", FormatPath(path))
+		}
+
+		k := new(big.Int).SetBytes(il)
+		k.Add(k, new(big.Int).SetBytes(key))
+		k.Mod(k, secpN)
+		if k.Sign() == 0 {
+			return nil, xerrors.Errorf("derivation at %s gives an invalid key", FormatPath(path))
+		}
+
+		key = make([]byte, 32)
+		k.FillBytes(key)
+		chain = ir
+	}
+
+	return key, nil
+}
+
+func hmacSHA512(key, data []byte) ([]byte, []byte) {
+	h := hmac.New(sha512.New, key)
+	_, _ = h.Write(data)
+	sum := h.Sum(nil)
+	return sum[:32], sum[32:]
+}
+
+func validSecpKey(k []byte) bool {
+	n := new(big.Int).SetBytes(k)
+	return n.Sign() > 0 && n.Cmp(secpN) < 0
+}
+
+// compressPubkey compresses a 65 byte uncompressed secp256k1 public key
+func compressPubkey(pub []byte) []byte {
+	out := make([]byte, 33)
+	out[0] = 2 + pub[64]&1
+	copy(out[1:], pub[1:33])
+	return out
+}
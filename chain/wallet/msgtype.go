@@ -0,0 +1,154 @@
+package wallet
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"golang.org/x/xerrors"
+
+	market2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/market"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// MsgTypeHandler interprets sign requests of one MsgType. Handlers for types
+// not known to lotus can be registered with RegisterMsgType, so that policies,
+// the audit log and approvals understand them without changes to the signing
+// path.
+type MsgTypeHandler struct {
+	// Decode checks that toSign matches the request metadata and returns the
+	// decoded payload. When a policy applies to the signer, requests which fail
+	// to decode are refused.
+	Decode func(toSign []byte, meta api.MsgMeta) (interface{}, error)
+
+	// CheckPolicy checks a decoded payload against a signing policy, returning
+	// an error wrapping ErrPolicyViolation if it isn't allowed. Optional.
+	CheckPolicy func(pol *api.SigningPolicy, payload interface{}) error
+
+	// Describe returns a one line summary of a decoded payload, shown in the
+	// audit log and pending approvals. Optional.
+	Describe func(payload interface{}) string
+}
+
+var msgTypes = struct {
+	lk       sync.RWMutex
+	handlers map[api.MsgType]MsgTypeHandler
+}{
+	handlers: map[api.MsgType]MsgTypeHandler{},
+}
+
+// RegisterMsgType registers the handler of a MsgType. It is meant to be called
+// from init functions, and panics if the type already has a handler.
+func RegisterMsgType(t api.MsgType, h MsgTypeHandler) {
+	if h.Decode == nil {
+		panic(fmt.Sprintf("msg type %q handler has no decoder", t))
+	}
+
+	msgTypes.lk.Lock()
+	defer msgTypes.lk.Unlock()
+
+	if _, ok := msgTypes.handlers[t]; ok {
+		panic(fmt.Sprintf("msg type %q registered twice", t))
+	}
+	msgTypes.handlers[t] = h
+}
+
+// MsgTypeHandlerOf returns the handler registered for a MsgType
+func MsgTypeHandlerOf(t api.MsgType) (MsgTypeHandler, bool) {
+	msgTypes.lk.RLock()
+	defer msgTypes.lk.RUnlock()
+
+	h, ok := msgTypes.handlers[t]
+	return h, ok
+}
+
+// describeMsg returns the summary of a sign request given by its type
+// handler, or an empty string if there is none or the payload doesn't decode
+func describeMsg(toSign []byte, meta api.MsgMeta) string {
+	h, ok := MsgTypeHandlerOf(meta.Type)
+	if !ok || h.Describe == nil {
+		return ""
+	}
+
+	payload, err := h.Decode(toSign, meta)
+	if err != nil {
+		return ""
+	}
+	return h.Describe(payload)
+}
+
+// decodeBlockHeader decodes the header being signed, making sure toSign is
+// exactly its signing bytes: the canonical encoding without a signature.
+func decodeBlockHeader(toSign []byte) (*types.BlockHeader, error) {
+	bh, err := types.DecodeBlock(toSign)
+	if err != nil {
+		return nil, xerrors.Errorf("decoding block header: %w", err)
+	}
+	if bh.BlockSig != nil {
+		return nil, xerrors.Errorf("block header to sign already carries a signature")
+	}
+
+	sb, err := bh.SigningBytes()
+	if err != nil {
+		return nil, xerrors.Errorf("getting block signing bytes: %w", err)
+	}
+	if !bytes.Equal(sb, toSign) {
+		return nil, xerrors.Errorf("signing bytes aren't a canonically encoded block header")
+	}
+
+	return bh, nil
+}
+
+func init() {
+	RegisterMsgType(api.MTChainMsg, MsgTypeHandler{
+		Decode: func(toSign []byte, meta api.MsgMeta) (interface{}, error) {
+			return decodeChainMsg(toSign, meta)
+		},
+		CheckPolicy: func(pol *api.SigningPolicy, payload interface{}) error {
+			msg := payload.(*types.Message)
+			return checkMsgPolicy(pol, msg.To, &msg.Value, &msg.GasFeeCap)
+		},
+		// decoded message fields are recorded separately, no summary needed
+	})
+
+	RegisterMsgType(api.MTBlock, MsgTypeHandler{
+		Decode: func(toSign []byte, meta api.MsgMeta) (interface{}, error) {
+			return decodeBlockHeader(toSign)
+		},
+		Describe: func(payload interface{}) string {
+			bh := payload.(*types.BlockHeader)
+			return fmt.Sprintf("block at height %d by %s", bh.Height, bh.Miner)
+		},
+	})
+
+	RegisterMsgType(api.MTDealProposal, MsgTypeHandler{
+		Decode: func(toSign []byte, meta api.MsgMeta) (interface{}, error) {
+			var dp market2.DealProposal
+			if err := dp.UnmarshalCBOR(bytes.NewReader(toSign)); err != nil {
+				return nil, xerrors.Errorf("decoding deal proposal: %w", err)
+			}
+			return &dp, nil
+		},
+		Describe: func(payload interface{}) string {
+			dp := payload.(*market2.DealProposal)
+			return fmt.Sprintf("deal for %s with %s", dp.PieceCID, dp.Provider)
+		},
+	})
+
+	RegisterMsgType(api.MTFileDigest, MsgTypeHandler{
+		Decode: func(toSign []byte, meta api.MsgMeta) (interface{}, error) {
+			if len(meta.Extra) != 32 {
+				return nil, xerrors.Errorf("expected a 32 byte sha256 digest, got %d bytes", len(meta.Extra))
+			}
+			if !bytes.Equal(toSign, FileDigestBytes(meta.Extra)) {
+				return nil, xerrors.Errorf("signing bytes don't match file digest")
+			}
+			return meta.Extra, nil
+		},
+		Describe: func(payload interface{}) string {
+			return fmt.Sprintf("sha256:%x", payload)
+		},
+	})
+}
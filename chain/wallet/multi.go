@@ -2,7 +2,11 @@ package wallet
 
 import (
 	"context"
+	"sort"
 
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
 	"go.uber.org/fx"
 	"golang.org/x/xerrors"
 
@@ -13,6 +17,7 @@ import (
 	"github.com/filecoin-project/lotus/chain/types"
 	ledgerwallet "github.com/filecoin-project/lotus/chain/wallet/ledger"
 	"github.com/filecoin-project/lotus/chain/wallet/remotewallet"
+	"github.com/filecoin-project/lotus/metrics"
 )
 
 type MultiWallet struct {
@@ -21,6 +26,17 @@ type MultiWallet struct {
 	Local  *LocalWallet               `optional:"true"`
 	Remote *remotewallet.RemoteWallet `optional:"true"`
 	Ledger *ledgerwallet.LedgerWallet `optional:"true"`
+
+	// When set, backends failing repeatedly are skipped for a cool-down
+	Breakers *Breakers `optional:"true"`
+
+	// When set, addresses are looked up first on the backend which served
+	// them last
+	Index *BackendIndex `optional:"true"`
+
+	// When set, overrides the order backends are tried in, and which backend
+	// creates and imports keys
+	Routing *Router `optional:"true"`
 }
 
 type getif interface {
@@ -53,21 +69,86 @@ func nonNil(wallets ...getif) []api.WalletAPI {
 	return out
 }
 
-func (m MultiWallet) find(ctx context.Context, address address.Address, wallets ...getif) (api.WalletAPI, error) {
-	ws := nonNil(wallets...)
+func backendName(w api.WalletAPI) string {
+	switch w.(type) {
+	case *LocalWallet:
+		return "local"
+	case *remotewallet.RemoteWallet:
+		return "remote"
+	case *ledgerwallet.LedgerWallet:
+		return "ledger"
+	default:
+		return "unknown"
+	}
+}
 
+// find returns the first backend holding the key of the address. Backends
+// are asked at once, see probeHas, unless one confirmed holding the key very
+// recently. Backends which are degraded, in maintenance, or fail to answer
+// are skipped, and why is returned in skipped, so that callers can tell why
+// a key wasn't found.
+func (m MultiWallet) find(ctx context.Context, address address.Address, wallets ...getif) (w api.WalletAPI, skipped []error, err error) {
+	ws := m.Routing.lookupOrder(address, nonNil(wallets...))
+
+	if k, ok := m.Index.Lookup(address); ok {
+		for i, w := range ws {
+			if backendName(w) == k.Backend {
+				ws = append(append([]api.WalletAPI{w}, ws[:i]...), ws[i+1:]...)
+				break
+			}
+		}
+	}
+
+	var cands []hasCandidate
 	for _, w := range ws {
-		have, err := w.WalletHas(ctx, address)
-		if err != nil {
-			return nil, err
+		name := backendName(w)
+		if err := m.Breakers.allow(name); err != nil {
+			log.Debugw("skipping wallet backend", "error", err)
+			skipped = append(skipped, err)
+			continue
 		}
+		cands = append(cands, hasCandidate{name: name, w: w})
+	}
 
-		if have {
+	if recent, ok := m.Index.recent(address); ok && len(cands) > 0 && cands[0].name == recent {
+		return cands[0].w, skipped, nil
+	}
+
+	// a failing backend doesn't keep keys of other backends from being used
+	i, failed := probeHas(ctx, m.Breakers, address, cands)
+	skipped = append(skipped, failed...)
+	if i < 0 {
+		return nil, skipped, nil
+	}
+
+	m.Index.put(address, cands[i].name)
+	return cands[i].w, skipped, nil
+}
+
+// routed returns the backend configured by name to create or import a key of
+// the given type, or nil to use the default backend
+func (m MultiWallet) routed(keyType types.KeyType, addr address.Address) (api.WalletAPI, error) {
+	name := m.Routing.newKeyBackend(keyType, addr)
+	if name == "" {
+		return nil, nil
+	}
+
+	for _, w := range nonNil(m.Remote, m.Ledger, m.Local) {
+		if backendName(w) == name {
 			return w, nil
 		}
 	}
+	return nil, xerrors.Errorf("backend %s routed to for %s keys is not configured", name, keyType)
+}
 
-	return nil, nil
+// keyNotFound returns the error for a key no backend was found to hold
+func keyNotFound(skipped []error) error {
+	if len(skipped) == 0 {
+		return xerrors.Errorf("key not found")
+	}
+	// the key may well be on a skipped backend, report why it was skipped
+	// rather than a bare not found
+	return xerrors.Errorf("key not found on available backends: %w", skipped[0])
 }
 
 func (m MultiWallet) WalletNew(ctx context.Context, keyType types.KeyType) (address.Address, error) {
@@ -76,16 +157,26 @@ func (m MultiWallet) WalletNew(ctx context.Context, keyType types.KeyType) (addr
 		local = m.Ledger
 	}
 
-	w := firstNonNil(m.Remote, local)
+	w, err := m.routed(keyType, address.Undef)
+	if err != nil {
+		return address.Undef, err
+	}
+	if w == nil {
+		w = firstNonNil(m.Remote, local)
+	}
 	if w == nil {
 		return address.Undef, xerrors.Errorf("no wallet backends supporting key type: %s", keyType)
 	}
 
-	return w.WalletNew(ctx, keyType)
+	a, err := w.WalletNew(ctx, keyType)
+	if err == nil {
+		m.Index.put(a, backendName(w))
+	}
+	return a, err
 }
 
 func (m MultiWallet) WalletHas(ctx context.Context, address address.Address) (bool, error) {
-	w, err := m.find(ctx, address, m.Remote, m.Ledger, m.Local)
+	w, _, err := m.find(ctx, address, m.Remote, m.Ledger, m.Local)
 	return w != nil, err
 }
 
@@ -93,45 +184,97 @@ func (m MultiWallet) WalletList(ctx context.Context) ([]address.Address, error)
 	out := make([]address.Address, 0)
 	seen := map[address.Address]struct{}{}
 
+	// a failing backend doesn't hide the keys of the others, unless none of
+	// them answer
+	var answered int
+	var lastErr error
+
 	ws := nonNil(m.Remote, m.Ledger, m.Local)
 	for _, w := range ws {
+		name := backendName(w)
+		if err := m.Breakers.allow(name); err != nil {
+			log.Warnw("skipping wallet backend", "error", err)
+			continue
+		}
+
 		l, err := w.WalletList(ctx)
+		m.Breakers.record(name, w, err)
 		if err != nil {
-			return nil, err
+			log.Warnw("listing wallet backend failed, skipping it", "backend", name, "error", err)
+			lastErr = xerrors.Errorf("listing %s: %w", name, err)
+			continue
 		}
+		answered++
 
 		for _, a := range l {
 			if _, ok := seen[a]; ok {
 				continue
 			}
 			seen[a] = struct{}{}
+			m.Index.put(a, name)
 
 			out = append(out, a)
 		}
 	}
 
+	if answered == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+
+	// keep the order stable regardless of which backends answered
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].String() < out[j].String()
+	})
+
 	return out, nil
 }
 
 func (m MultiWallet) WalletSign(ctx context.Context, signer address.Address, toSign []byte, meta api.MsgMeta) (*crypto.Signature, error) {
-	w, err := m.find(ctx, signer, m.Remote, m.Ledger, m.Local)
+	ctx, span := trace.StartSpan(ctx, "MultiWallet.WalletSign")
+	defer span.End()
+	span.AddAttributes(trace.StringAttribute("signer", signer.String()))
+
+	fctx, fspan := trace.StartSpan(ctx, "MultiWallet.find")
+	w, skipped, err := m.find(fctx, signer, m.Remote, m.Ledger, m.Local)
+	fspan.End()
 	if err != nil {
 		return nil, err
 	}
 	if w == nil {
-		return nil, xerrors.Errorf("key not found")
+		return nil, keyNotFound(skipped)
 	}
 
-	return w.WalletSign(ctx, signer, toSign, meta)
+	name := backendName(w)
+	span.AddAttributes(trace.StringAttribute("backend", name))
+
+	ctx, _ = tag.New(ctx, tag.Upsert(metrics.WalletBackend, name))
+	stop := metrics.Timer(ctx, metrics.WalletSignDuration)
+	defer stop()
+
+	sig, err := w.WalletSign(ctx, signer, toSign, meta)
+	if err == nil && name != "local" {
+		err = verifyBackendSignature(ctx, sig, signer, toSign)
+	}
+	if err != nil {
+		span.SetStatus(trace.Status{
+			Code:    trace.StatusCodeUnknown,
+			Message: err.Error(),
+		})
+		stats.Record(ctx, metrics.WalletSignFailure.M(1))
+		return nil, err
+	}
+
+	stats.Record(ctx, metrics.WalletSign.M(1))
+	return sig, nil
 }
 
 func (m MultiWallet) WalletExport(ctx context.Context, address address.Address) (*types.KeyInfo, error) {
-	w, err := m.find(ctx, address, m.Remote, m.Local)
+	w, skipped, err := m.find(ctx, address, m.Remote, m.Local)
 	if err != nil {
 		return nil, err
 	}
 	if w == nil {
-		return nil, xerrors.Errorf("key not found")
+		return nil, keyNotFound(skipped)
 	}
 
 	return w.WalletExport(ctx, address)
@@ -143,27 +286,48 @@ func (m MultiWallet) WalletImport(ctx context.Context, info *types.KeyInfo) (add
 		local = m.Ledger
 	}
 
-	w := firstNonNil(m.Remote, local)
+	addr := address.Undef
+	if k, err := NewKey(*info); err == nil {
+		addr = k.Address
+	}
+	w, err := m.routed(info.Type, addr)
+	if err != nil {
+		return address.Undef, err
+	}
+	if w == nil {
+		w = firstNonNil(m.Remote, local)
+	}
 	if w == nil {
 		return address.Undef, xerrors.Errorf("no wallet backends configured")
 	}
 
-	return w.WalletImport(ctx, info)
+	a, err := w.WalletImport(ctx, info)
+	if err == nil {
+		m.Index.put(a, backendName(w))
+	}
+	return a, err
 }
 
 func (m MultiWallet) WalletDelete(ctx context.Context, address address.Address) error {
 	for {
-		w, err := m.find(ctx, address, m.Remote, m.Ledger, m.Local)
+		w, skipped, err := m.find(ctx, address, m.Remote, m.Ledger, m.Local)
 		if err != nil {
 			return err
 		}
 		if w == nil {
+			if len(skipped) > 0 {
+				// copies of the key may remain on skipped backends
+				return xerrors.Errorf("can't make sure the key is deleted from all backends: %w", skipped[0])
+			}
+			m.Index.remove(address)
 			return nil
 		}
 
 		if err := w.WalletDelete(ctx, address); err != nil {
 			return err
 		}
+		// look for other copies without trusting the cached answer
+		m.Index.remove(address)
 	}
 }
 
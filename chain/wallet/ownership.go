@@ -0,0 +1,33 @@
+package wallet
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/lib/sigs"
+)
+
+// OwnershipProofBytes returns the bytes signed to prove possession of the key
+// of addr at time t. The prefix keeps them from being valid messages, blocks
+// or CIDs.
+func OwnershipProofBytes(addr address.Address, t time.Time) []byte {
+	return []byte(fmt.Sprintf("lotus-wallet ownership proof\n%s\n%s", addr, t.UTC().Format(time.RFC3339)))
+}
+
+// VerifyOwnershipProof checks the proof of a listed address, and that it's not
+// older than maxAge (0 for any age).
+func VerifyOwnershipProof(oa api.OwnedAddress, maxAge time.Duration) error {
+	if oa.Proof == nil {
+		return xerrors.Errorf("no ownership proof for %s: %s", oa.Address, oa.ProofError)
+	}
+	if maxAge > 0 && time.Since(oa.ProofTime) > maxAge {
+		return xerrors.Errorf("ownership proof for %s is older than %s", oa.Address, maxAge)
+	}
+
+	return sigs.Verify(oa.Proof, oa.Address, OwnershipProofBytes(oa.Address, oa.ProofTime))
+}
@@ -0,0 +1,405 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"go.opencensus.io/trace"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+var dsPolicyPrefix = "/policy/"
+
+var ErrPolicyViolation = xerrors.New("signing policy violation")
+
+// PolicyWallet enforces per-address signing policies before passing sign
+// requests on to the underlying wallet. Addresses without a policy are not
+// restricted.
+type PolicyWallet struct {
+	api.WalletAPI
+
+	ds datastore.Datastore
+
+	spendLk       sync.Mutex
+	pendingSpends map[spendRef]*pendingSpend
+	budgets       deadlineBudgets
+}
+
+func NewPolicyWallet(under api.WalletAPI, ds datastore.Datastore) *PolicyWallet {
+	return &PolicyWallet{
+		WalletAPI: under,
+		ds:        ds,
+	}
+}
+
+func (p *PolicyWallet) WalletSign(ctx context.Context, signer address.Address, toSign []byte, meta api.MsgMeta) (*crypto.Signature, error) {
+	lim, err := p.check(ctx, signer, toSign, meta)
+	if err != nil {
+		log.Warnw("refusing to sign", "signer", signer, "type", meta.Type, "error", err)
+		return nil, xerrors.Errorf("signing with %s: %w", signer, err)
+	}
+
+	if (lim.daily == nil && lim.budget == nil) || meta.Type != api.MTChainMsg {
+		return p.WalletAPI.WalletSign(ctx, signer, toSign, meta)
+	}
+
+	msg, err := decodeChainMsg(toSign, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	// budget tokens and spend reservations are given back when the message
+	// doesn't get signed
+	var dones []func(signed bool)
+	finish := func(signed bool) {
+		for _, done := range dones {
+			done(signed)
+		}
+	}
+
+	if lim.budget != nil {
+		done, err := p.budgets.take(ctx, signer, *lim.budget, msg)
+		if err != nil {
+			log.Warnw("refusing to sign", "signer", signer, "type", meta.Type, "error", err)
+			return nil, xerrors.Errorf("signing with %s: %w", signer, err)
+		}
+		dones = append(dones, done)
+	}
+
+	if lim.daily != nil {
+		done, err := p.reserveSpend(signer, *lim.daily, msg)
+		if err != nil {
+			finish(false)
+			log.Warnw("refusing to sign", "signer", signer, "type", meta.Type, "error", err)
+			return nil, xerrors.Errorf("signing with %s: %w", signer, err)
+		}
+		dones = append(dones, done)
+	}
+
+	sig, err := p.WalletAPI.WalletSign(ctx, signer, toSign, meta)
+	finish(err == nil)
+	return sig, err
+}
+
+// policyLimits are the stateful limits which apply to a sign request
+type policyLimits struct {
+	daily  *types.BigInt
+	budget *api.DeadlineBudget
+}
+
+// check checks the request against all policies of the signer, and returns
+// the lowest daily limit and the combined deadline budget which apply, if
+// any.
+func (p *PolicyWallet) check(ctx context.Context, signer address.Address, toSign []byte, meta api.MsgMeta) (policyLimits, error) {
+	ctx, span := trace.StartSpan(ctx, "PolicyWallet.check")
+	defer span.End()
+
+	var lim policyLimits
+
+	pols, err := p.policiesOf(ctx, signer)
+	if err != nil {
+		return lim, err
+	}
+	span.AddAttributes(trace.Int64Attribute("policies", int64(len(pols))))
+
+	for _, pol := range pols {
+		if err := CheckPolicy(pol, toSign, meta); err != nil {
+			return lim, err
+		}
+
+		if pol.DailyLimit != nil && (lim.daily == nil || pol.DailyLimit.LessThan(*lim.daily)) {
+			lim.daily = pol.DailyLimit
+		}
+		if pol.DeadlineBudget != nil {
+			lim.budget = combineBudgets(lim.budget, pol.DeadlineBudget)
+		}
+	}
+
+	return lim, nil
+}
+
+// policiesOf returns all policies which apply to the address: its own policy
+// and policies of key groups it is a member of. Fails if any of the groups is
+// frozen.
+func (p *PolicyWallet) policiesOf(ctx context.Context, addr address.Address) ([]*api.SigningPolicy, error) {
+	var out []*api.SigningPolicy
+
+	pol, err := p.WalletPolicyGet(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	if pol != nil {
+		out = append(out, pol)
+	}
+
+	groups, err := p.groupsOf(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		if g.Frozen {
+			return nil, xerrors.Errorf("%w: key group %q is frozen", ErrPolicyViolation, g.Name)
+		}
+		if g.Policy != nil {
+			out = append(out, g.Policy)
+		}
+	}
+
+	return out, nil
+}
+
+// CheckPolicy returns an error wrapping ErrPolicyViolation if signing toSign is
+// not allowed by the policy.
+//
+// The bytes of types without a handler can't be checked, and could as well be
+// a chain message CID relabeled to get around the policy. Such requests are
+// only allowed when the policy lists their type and doesn't constrain chain
+// messages.
+func CheckPolicy(pol *api.SigningPolicy, toSign []byte, meta api.MsgMeta) error {
+	listed := false
+	for _, t := range pol.AllowedTypes {
+		if t == meta.Type {
+			listed = true
+			break
+		}
+	}
+	if len(pol.AllowedTypes) > 0 && !listed {
+		return xerrors.Errorf("%w: type %q not allowed", ErrPolicyViolation, meta.Type)
+	}
+
+	h, ok := MsgTypeHandlerOf(meta.Type)
+	if !ok {
+		if listed && !constrainsMessages(pol) {
+			return nil
+		}
+		return xerrors.Errorf("%w: requests of type %q can't be checked against the policy", ErrPolicyViolation, meta.Type)
+	}
+
+	payload, err := h.Decode(toSign, meta)
+	if err != nil {
+		return xerrors.Errorf("%w: %s", ErrPolicyViolation, err)
+	}
+
+	if h.CheckPolicy != nil {
+		return h.CheckPolicy(pol, payload)
+	}
+	return nil
+}
+
+// constrainsMessages returns whether the policy limits chain messages
+func constrainsMessages(pol *api.SigningPolicy) bool {
+	return len(pol.AllowedTo) > 0 || pol.MaxValue != nil || pol.MaxGasFeeCap != nil || pol.DailyLimit != nil || pol.DeadlineBudget != nil
+}
+
+// checkMsgPolicy checks chain message fields against the policy. Nil values
+// are not checked.
+func checkMsgPolicy(pol *api.SigningPolicy, to address.Address, value, feeCap *types.BigInt) error {
+	if len(pol.AllowedTo) > 0 {
+		allowed := false
+		for _, a := range pol.AllowedTo {
+			if a == to {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return xerrors.Errorf("%w: recipient %s not allowed", ErrPolicyViolation, to)
+		}
+	}
+
+	if pol.MaxValue != nil && value != nil && value.GreaterThan(*pol.MaxValue) {
+		return xerrors.Errorf("%w: value %s exceeds limit of %s", ErrPolicyViolation, types.FIL(*value), types.FIL(*pol.MaxValue))
+	}
+
+	if pol.MaxGasFeeCap != nil && feeCap != nil && feeCap.GreaterThan(*pol.MaxGasFeeCap) {
+		return xerrors.Errorf("%w: gas fee cap %s exceeds limit of %s", ErrPolicyViolation, *feeCap, *pol.MaxGasFeeCap)
+	}
+
+	return nil
+}
+
+// decodeChainMsg decodes the message in meta.Extra, making sure it is the
+// message which CID is being signed.
+func decodeChainMsg(toSign []byte, meta api.MsgMeta) (*types.Message, error) {
+	var msg types.Message
+	if err := msg.UnmarshalCBOR(bytes.NewReader(meta.Extra)); err != nil {
+		return nil, xerrors.Errorf("unmarshalling message: %w", err)
+	}
+
+	_, bc, err := cid.CidFromBytes(toSign)
+	if err != nil {
+		return nil, xerrors.Errorf("getting cid from signing bytes: %w", err)
+	}
+
+	if !msg.Cid().Equals(bc) {
+		return nil, xerrors.Errorf("cid(meta.Extra).bytes() != toSign")
+	}
+
+	return &msg, nil
+}
+
+func policyKey(addr address.Address) datastore.Key {
+	return datastore.NewKey(dsPolicyPrefix + addr.String())
+}
+
+func (p *PolicyWallet) WalletPolicySet(ctx context.Context, addr address.Address, pol *api.SigningPolicy) error {
+	if pol == nil {
+		return p.ds.Delete(policyKey(addr))
+	}
+
+	b, err := json.Marshal(pol)
+	if err != nil {
+		return xerrors.Errorf("marshaling policy: %w", err)
+	}
+
+	return p.ds.Put(policyKey(addr), b)
+}
+
+func (p *PolicyWallet) WalletPolicyGet(ctx context.Context, addr address.Address) (*api.SigningPolicy, error) {
+	b, err := p.ds.Get(policyKey(addr))
+	if err == datastore.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("getting policy: %w", err)
+	}
+
+	var pol api.SigningPolicy
+	if err := json.Unmarshal(b, &pol); err != nil {
+		return nil, xerrors.Errorf("unmarshaling policy: %w", err)
+	}
+
+	return &pol, nil
+}
+
+func (p *PolicyWallet) WalletPolicyList(ctx context.Context) ([]api.AddressPolicy, error) {
+	res, err := p.ds.Query(query.Query{Prefix: dsPolicyPrefix})
+	if err != nil {
+		return nil, xerrors.Errorf("querying policies: %w", err)
+	}
+	defer res.Close() //nolint:errcheck
+
+	var out []api.AddressPolicy
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, xerrors.Errorf("iterating policies: %w", r.Error)
+		}
+
+		addr, err := address.NewFromString(strings.TrimPrefix(r.Key, dsPolicyPrefix))
+		if err != nil {
+			return nil, xerrors.Errorf("parsing policy key %s: %w", r.Key, err)
+		}
+
+		var ap api.AddressPolicy
+		ap.Address = addr
+		if err := json.Unmarshal(r.Value, &ap.Policy); err != nil {
+			return nil, xerrors.Errorf("unmarshaling policy for %s: %w", addr, err)
+		}
+		out = append(out, ap)
+	}
+
+	return out, nil
+}
+
+type policyFile struct {
+	Policy []struct {
+		Address      string
+		AllowedTypes []string
+		AllowedTo    []string
+		MaxValue     string
+		MaxGasFeeCap string
+		DailyLimit   string
+
+		DeadlineMessages  int
+		DeadlineGas       int64
+		DeadlineAlertOnly bool
+	}
+}
+
+// LoadPolicyFile parses signing policies from a TOML file in the form of:
+//
+//	[[Policy]]
+//	Address = "f1..."
+//	AllowedTypes = ["message"]
+//	AllowedTo = ["f1..."]
+//	MaxValue = "10 FIL"
+//	MaxGasFeeCap = "100000 attoFIL"
+//	DailyLimit = "100 FIL"
+//	DeadlineMessages = 10
+//	DeadlineGas = 1000000000
+//	DeadlineAlertOnly = false
+//
+// Deadline limits are described by api.DeadlineBudget.
+func LoadPolicyFile(path string) ([]api.AddressPolicy, error) {
+	var pf policyFile
+	if _, err := toml.DecodeFile(path, &pf); err != nil {
+		return nil, xerrors.Errorf("decoding policy file: %w", err)
+	}
+
+	out := make([]api.AddressPolicy, 0, len(pf.Policy))
+	for _, fp := range pf.Policy {
+		addr, err := address.NewFromString(fp.Address)
+		if err != nil {
+			return nil, xerrors.Errorf("parsing policy address %q: %w", fp.Address, err)
+		}
+
+		ap := api.AddressPolicy{Address: addr}
+		for _, t := range fp.AllowedTypes {
+			ap.Policy.AllowedTypes = append(ap.Policy.AllowedTypes, api.MsgType(t))
+		}
+		for _, s := range fp.AllowedTo {
+			to, err := address.NewFromString(s)
+			if err != nil {
+				return nil, xerrors.Errorf("parsing allowed recipient %q of %s: %w", s, addr, err)
+			}
+			ap.Policy.AllowedTo = append(ap.Policy.AllowedTo, to)
+		}
+		if ap.Policy.MaxValue, err = parseFILLimit(fp.MaxValue); err != nil {
+			return nil, xerrors.Errorf("parsing MaxValue of %s: %w", addr, err)
+		}
+		if ap.Policy.MaxGasFeeCap, err = parseFILLimit(fp.MaxGasFeeCap); err != nil {
+			return nil, xerrors.Errorf("parsing MaxGasFeeCap of %s: %w", addr, err)
+		}
+		if ap.Policy.DailyLimit, err = parseFILLimit(fp.DailyLimit); err != nil {
+			return nil, xerrors.Errorf("parsing DailyLimit of %s: %w", addr, err)
+		}
+		if fp.DeadlineMessages > 0 || fp.DeadlineGas > 0 {
+			ap.Policy.DeadlineBudget = &api.DeadlineBudget{
+				Messages:  fp.DeadlineMessages,
+				Gas:       fp.DeadlineGas,
+				AlertOnly: fp.DeadlineAlertOnly,
+			}
+		}
+
+		out = append(out, ap)
+	}
+
+	return out, nil
+}
+
+func parseFILLimit(s string) (*types.BigInt, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	f, err := types.ParseFIL(s)
+	if err != nil {
+		return nil, err
+	}
+
+	bi := types.BigInt(f)
+	return &bi, nil
+}
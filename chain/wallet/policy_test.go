@@ -0,0 +1,93 @@
+package wallet
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func newTestPolicyWallet(t *testing.T) (*PolicyWallet, address.Address) {
+	lw, err := NewWallet(NewMemKeyStore())
+	require.NoError(t, err)
+
+	signer, err := lw.WalletNew(context.Background(), types.KTSecp256k1)
+	require.NoError(t, err)
+
+	return NewPolicyWallet(lw, dssync.MutexWrap(datastore.NewMapDatastore())), signer
+}
+
+func testMessage(t *testing.T, from address.Address, value uint64) *types.Message {
+	to, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+
+	return &types.Message{
+		From:       from,
+		To:         to,
+		Value:      types.NewInt(value),
+		GasLimit:   1000,
+		GasFeeCap:  types.NewInt(1),
+		GasPremium: types.NewInt(1),
+	}
+}
+
+func chainMsgMeta(t *testing.T, msg *types.Message) api.MsgMeta {
+	b, err := msg.Serialize()
+	require.NoError(t, err)
+	return api.MsgMeta{Type: api.MTChainMsg, Extra: b}
+}
+
+func TestPolicyRefusesRelabeledMessage(t *testing.T) {
+	ctx := context.Background()
+	pw, signer := newTestPolicyWallet(t)
+
+	maxValue := types.NewInt(10)
+	require.NoError(t, pw.WalletPolicySet(ctx, signer, &api.SigningPolicy{MaxValue: &maxValue}))
+
+	msg := testMessage(t, signer, 1000)
+
+	_, err := pw.WalletSign(ctx, signer, msg.Cid().Bytes(), chainMsgMeta(t, msg))
+	require.True(t, xerrors.Is(err, ErrPolicyViolation), err)
+
+	// the same message cid labeled as an unknown type must not get around
+	// MaxValue
+	_, err = pw.WalletSign(ctx, signer, msg.Cid().Bytes(), api.MsgMeta{Type: api.MTUnknown})
+	require.True(t, xerrors.Is(err, ErrPolicyViolation), err)
+
+	// nor labeled as a block, which must decode as a block header
+	_, err = pw.WalletSign(ctx, signer, msg.Cid().Bytes(), api.MsgMeta{Type: api.MTBlock})
+	require.True(t, xerrors.Is(err, ErrPolicyViolation), err)
+
+	small := testMessage(t, signer, 5)
+	_, err = pw.WalletSign(ctx, signer, small.Cid().Bytes(), chainMsgMeta(t, small))
+	require.NoError(t, err)
+}
+
+func TestPolicyAllowsListedUncheckedTypes(t *testing.T) {
+	ctx := context.Background()
+	pw, signer := newTestPolicyWallet(t)
+
+	require.NoError(t, pw.WalletPolicySet(ctx, signer, &api.SigningPolicy{
+		AllowedTypes: []api.MsgType{api.MTUnknown},
+	}))
+	_, err := pw.WalletSign(ctx, signer, []byte("data"), api.MsgMeta{Type: api.MTUnknown})
+	require.NoError(t, err)
+
+	// once the policy constrains messages, unchecked types are refused even
+	// when listed
+	maxValue := types.NewInt(10)
+	require.NoError(t, pw.WalletPolicySet(ctx, signer, &api.SigningPolicy{
+		AllowedTypes: []api.MsgType{api.MTUnknown, api.MTChainMsg},
+		MaxValue:     &maxValue,
+	}))
+	_, err = pw.WalletSign(ctx, signer, []byte("data"), api.MsgMeta{Type: api.MTUnknown})
+	require.True(t, xerrors.Is(err, ErrPolicyViolation), err)
+}
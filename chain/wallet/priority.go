@@ -0,0 +1,41 @@
+package wallet
+
+import (
+	"github.com/filecoin-project/go-address"
+	builtin2 "github.com/filecoin-project/specs-actors/v2/actors/builtin"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+// Sign request priorities
+const (
+	// Signatures with a chain deadline: block headers and WindowPoSt proofs.
+	// Missing them costs block rewards or faults sectors.
+	PriorityCritical = "critical"
+	// Everything else
+	PriorityBulk = "bulk"
+)
+
+// SignPriority classifies a sign request by its type and the decoded payload.
+// Block requests are critical only when they carry a block header. Messages
+// calling SubmitWindowedPoSt on an actor address are taken to be WindowPoSt
+// submissions; the actor isn't looked up, so a caller can at worst get another
+// actor's method 5 signed sooner.
+func SignPriority(toSign []byte, meta api.MsgMeta) string {
+	switch meta.Type {
+	case api.MTBlock:
+		if _, err := decodeBlockHeader(toSign); err != nil {
+			return PriorityBulk
+		}
+		return PriorityCritical
+	case api.MTChainMsg:
+		msg, err := decodeChainMsg(toSign, meta)
+		if err != nil {
+			return PriorityBulk
+		}
+		if msg.Method == builtin2.MethodsMiner.SubmitWindowedPoSt && (msg.To.Protocol() == address.ID || msg.To.Protocol() == address.Actor) {
+			return PriorityCritical
+		}
+	}
+	return PriorityBulk
+}
@@ -0,0 +1,77 @@
+package wallet
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+// backendHasTimeout bounds how long a backend may take to answer WalletHas
+// before it is skipped
+const backendHasTimeout = 10 * time.Second
+
+type hasCandidate struct {
+	name string
+	w    api.WalletAPI
+}
+
+type hasResult struct {
+	have bool
+	err  error
+}
+
+// probeHas asks all candidates whether they hold the address at once.
+// Answers are taken in candidate order, so that the first candidate holding
+// the key is picked as with asking one after the other, but a slow backend
+// only delays the answer if it comes before the one holding the key.
+// Candidates which fail or time out are skipped and why is returned. The
+// index of the picked candidate is returned, or -1.
+func probeHas(ctx context.Context, breakers *Breakers, addr address.Address, cands []hasCandidate) (int, []error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]chan hasResult, len(cands))
+	for i, c := range cands {
+		results[i] = make(chan hasResult, 1)
+		go func(c hasCandidate, out chan<- hasResult) {
+			tctx, tcancel := context.WithTimeout(ctx, backendHasTimeout)
+			defer tcancel()
+
+			have, err := c.w.WalletHas(tctx, addr)
+			if err != nil && ctx.Err() != nil {
+				// the answer isn't needed anymore, don't count it as a
+				// backend failure
+				out <- hasResult{err: err}
+				return
+			}
+			breakers.record(c.name, c.w, err)
+			out <- hasResult{have: have, err: err}
+		}(c, results[i])
+	}
+
+	var skipped []error
+	for i, c := range cands {
+		var r hasResult
+		select {
+		case r = <-results[i]:
+		case <-ctx.Done():
+			return -1, append(skipped, ctx.Err())
+		}
+
+		if r.err != nil {
+			log.Warnw("checking wallet backend", "backend", c.name, "address", addr, "error", r.err)
+			skipped = append(skipped, xerrors.Errorf("%s: %w", c.name, r.err))
+			continue
+		}
+		if r.have {
+			return i, skipped
+		}
+	}
+
+	return -1, skipped
+}
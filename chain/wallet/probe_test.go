@@ -0,0 +1,75 @@
+package wallet
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+)
+
+func TestProbeHasPicksFirstHolder(t *testing.T) {
+	ctx := context.Background()
+	addr, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+
+	i, skipped := probeHas(ctx, nil, addr, []hasCandidate{
+		{name: "a", w: newFakeBackend(false, nil)},
+		{name: "b", w: newFakeBackend(false, xerrors.New("down"))},
+		{name: "c", w: newFakeBackend(true, nil)},
+		{name: "d", w: newFakeBackend(true, nil)},
+	})
+	require.Equal(t, 2, i)
+	require.Len(t, skipped, 1)
+	require.Contains(t, skipped[0].Error(), "b: down")
+
+	i, skipped = probeHas(ctx, nil, addr, []hasCandidate{
+		{name: "a", w: newFakeBackend(false, nil)},
+	})
+	require.Equal(t, -1, i)
+	require.Empty(t, skipped)
+}
+
+func TestProbeHasDoesntWaitAfterHolder(t *testing.T) {
+	addr, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+
+	slow := newFakeBackend(true, nil)
+	slow.delay = time.Minute
+
+	start := time.Now()
+	i, _ := probeHas(context.Background(), nil, addr, []hasCandidate{
+		{name: "a", w: newFakeBackend(true, nil)},
+		{name: "slow", w: slow},
+	})
+	require.Equal(t, 0, i)
+	require.Less(t, int64(time.Since(start)), int64(10*time.Second))
+
+	// a slow backend before the holder is waited for, until the request is
+	// cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	i, skipped := probeHas(ctx, nil, addr, []hasCandidate{
+		{name: "slow", w: slow},
+		{name: "a", w: newFakeBackend(true, nil)},
+	})
+	require.Equal(t, -1, i)
+	require.True(t, xerrors.Is(skipped[len(skipped)-1], context.DeadlineExceeded))
+}
+
+func TestProbeHasRecordsFailures(t *testing.T) {
+	addr, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+
+	b := NewBreakers(BreakerConfig{Threshold: 1, Cooldown: time.Hour})
+	i, _ := probeHas(context.Background(), b, addr, []hasCandidate{
+		{name: "down", w: newFakeBackend(false, xerrors.New("down"))},
+		{name: "up", w: newFakeBackend(true, nil)},
+	})
+	require.Equal(t, 1, i)
+	require.True(t, xerrors.Is(b.allow("down"), ErrBackendDegraded))
+	require.NoError(t, b.allow("up"))
+}
@@ -0,0 +1,247 @@
+package remotewallet
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-jsonrpc"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	cliutil "github.com/filecoin-project/lotus/cli/util"
+)
+
+// endpointCooldown is how long an unreachable remote is skipped before it is
+// tried again, unless a health check finds it reachable earlier
+const endpointCooldown = 30 * time.Second
+
+// endpointCheckInterval is how often all remotes are health checked
+const endpointCheckInterval = 30 * time.Second
+
+type endpoint struct {
+	addr string
+	w    *lazyWallet
+
+	// set while the remote is unreachable
+	downUntil time.Time
+	lastErr   error
+}
+
+// failoverWallet sends requests to one of several remote wallets holding the
+// same keys, moving on to the next one when a remote can't be reached.
+// Errors returned by a reachable remote, like policy denials, are returned
+// as is, as another remote would answer the same.
+type failoverWallet struct {
+	lk        sync.Mutex
+	endpoints []*endpoint
+	active    int
+}
+
+// NewFailoverRemoteWallet is like NewLazyRemoteWallet, with requests failing
+// over between the given remote wallets, which must all hold the same keys.
+// Remotes are health checked in the background until the closer is called.
+func NewFailoverRemoteWallet(infos []string, verifiers ...string) (*RemoteWallet, jsonrpc.ClientCloser) {
+	fw := &failoverWallet{}
+	for _, info := range infos {
+		fw.endpoints = append(fw.endpoints, &endpoint{
+			addr: cliutil.ParseApiInfo(info).Addr,
+			w:    &lazyWallet{info: info},
+		})
+	}
+
+	rw := &RemoteWallet{WalletAPI: fw}
+	vcloser := rw.addLazyVerifiers(verifiers)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go fw.checkHealth(ctx, endpointCheckInterval)
+
+	return rw, func() {
+		cancel()
+		vcloser()
+		for _, e := range fw.endpoints {
+			e.w.close()
+		}
+	}
+}
+
+func (f *failoverWallet) status() []api.RemoteStatus {
+	f.lk.Lock()
+	active := f.active
+	f.lk.Unlock()
+
+	out := make([]api.RemoteStatus, len(f.endpoints))
+	for i, e := range f.endpoints {
+		out[i] = e.w.status("remote")
+		out[i].Active = i == active
+	}
+	return out
+}
+
+func unreachable(err error) bool {
+	var cerr *connectError
+	var jerr *jsonrpc.ErrClient
+	return xerrors.As(err, &cerr) || xerrors.As(err, &jerr)
+}
+
+// order returns endpoints to try, the active one first, then ones which
+// aren't known to be down. If all are down, all are tried anyway.
+func (f *failoverWallet) order() []int {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	var up, down []int
+	for i := range f.endpoints {
+		idx := (f.active + i) % len(f.endpoints)
+		if time.Now().Before(f.endpoints[idx].downUntil) {
+			down = append(down, idx)
+			continue
+		}
+		up = append(up, idx)
+	}
+	if len(up) == 0 {
+		return down
+	}
+	return up
+}
+
+func (f *failoverWallet) result(idx int, err error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	e := f.endpoints[idx]
+	if err != nil {
+		if e.downUntil.IsZero() {
+			log.Warnw("remote wallet unreachable, failing over", "remote", e.addr, "error", err)
+		}
+		e.downUntil = time.Now().Add(endpointCooldown)
+		e.lastErr = err
+		return
+	}
+
+	if !e.downUntil.IsZero() {
+		log.Infow("remote wallet reachable again", "remote", e.addr)
+	}
+	e.downUntil = time.Time{}
+	e.lastErr = nil
+	if f.active != idx {
+		log.Infow("switched active remote wallet", "remote", e.addr)
+		f.active = idx
+	}
+}
+
+// do calls cb with remotes until one can be reached
+func (f *failoverWallet) do(ctx context.Context, cb func(w api.WalletAPI) error) error {
+	var lastErr error
+	for _, idx := range f.order() {
+		err := cb(f.endpoints[idx].w)
+		if err != nil && ctx.Err() != nil {
+			// the request was canceled, which says nothing about the remote
+			return err
+		}
+		if err != nil && unreachable(err) {
+			f.result(idx, err)
+			lastErr = err
+			continue
+		}
+
+		f.result(idx, nil)
+		return err
+	}
+
+	return xerrors.Errorf("no remote wallet reachable: %w", lastErr)
+}
+
+func (f *failoverWallet) checkHealth(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+
+		for _, e := range f.endpoints {
+			cctx, cancel := context.WithTimeout(ctx, interval)
+			_, err := e.w.WalletList(cctx)
+			cancel()
+			if err != nil && !unreachable(err) {
+				// reachable, but refusing to list; that's fine for routing
+				err = nil
+			}
+
+			f.lk.Lock()
+			if err != nil {
+				e.downUntil = time.Now().Add(endpointCooldown)
+				e.lastErr = err
+			} else if !e.downUntil.IsZero() {
+				log.Infow("remote wallet reachable again", "remote", e.addr)
+				e.downUntil = time.Time{}
+				e.lastErr = nil
+			}
+			f.lk.Unlock()
+		}
+	}
+}
+
+func (f *failoverWallet) WalletNew(ctx context.Context, kt types.KeyType) (a address.Address, err error) {
+	err = f.do(ctx, func(w api.WalletAPI) error {
+		a, err = w.WalletNew(ctx, kt)
+		return err
+	})
+	return a, err
+}
+
+func (f *failoverWallet) WalletHas(ctx context.Context, addr address.Address) (have bool, err error) {
+	err = f.do(ctx, func(w api.WalletAPI) error {
+		have, err = w.WalletHas(ctx, addr)
+		return err
+	})
+	return have, err
+}
+
+func (f *failoverWallet) WalletList(ctx context.Context) (list []address.Address, err error) {
+	err = f.do(ctx, func(w api.WalletAPI) error {
+		list, err = w.WalletList(ctx)
+		return err
+	})
+	return list, err
+}
+
+func (f *failoverWallet) WalletSign(ctx context.Context, signer address.Address, toSign []byte, meta api.MsgMeta) (sig *crypto.Signature, err error) {
+	err = f.do(ctx, func(w api.WalletAPI) error {
+		sig, err = w.WalletSign(ctx, signer, toSign, meta)
+		return err
+	})
+	return sig, err
+}
+
+func (f *failoverWallet) WalletExport(ctx context.Context, addr address.Address) (ki *types.KeyInfo, err error) {
+	err = f.do(ctx, func(w api.WalletAPI) error {
+		ki, err = w.WalletExport(ctx, addr)
+		return err
+	})
+	return ki, err
+}
+
+func (f *failoverWallet) WalletImport(ctx context.Context, ki *types.KeyInfo) (a address.Address, err error) {
+	err = f.do(ctx, func(w api.WalletAPI) error {
+		a, err = w.WalletImport(ctx, ki)
+		return err
+	})
+	return a, err
+}
+
+func (f *failoverWallet) WalletDelete(ctx context.Context, addr address.Address) error {
+	return f.do(ctx, func(w api.WalletAPI) error {
+		return w.WalletDelete(ctx, addr)
+	})
+}
+
+var _ api.WalletAPI = &failoverWallet{}
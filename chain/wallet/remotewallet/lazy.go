@@ -0,0 +1,242 @@
+package remotewallet
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-jsonrpc"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	cliutil "github.com/filecoin-project/lotus/cli/util"
+)
+
+// connectError is returned when the remote wallet can't be reached, as
+// opposed to errors returned by the remote wallet
+type connectError struct {
+	err error
+}
+
+func (e *connectError) Error() string {
+	return e.err.Error()
+}
+
+func (e *connectError) Unwrap() error {
+	return e.err
+}
+
+// Connection states reported by RemoteWallet.Status
+const (
+	ConnNotConnected = "not-connected"
+	ConnConnected    = "connected"
+	ConnUnreachable  = "unreachable"
+)
+
+const (
+	minDialBackoff = time.Second
+	maxDialBackoff = time.Minute
+)
+
+// lazyWallet connects to a wallet api on first use. Failed connection
+// attempts are retried with exponential backoff; once connected, the
+// websocket client pings the remote and reconnects by itself when the
+// connection drops.
+type lazyWallet struct {
+	info string
+
+	lk     sync.Mutex
+	api    api.WalletAPI
+	closer jsonrpc.ClientCloser
+
+	dialFails int
+	nextDial  time.Time
+	lastErr   error
+	lastSeen  time.Time
+}
+
+// NewLazyRemoteWallet is like NewRemoteWallet, but only connects to the
+// remote and the verifiers once the wallet is first used.
+func NewLazyRemoteWallet(info string, verifiers ...string) (*RemoteWallet, jsonrpc.ClientCloser) {
+	lw := &lazyWallet{info: info}
+	rw := &RemoteWallet{WalletAPI: lw}
+	vcloser := rw.addLazyVerifiers(verifiers)
+
+	return rw, func() {
+		lw.close()
+		vcloser()
+	}
+}
+
+// addLazyVerifiers adds verifiers which are connected to on first use, and
+// returns a function closing them
+func (w *RemoteWallet) addLazyVerifiers(verifiers []string) func() {
+	var lazy []*lazyWallet
+	for _, vi := range verifiers {
+		vw := &lazyWallet{info: vi}
+		lazy = append(lazy, vw)
+
+		w.verifiers = append(w.verifiers, verifier{
+			addr:      cliutil.ParseApiInfo(vi).Addr,
+			WalletAPI: vw,
+		})
+	}
+
+	return func() {
+		for _, l := range lazy {
+			l.close()
+		}
+	}
+}
+
+func (l *lazyWallet) get() (api.WalletAPI, error) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	if l.api != nil {
+		return l.api, nil
+	}
+
+	addr := cliutil.ParseApiInfo(l.info).Addr
+	if time.Now().Before(l.nextDial) {
+		return nil, &connectError{xerrors.Errorf("connecting to remote wallet %s: retrying in %s after %d failed attempts: %w", addr, time.Until(l.nextDial).Truncate(time.Second), l.dialFails, l.lastErr)}
+	}
+
+	log.Infow("connecting to remote wallet", "addr", addr)
+	// the connection outlives the request which triggered it
+	wapi, closer, err := connect(context.Background(), l.info)
+	if err != nil {
+		l.dialFails++
+		backoff := minDialBackoff << uint(l.dialFails-1)
+		if backoff > maxDialBackoff || backoff <= 0 {
+			backoff = maxDialBackoff
+		}
+		l.nextDial = time.Now().Add(backoff)
+		l.lastErr = err
+		return nil, &connectError{xerrors.Errorf("connecting to remote wallet: %w", err)}
+	}
+
+	l.api, l.closer = wapi, closer
+	l.dialFails = 0
+	l.lastErr = nil
+	l.lastSeen = time.Now()
+	return wapi, nil
+}
+
+// observe records whether the remote could be reached by a request
+func (l *lazyWallet) observe(err error) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	if err != nil && unreachable(err) {
+		l.lastErr = err
+		return
+	}
+	l.lastErr = nil
+	l.lastSeen = time.Now()
+}
+
+func (l *lazyWallet) status(role string) api.RemoteStatus {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	st := api.RemoteStatus{
+		Role:     role,
+		Addr:     cliutil.ParseApiInfo(l.info).Addr,
+		State:    ConnConnected,
+		LastSeen: l.lastSeen,
+	}
+	switch {
+	case l.lastErr != nil:
+		st.State = ConnUnreachable
+		st.LastError = l.lastErr.Error()
+	case l.api == nil:
+		st.State = ConnNotConnected
+	}
+	return st
+}
+
+func (l *lazyWallet) close() {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	if l.closer != nil {
+		l.closer()
+	}
+}
+
+func (l *lazyWallet) WalletNew(ctx context.Context, kt types.KeyType) (address.Address, error) {
+	w, err := l.get()
+	if err != nil {
+		return address.Undef, err
+	}
+	a, err := w.WalletNew(ctx, kt)
+	l.observe(err)
+	return a, err
+}
+
+func (l *lazyWallet) WalletHas(ctx context.Context, addr address.Address) (bool, error) {
+	w, err := l.get()
+	if err != nil {
+		return false, err
+	}
+	have, err := w.WalletHas(ctx, addr)
+	l.observe(err)
+	return have, err
+}
+
+func (l *lazyWallet) WalletList(ctx context.Context) ([]address.Address, error) {
+	w, err := l.get()
+	if err != nil {
+		return nil, err
+	}
+	list, err := w.WalletList(ctx)
+	l.observe(err)
+	return list, err
+}
+
+func (l *lazyWallet) WalletSign(ctx context.Context, signer address.Address, toSign []byte, meta api.MsgMeta) (*crypto.Signature, error) {
+	w, err := l.get()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := w.WalletSign(ctx, signer, toSign, meta)
+	l.observe(err)
+	return sig, err
+}
+
+func (l *lazyWallet) WalletExport(ctx context.Context, addr address.Address) (*types.KeyInfo, error) {
+	w, err := l.get()
+	if err != nil {
+		return nil, err
+	}
+	ki, err := w.WalletExport(ctx, addr)
+	l.observe(err)
+	return ki, err
+}
+
+func (l *lazyWallet) WalletImport(ctx context.Context, ki *types.KeyInfo) (address.Address, error) {
+	w, err := l.get()
+	if err != nil {
+		return address.Undef, err
+	}
+	a, err := w.WalletImport(ctx, ki)
+	l.observe(err)
+	return a, err
+}
+
+func (l *lazyWallet) WalletDelete(ctx context.Context, addr address.Address) error {
+	w, err := l.get()
+	if err != nil {
+		return err
+	}
+	err = w.WalletDelete(ctx, addr)
+	l.observe(err)
+	return err
+}
+
+var _ api.WalletAPI = &lazyWallet{}
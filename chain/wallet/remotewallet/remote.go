@@ -2,32 +2,57 @@ package remotewallet
 
 import (
 	"context"
+	"sort"
+	"time"
 
+	logging "github.com/ipfs/go-log/v2"
 	"go.uber.org/fx"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-jsonrpc"
+	"github.com/filecoin-project/go-state-types/crypto"
+
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/api/client"
 	cliutil "github.com/filecoin-project/lotus/cli/util"
+	"github.com/filecoin-project/lotus/lib/retryhint"
 	"github.com/filecoin-project/lotus/node/modules/helpers"
 )
 
+var log = logging.Logger("wallet-remote")
+
+const (
+	// longest retry-after hint a sign request waits for
+	maxRetryWait = 30 * time.Second
+	// how many times a sign request is sent when the remote asks to retry
+	maxSignAttempts = 3
+)
+
 type RemoteWallet struct {
 	api.WalletAPI
+
+	// verifiers are independent upstreams which must confirm WalletHas and
+	// WalletList answers, so a lying intermediary can be detected
+	verifiers []verifier
 }
 
-func SetupRemoteWallet(info string) func(mctx helpers.MetricsCtx, lc fx.Lifecycle) (*RemoteWallet, error) {
-	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle) (*RemoteWallet, error) {
-		ai := cliutil.ParseApiInfo(info)
+type verifier struct {
+	addr string
+	api.WalletAPI
+}
 
-		url, err := ai.DialArgs()
-		if err != nil {
+// SetupRemoteWallet connects to the wallet api at info, using the given client
+// TLS settings for wss endpoints.
+func SetupRemoteWallet(info string, tlsCfg cliutil.TLSClientConfig, verifiers ...string) func(mctx helpers.MetricsCtx, lc fx.Lifecycle) (*RemoteWallet, error) {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle) (*RemoteWallet, error) {
+		if err := tlsCfg.Apply(); err != nil {
 			return nil, err
 		}
 
-		wapi, closer, err := client.NewWalletRPC(mctx, url, ai.AuthHeader())
+		rw, closer, err := NewRemoteWallet(mctx, info, verifiers...)
 		if err != nil {
-			return nil, xerrors.Errorf("creating jsonrpc client: %w", err)
+			return nil, err
 		}
 
 		lc.Append(fx.Hook{
@@ -37,8 +62,156 @@ func SetupRemoteWallet(info string) func(mctx helpers.MetricsCtx, lc fx.Lifecycl
 			},
 		})
 
-		return &RemoteWallet{wapi}, nil
+		return rw, nil
+	}
+}
+
+// NewRemoteWallet connects to the wallet api at info. WalletHas and WalletList
+// answers are checked against all of the verifier apis. If a connection
+// drops, it is re-established by itself.
+func NewRemoteWallet(ctx context.Context, info string, verifiers ...string) (*RemoteWallet, jsonrpc.ClientCloser, error) {
+	rw, closer := NewLazyRemoteWallet(info, verifiers...)
+
+	// connect right away, so that a wrong address is reported at startup
+	if _, err := rw.WalletAPI.(*lazyWallet).get(); err != nil {
+		closer()
+		return nil, nil, err
+	}
+	for _, v := range rw.verifiers {
+		if _, err := v.WalletAPI.(*lazyWallet).get(); err != nil {
+			closer()
+			return nil, nil, xerrors.Errorf("connecting to verifier: %w", err)
+		}
+	}
+
+	return rw, closer, nil
+}
+
+// Status returns the connection state of the remote wallets and verifiers
+func (w *RemoteWallet) Status() []api.RemoteStatus {
+	var out []api.RemoteStatus
+	switch u := w.WalletAPI.(type) {
+	case *lazyWallet:
+		st := u.status("remote")
+		st.Active = true
+		out = append(out, st)
+	case *failoverWallet:
+		out = append(out, u.status()...)
+	}
+
+	for _, v := range w.verifiers {
+		if l, ok := v.WalletAPI.(*lazyWallet); ok {
+			out = append(out, l.status("verifier"))
+		}
+	}
+	return out
+}
+
+func connect(ctx context.Context, info string) (api.WalletAPI, jsonrpc.ClientCloser, error) {
+	ai := cliutil.ParseApiInfo(info)
+
+	url, err := ai.DialArgs()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	headers, err := cliutil.SignWalletRequest(url, ai.AuthHeader())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wapi, closer, err := client.NewWalletRPC(ctx, url, headers)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("creating jsonrpc client: %w", err)
+	}
+
+	return wapi, closer, nil
+}
+
+func (w *RemoteWallet) WalletHas(ctx context.Context, addr address.Address) (bool, error) {
+	have, err := w.WalletAPI.WalletHas(ctx, addr)
+	if err != nil {
+		return false, err
+	}
+
+	for _, v := range w.verifiers {
+		vhave, err := v.WalletHas(ctx, addr)
+		if err != nil {
+			return false, xerrors.Errorf("verifying WalletHas with %s: %w", v.addr, err)
+		}
+		if vhave != have {
+			log.Errorw("remote wallet answer not confirmed by verifier", "method", "WalletHas", "address", addr, "remote", have, "verifier", v.addr, "verifierAnswer", vhave)
+			return false, xerrors.Errorf("WalletHas(%s) answer not confirmed by verifier %s", addr, v.addr)
+		}
+	}
+
+	return have, nil
+}
+
+func (w *RemoteWallet) WalletList(ctx context.Context) ([]address.Address, error) {
+	list, err := w.WalletAPI.WalletList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range w.verifiers {
+		vlist, err := v.WalletList(ctx)
+		if err != nil {
+			return nil, xerrors.Errorf("verifying WalletList with %s: %w", v.addr, err)
+		}
+		if !sameAddrs(list, vlist) {
+			log.Errorw("remote wallet answer not confirmed by verifier", "method", "WalletList", "remote", list, "verifier", v.addr, "verifierAnswer", vlist)
+			return nil, xerrors.Errorf("WalletList answer not confirmed by verifier %s", v.addr)
+		}
+	}
+
+	return list, nil
+}
+
+// WalletSign retries requests the remote refused for a temporary reason, like
+// a full sign queue, after the retry-after hint of the refusal. Hints longer
+// than maxRetryWait, or beyond the deadline of the request, are returned to
+// the caller.
+func (w *RemoteWallet) WalletSign(ctx context.Context, signer address.Address, toSign []byte, meta api.MsgMeta) (*crypto.Signature, error) {
+	for attempt := 1; ; attempt++ {
+		sig, err := w.WalletAPI.WalletSign(ctx, signer, toSign, meta)
+		after, ok := retryhint.After(err)
+		if !ok || after > maxRetryWait || attempt >= maxSignAttempts {
+			return sig, err
+		}
+		if dl, ok := ctx.Deadline(); ok && time.Until(dl) < after {
+			return sig, err
+		}
+
+		log.Infow("remote wallet asked to retry sign request later", "signer", signer, "after", after, "attempt", attempt, "error", err)
+		select {
+		case <-time.After(after):
+		case <-ctx.Done():
+			return nil, err
+		}
+	}
+}
+
+func sameAddrs(a, b []address.Address) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	sa := make([]string, len(a))
+	sb := make([]string, len(b))
+	for i := range a {
+		sa[i] = a[i].String()
+		sb[i] = b[i].String()
+	}
+	sort.Strings(sa)
+	sort.Strings(sb)
+
+	for i := range sa {
+		if sa[i] != sb[i] {
+			return false
+		}
 	}
+	return true
 }
 
 func (w *RemoteWallet) Get() api.WalletAPI {
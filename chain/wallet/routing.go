@@ -0,0 +1,217 @@
+package wallet
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ipfs/go-datastore"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+var dsRoutingKey = datastore.NewKey("/routing")
+
+// Router holds the configuration routing MultiWallet requests to backends,
+// see api.BackendRouting. A nil *Router keeps the default routing.
+type Router struct {
+	ds datastore.Datastore
+
+	lk   sync.RWMutex
+	cfg  api.BackendRouting
+	pins map[address.Address]string
+}
+
+// NewRouter loads the routing configuration from the datastore
+func NewRouter(ds datastore.Datastore) (*Router, error) {
+	r := &Router{ds: ds}
+
+	b, err := ds.Get(dsRoutingKey)
+	if err == datastore.ErrNotFound {
+		return r, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("getting backend routing: %w", err)
+	}
+
+	var cfg api.BackendRouting
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, xerrors.Errorf("unmarshaling backend routing: %w", err)
+	}
+	r.apply(cfg)
+	return r, nil
+}
+
+func (r *Router) apply(cfg api.BackendRouting) {
+	r.cfg = cfg
+	r.pins = map[address.Address]string{}
+	for _, p := range cfg.Pins {
+		r.pins[p.Address] = p.Backend
+	}
+}
+
+// Get returns the current routing configuration
+func (r *Router) Get() api.BackendRouting {
+	if r == nil {
+		return api.BackendRouting{}
+	}
+
+	r.lk.RLock()
+	defer r.lk.RUnlock()
+	return r.cfg
+}
+
+// Set replaces the routing configuration. All backend names must be in known.
+func (r *Router) Set(cfg api.BackendRouting, known []string) error {
+	if r == nil {
+		return xerrors.Errorf("backend routing is not supported")
+	}
+
+	isKnown := map[string]bool{}
+	for _, k := range known {
+		isKnown[k] = true
+	}
+	check := func(what, name string) error {
+		if !isKnown[name] {
+			return xerrors.Errorf("%s: unknown backend %q", what, name)
+		}
+		return nil
+	}
+
+	for _, b := range cfg.Priority {
+		if err := check("priority", b); err != nil {
+			return err
+		}
+	}
+	for kt, b := range cfg.NewKeys {
+		if err := check(string(kt)+" keys", b); err != nil {
+			return err
+		}
+	}
+	seen := map[address.Address]bool{}
+	for _, p := range cfg.Pins {
+		if err := check(p.Address.String(), p.Backend); err != nil {
+			return err
+		}
+		if seen[p.Address] {
+			return xerrors.Errorf("%s is pinned more than once", p.Address)
+		}
+		seen[p.Address] = true
+	}
+
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	if err := r.ds.Put(dsRoutingKey, b); err != nil {
+		return xerrors.Errorf("writing backend routing: %w", err)
+	}
+	r.apply(cfg)
+	return nil
+}
+
+// lookupOrder returns the backends the address should be looked up on, in
+// order. Pinned addresses are only looked up on their backend.
+func (r *Router) lookupOrder(addr address.Address, ws []api.WalletAPI) []api.WalletAPI {
+	if r == nil {
+		return ws
+	}
+
+	r.lk.RLock()
+	defer r.lk.RUnlock()
+
+	if pin, ok := r.pins[addr]; ok {
+		for _, w := range ws {
+			if backendName(w) == pin {
+				return []api.WalletAPI{w}
+			}
+		}
+		return nil
+	}
+
+	if len(r.cfg.Priority) == 0 {
+		return ws
+	}
+
+	rank := map[string]int{}
+	for i, b := range r.cfg.Priority {
+		rank[b] = i + 1
+	}
+	out := append([]api.WalletAPI(nil), ws...)
+	sort.SliceStable(out, func(i, j int) bool {
+		ri, rj := rank[backendName(out[i])], rank[backendName(out[j])]
+		if ri == 0 || rj == 0 {
+			return rj == 0 && ri != 0
+		}
+		return ri < rj
+	})
+	return out
+}
+
+// newKeyBackend returns the backend configured to create or import a key, or
+// an empty string for the default. Pins take precedence over the key type.
+func (r *Router) newKeyBackend(kt types.KeyType, addr address.Address) string {
+	if r == nil {
+		return ""
+	}
+
+	r.lk.RLock()
+	defer r.lk.RUnlock()
+
+	if pin, ok := r.pins[addr]; ok && addr != address.Undef {
+		return pin
+	}
+	return r.cfg.NewKeys[kt]
+}
+
+type routingFile struct {
+	Priority []string
+	NewKeys  map[string]string
+	Pin      []struct {
+		Address string
+		Backend string
+	}
+}
+
+// LoadRoutingFile parses backend routing from a TOML file in the form of:
+//
+//	Priority = ["ledger", "local"]
+//
+//	[NewKeys]
+//	secp256k1 = "remote"
+//	bls = "local"
+//
+//	[[Pin]]
+//	Address = "f1..."
+//	Backend = "ledger"
+func LoadRoutingFile(path string) (*api.BackendRouting, error) {
+	var rf routingFile
+	if _, err := toml.DecodeFile(path, &rf); err != nil {
+		return nil, xerrors.Errorf("decoding routing file: %w", err)
+	}
+
+	out := &api.BackendRouting{Priority: rf.Priority}
+	if len(rf.NewKeys) > 0 {
+		out.NewKeys = map[types.KeyType]string{}
+		for kt, b := range rf.NewKeys {
+			out.NewKeys[types.KeyType(kt)] = b
+		}
+	}
+	for _, p := range rf.Pin {
+		a, err := address.NewFromString(p.Address)
+		if err != nil {
+			return nil, xerrors.Errorf("parsing pinned address %q: %w", p.Address, err)
+		}
+		out.Pins = append(out.Pins, api.AddressPin{Address: a, Backend: p.Backend})
+	}
+	return out, nil
+}
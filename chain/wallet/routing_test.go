@@ -0,0 +1,116 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/wallet/remotewallet"
+)
+
+func TestRouterSetPersists(t *testing.T) {
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	r, err := NewRouter(ds)
+	require.NoError(t, err)
+	require.Equal(t, api.BackendRouting{}, r.Get())
+
+	pinned, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+	known := []string{"local", "remote"}
+
+	require.Error(t, r.Set(api.BackendRouting{Priority: []string{"ledger"}}, known))
+	require.Error(t, r.Set(api.BackendRouting{NewKeys: map[types.KeyType]string{types.KTBLS: "ledger"}}, known))
+	require.Error(t, r.Set(api.BackendRouting{Pins: []api.AddressPin{
+		{Address: pinned, Backend: "local"},
+		{Address: pinned, Backend: "remote"},
+	}}, known))
+	require.Equal(t, api.BackendRouting{}, r.Get(), "invalid routing must not be applied")
+
+	cfg := api.BackendRouting{
+		Priority: []string{"remote", "local"},
+		NewKeys:  map[types.KeyType]string{types.KTBLS: "remote"},
+		Pins:     []api.AddressPin{{Address: pinned, Backend: "local"}},
+	}
+	require.NoError(t, r.Set(cfg, known))
+	require.Equal(t, cfg, r.Get())
+
+	r, err = NewRouter(ds)
+	require.NoError(t, err)
+	require.Equal(t, cfg, r.Get())
+
+	require.Equal(t, "remote", r.newKeyBackend(types.KTBLS, address.Undef))
+	require.Equal(t, "", r.newKeyBackend(types.KTSecp256k1, address.Undef))
+	require.Equal(t, "local", r.newKeyBackend(types.KTBLS, pinned))
+}
+
+func TestRouterLookupOrder(t *testing.T) {
+	lw, err := NewWallet(NewMemKeyStore())
+	require.NoError(t, err)
+	rw := &remotewallet.RemoteWallet{}
+	ws := []api.WalletAPI{lw, rw}
+
+	pinned, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+	other, err := address.NewIDAddress(1001)
+	require.NoError(t, err)
+
+	var nilRouter *Router
+	require.Equal(t, ws, nilRouter.lookupOrder(other, ws))
+
+	r, err := NewRouter(dssync.MutexWrap(datastore.NewMapDatastore()))
+	require.NoError(t, err)
+	require.Equal(t, ws, r.lookupOrder(other, ws))
+
+	require.NoError(t, r.Set(api.BackendRouting{
+		Priority: []string{"remote"},
+		Pins:     []api.AddressPin{{Address: pinned, Backend: "local"}},
+	}, []string{"local", "remote"}))
+	require.Equal(t, []api.WalletAPI{rw, lw}, r.lookupOrder(other, ws))
+	require.Equal(t, []api.WalletAPI{lw}, r.lookupOrder(pinned, ws))
+
+	// pinned to a backend which isn't configured
+	require.Empty(t, r.lookupOrder(pinned, []api.WalletAPI{rw}))
+}
+
+func TestLoadRoutingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "routing")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "routing.toml")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+Priority = ["ledger", "local"]
+
+[NewKeys]
+bls = "local"
+
+[[Pin]]
+Address = "t01000"
+Backend = "ledger"
+`), 0600))
+
+	cfg, err := LoadRoutingFile(path)
+	require.NoError(t, err)
+	pinned, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+	require.Equal(t, &api.BackendRouting{
+		Priority: []string{"ledger", "local"},
+		NewKeys:  map[types.KeyType]string{types.KTBLS: "local"},
+		Pins:     []api.AddressPin{{Address: pinned, Backend: "ledger"}},
+	}, cfg)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("[[Pin]]\nAddress = \"not an address\"\nBackend = \"local\"\n"), 0600))
+	_, err = LoadRoutingFile(path)
+	require.Error(t, err)
+}
@@ -0,0 +1,159 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+var dsSecLogPrefix = "/seclog/"
+
+// Kinds of security events
+const (
+	SecEvKeyNew          = "key-new"
+	SecEvKeyImport       = "key-import"
+	SecEvKeyDelete       = "key-delete"
+	SecEvHDInit          = "hd-init"
+	SecEvServiceKeyNew   = "service-key-new"
+	SecEvServiceKeyDel   = "service-key-delete"
+	SecEvPolicySet       = "policy-set"
+	SecEvPolicyRemove    = "policy-remove"
+	SecEvGroupSet        = "group-set"
+	SecEvGroupRemove     = "group-remove"
+	SecEvGroupFreeze     = "group-freeze"
+	SecEvGroupUnfreeze   = "group-unfreeze"
+	SecEvTokenNew        = "token-new"
+	SecEvTokenRevoke     = "token-revoke"
+	SecEvMaintenanceOn   = "maintenance-on"
+	SecEvMaintenanceOff  = "maintenance-off"
+	SecEvAddressAppeared = "address-appeared"
+	SecEvBackendAttach   = "backend-attach"
+	SecEvBackendDetach   = "backend-detach"
+)
+
+// SecurityLog records security relevant changes of wallet state: keys being
+// added or removed, policy and key group changes, freezes and api tokens being
+// issued or revoked. Unlike the audit log it doesn't record sign requests, so
+// it stays small enough to review in full during an incident. Only changes
+// which took effect are recorded.
+type SecurityLog struct {
+	api.WalletAPI
+
+	ds  datastore.Datastore
+	seq uint64
+}
+
+func NewSecurityLog(under api.WalletAPI, ds datastore.Datastore) *SecurityLog {
+	return &SecurityLog{
+		WalletAPI: under,
+		ds:        ds,
+	}
+}
+
+func (l *SecurityLog) WalletNew(ctx context.Context, typ types.KeyType) (address.Address, error) {
+	a, err := l.WalletAPI.WalletNew(ctx, typ)
+	if err == nil {
+		l.Record(ctx, SecEvKeyNew, a.String(), string(typ))
+	}
+	return a, err
+}
+
+func (l *SecurityLog) WalletImport(ctx context.Context, ki *types.KeyInfo) (address.Address, error) {
+	a, err := l.WalletAPI.WalletImport(ctx, ki)
+	if err == nil {
+		l.Record(ctx, SecEvKeyImport, a.String(), string(ki.Type))
+	}
+	return a, err
+}
+
+func (l *SecurityLog) WalletDelete(ctx context.Context, addr address.Address) error {
+	err := l.WalletAPI.WalletDelete(ctx, addr)
+	if err == nil {
+		l.Record(ctx, SecEvKeyDelete, addr.String(), "")
+	}
+	return err
+}
+
+// Record adds an event to the log. Subject is the changed object, like an
+// address, key group name or token ID.
+func (l *SecurityLog) Record(ctx context.Context, kind, subject, detail string) {
+	ev := api.SecurityEvent{
+		Time:    time.Now(),
+		Kind:    kind,
+		Caller:  CallerFromContext(ctx),
+		Subject: subject,
+		Detail:  detail,
+	}
+
+	b, err := json.Marshal(ev)
+	if err != nil {
+		log.Errorw("marshaling security event", "error", err)
+		return
+	}
+
+	// same key layout as the audit log, sorting by time
+	k := fmt.Sprintf("%s%020d-%d", dsSecLogPrefix, ev.Time.UnixNano(), atomic.AddUint64(&l.seq, 1))
+	if err := l.ds.Put(datastore.NewKey(k), b); err != nil {
+		// the change already happened, don't fail the request
+		log.Errorw("recording security event", "kind", kind, "subject", subject, "error", err)
+	}
+}
+
+// WalletSecurityEvents returns security events matching the filter, oldest
+// first. With a limit set, the most recent matching events are returned.
+func (l *SecurityLog) WalletSecurityEvents(ctx context.Context, f api.SecurityEventFilter) ([]api.SecurityEvent, error) {
+	res, err := l.ds.Query(query.Query{
+		Prefix: dsSecLogPrefix,
+		Orders: []query.Order{query.OrderByKey{}},
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("querying security log: %w", err)
+	}
+	defer res.Close() //nolint:errcheck
+
+	kinds := map[string]struct{}{}
+	for _, k := range f.Kinds {
+		kinds[k] = struct{}{}
+	}
+
+	var out []api.SecurityEvent
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, xerrors.Errorf("iterating security log: %w", r.Error)
+		}
+
+		var ev api.SecurityEvent
+		if err := json.Unmarshal(r.Value, &ev); err != nil {
+			return nil, xerrors.Errorf("unmarshaling security event %s: %w", r.Key, err)
+		}
+
+		if _, ok := kinds[ev.Kind]; len(kinds) > 0 && !ok {
+			continue
+		}
+		if !f.Since.IsZero() && ev.Time.Before(f.Since) {
+			continue
+		}
+		if !f.Until.IsZero() && !ev.Time.Before(f.Until) {
+			continue
+		}
+
+		out = append(out, ev)
+	}
+
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[len(out)-f.Limit:]
+	}
+
+	return out, nil
+}
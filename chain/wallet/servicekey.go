@@ -0,0 +1,201 @@
+package wallet
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// Key types of service keys. They are not Filecoin key types, so keys of these
+// types never get an address and can't be used by WalletSign.
+const (
+	KTServiceEd25519 types.KeyType = "service-ed25519"
+	KTServiceP256    types.KeyType = "service-p256"
+
+	KServiceKeyPrefix = "service-"
+)
+
+var serviceKeyNameRe = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+
+// ServiceKeys holds keys on curves Filecoin doesn't use, like ed25519 or
+// secp256r1 keys serving as service identities. Keys are named instead of
+// addressed and are kept under their own keystore prefix. They can only be
+// used through the WalletServiceKey* methods, which sign raw bytes, so they
+// stay isolated from Filecoin keys and signing policies.
+type ServiceKeys struct {
+	ks types.KeyStore
+	lk sync.Mutex
+}
+
+func NewServiceKeys(ks types.KeyStore) *ServiceKeys {
+	return &ServiceKeys{ks: ks}
+}
+
+// WalletServiceKeyNew generates a named service key
+func (s *ServiceKeys) WalletServiceKeyNew(ctx context.Context, name string, typ types.KeyType) (*api.ServiceKey, error) {
+	if !serviceKeyNameRe.MatchString(name) {
+		return nil, xerrors.Errorf("invalid service key name %q", name)
+	}
+
+	var priv []byte
+	switch typ {
+	case KTServiceEd25519:
+		_, pk, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, xerrors.Errorf("generating key: %w", err)
+		}
+		priv = pk.Seed()
+	case KTServiceP256:
+		pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, xerrors.Errorf("generating key: %w", err)
+		}
+		priv = make([]byte, 32)
+		pk.D.FillBytes(priv)
+	default:
+		return nil, xerrors.Errorf("unknown service key type %q, expected %s or %s", typ, KTServiceEd25519, KTServiceP256)
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if _, err := s.ks.Get(KServiceKeyPrefix + name); err == nil {
+		return nil, xerrors.Errorf("service key %q already exists", name)
+	} else if !xerrors.Is(err, types.ErrKeyInfoNotFound) {
+		return nil, xerrors.Errorf("checking for service key: %w", err)
+	}
+
+	ki := types.KeyInfo{Type: typ, PrivateKey: priv}
+	if err := s.ks.Put(KServiceKeyPrefix+name, ki); err != nil {
+		return nil, xerrors.Errorf("saving service key: %w", err)
+	}
+
+	return serviceKeyInfo(name, ki)
+}
+
+// WalletServiceKeyList lists service keys by name
+func (s *ServiceKeys) WalletServiceKeyList(ctx context.Context) ([]api.ServiceKey, error) {
+	names, err := s.ks.List()
+	if err != nil {
+		return nil, xerrors.Errorf("listing keystore: %w", err)
+	}
+	sort.Strings(names)
+
+	out := make([]api.ServiceKey, 0)
+	for _, n := range names {
+		if !strings.HasPrefix(n, KServiceKeyPrefix) {
+			continue
+		}
+
+		ki, err := s.ks.Get(n)
+		if err != nil {
+			return nil, xerrors.Errorf("getting service key %s: %w", n, err)
+		}
+		sk, err := serviceKeyInfo(strings.TrimPrefix(n, KServiceKeyPrefix), ki)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *sk)
+	}
+
+	return out, nil
+}
+
+// WalletServiceKeySign signs data with a service key. ed25519 keys sign the
+// data as is, p256 keys sign its sha256 digest and return an ASN.1 encoded
+// signature.
+func (s *ServiceKeys) WalletServiceKeySign(ctx context.Context, name string, data []byte) ([]byte, error) {
+	ki, err := s.ks.Get(KServiceKeyPrefix + name)
+	if xerrors.Is(err, types.ErrKeyInfoNotFound) {
+		return nil, xerrors.Errorf("service key %q not found", name)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("getting service key: %w", err)
+	}
+
+	switch ki.Type {
+	case KTServiceEd25519:
+		return ed25519.Sign(ed25519.NewKeyFromSeed(ki.PrivateKey), data), nil
+	case KTServiceP256:
+		digest := sha256.Sum256(data)
+		return ecdsa.SignASN1(rand.Reader, p256Key(ki.PrivateKey), digest[:])
+	default:
+		return nil, xerrors.Errorf("%q is not a service key", name)
+	}
+}
+
+// WalletServiceKeyDelete removes a service key
+func (s *ServiceKeys) WalletServiceKeyDelete(ctx context.Context, name string) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	ki, err := s.ks.Get(KServiceKeyPrefix + name)
+	if xerrors.Is(err, types.ErrKeyInfoNotFound) {
+		return xerrors.Errorf("service key %q not found", name)
+	}
+	if err != nil {
+		return xerrors.Errorf("getting service key: %w", err)
+	}
+	if ki.Type != KTServiceEd25519 && ki.Type != KTServiceP256 {
+		return xerrors.Errorf("%q is not a service key", name)
+	}
+
+	return s.ks.Delete(KServiceKeyPrefix + name)
+}
+
+// VerifyServiceSignature checks a signature made with WalletServiceKeySign
+func VerifyServiceSignature(typ types.KeyType, pub, data, sig []byte) error {
+	switch typ {
+	case KTServiceEd25519:
+		if len(pub) != ed25519.PublicKeySize || !ed25519.Verify(pub, data, sig) {
+			return xerrors.Errorf("invalid ed25519 signature")
+		}
+		return nil
+	case KTServiceP256:
+		x, y := elliptic.Unmarshal(elliptic.P256(), pub)
+		if x == nil {
+			return xerrors.Errorf("invalid p256 public key")
+		}
+		digest := sha256.Sum256(data)
+		if !ecdsa.VerifyASN1(&ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, digest[:], sig) {
+			return xerrors.Errorf("invalid p256 signature")
+		}
+		return nil
+	default:
+		return xerrors.Errorf("unknown service key type %q", typ)
+	}
+}
+
+func p256Key(priv []byte) *ecdsa.PrivateKey {
+	k := &ecdsa.PrivateKey{D: new(big.Int).SetBytes(priv)}
+	k.Curve = elliptic.P256()
+	k.X, k.Y = k.Curve.ScalarBaseMult(priv)
+	return k
+}
+
+func serviceKeyInfo(name string, ki types.KeyInfo) (*api.ServiceKey, error) {
+	sk := &api.ServiceKey{Name: name, Type: ki.Type}
+	switch ki.Type {
+	case KTServiceEd25519:
+		sk.PublicKey = ed25519.NewKeyFromSeed(ki.PrivateKey).Public().(ed25519.PublicKey)
+	case KTServiceP256:
+		k := p256Key(ki.PrivateKey)
+		sk.PublicKey = elliptic.Marshal(k.Curve, k.X, k.Y)
+	default:
+		return nil, xerrors.Errorf("%q is not a service key", name)
+	}
+	return sk, nil
+}
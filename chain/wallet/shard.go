@@ -0,0 +1,272 @@
+package wallet
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"sync/atomic"
+
+	"go.opencensus.io/tag"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/metrics"
+)
+
+// Shard is a downstream wallet of a ShardedWallet
+type Shard struct {
+	Name string
+	api.WalletAPI
+}
+
+// ShardedWallet spreads keys over a set of downstream wallets. Imported keys
+// are placed on the shard ranked first by rendezvous hashing of the address.
+// New keys are generated on the shards in turn, as their address isn't known
+// before the key exists. The shard holding each key is recorded in the
+// BackendIndex, which is asked first; otherwise the rendezvous hash only
+// orders the shards an address is probed on. Degraded shards are skipped, so
+// requests fail over to other shards holding a copy of the key.
+type ShardedWallet struct {
+	shards   []Shard
+	breakers *Breakers
+	index    *BackendIndex
+
+	next uint64
+}
+
+// NewShardedWallet routes requests to the given shards. Breakers may be nil;
+// the index is required, as it records where new keys were placed.
+func NewShardedWallet(shards []Shard, breakers *Breakers, index *BackendIndex) (*ShardedWallet, error) {
+	if len(shards) == 0 {
+		return nil, xerrors.Errorf("no shards configured")
+	}
+	if index == nil {
+		return nil, xerrors.Errorf("sharded wallet needs a backend index")
+	}
+
+	names := map[string]struct{}{}
+	for _, s := range shards {
+		if _, ok := names[s.Name]; ok {
+			return nil, xerrors.Errorf("duplicate shard name %q", s.Name)
+		}
+		names[s.Name] = struct{}{}
+	}
+
+	return &ShardedWallet{
+		shards:   shards,
+		breakers: breakers,
+		index:    index,
+	}, nil
+}
+
+func shardScore(addr address.Address, shard string) uint64 {
+	h := sha256.New()
+	_, _ = h.Write(addr.Bytes())
+	_, _ = h.Write([]byte(shard))
+	return binary.BigEndian.Uint64(h.Sum(nil))
+}
+
+// order returns shards in the preferred order for the address. The shard
+// which served the address last comes first.
+func (s *ShardedWallet) order(addr address.Address) []Shard {
+	out := append([]Shard(nil), s.shards...)
+	last, _ := s.index.Lookup(addr)
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name == last.Backend || out[j].Name == last.Backend {
+			return out[i].Name == last.Backend
+		}
+		return shardScore(addr, out[i].Name) > shardScore(addr, out[j].Name)
+	})
+	return out
+}
+
+// healthy returns shards which aren't degraded or in maintenance, in the
+// given order, and the reasons other shards were skipped
+func (s *ShardedWallet) healthy(shards []Shard) ([]Shard, []error) {
+	var out []Shard
+	var skipped []error
+	for _, sh := range shards {
+		if err := s.breakers.allow(sh.Name); err != nil {
+			log.Debugw("skipping wallet shard", "error", err)
+			skipped = append(skipped, err)
+			continue
+		}
+		out = append(out, sh)
+	}
+	return out, skipped
+}
+
+// find returns the first healthy shard holding the key of the address, and
+// the reasons other shards were skipped. Shards are asked at once, see
+// probeHas, unless one confirmed holding the key very recently.
+func (s *ShardedWallet) find(ctx context.Context, addr address.Address) (*Shard, []error, error) {
+	shards, skipped := s.healthy(s.order(addr))
+	if len(shards) == 0 {
+		return nil, skipped, nil
+	}
+
+	if recent, ok := s.index.recent(addr); ok && shards[0].Name == recent {
+		return &shards[0], skipped, nil
+	}
+
+	cands := make([]hasCandidate, len(shards))
+	for i, sh := range shards {
+		cands[i] = hasCandidate{name: sh.Name, w: sh.WalletAPI}
+	}
+
+	i, failed := probeHas(ctx, s.breakers, addr, cands)
+	skipped = append(skipped, failed...)
+	if i < 0 {
+		return nil, skipped, nil
+	}
+
+	s.index.put(addr, shards[i].Name)
+	return &shards[i], skipped, nil
+}
+
+func (s *ShardedWallet) WalletNew(ctx context.Context, typ types.KeyType) (address.Address, error) {
+	shards, _ := s.healthy(s.shards)
+	if len(shards) == 0 {
+		return address.Undef, xerrors.Errorf("no healthy wallet shards")
+	}
+
+	// the address isn't known up front, so the rendezvous hash can't place
+	// the key; spread new keys evenly and record where each went. Keys
+	// missing from the index are still found by probing all shards.
+	sh := shards[atomic.AddUint64(&s.next, 1)%uint64(len(shards))]
+	a, err := sh.WalletNew(ctx, typ)
+	if err == nil {
+		s.index.put(a, sh.Name)
+	}
+	return a, err
+}
+
+func (s *ShardedWallet) WalletHas(ctx context.Context, addr address.Address) (bool, error) {
+	sh, _, err := s.find(ctx, addr)
+	return sh != nil, err
+}
+
+func (s *ShardedWallet) WalletList(ctx context.Context) ([]address.Address, error) {
+	seen := map[address.Address]struct{}{}
+	out := make([]address.Address, 0)
+
+	// like MultiWallet, list the shards which answer, failing only if none do
+	var answered int
+	var lastErr error
+
+	shards, _ := s.healthy(s.shards)
+	for _, sh := range shards {
+		l, err := sh.WalletList(ctx)
+		s.breakers.record(sh.Name, sh.WalletAPI, err)
+		if err != nil {
+			log.Warnw("listing wallet shard failed, skipping it", "shard", sh.Name, "error", err)
+			lastErr = xerrors.Errorf("listing shard %s: %w", sh.Name, err)
+			continue
+		}
+		answered++
+
+		for _, a := range l {
+			if _, ok := seen[a]; ok {
+				continue
+			}
+			seen[a] = struct{}{}
+			s.index.put(a, sh.Name)
+			out = append(out, a)
+		}
+	}
+
+	if answered == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].String() < out[j].String()
+	})
+
+	return out, nil
+}
+
+func (s *ShardedWallet) WalletSign(ctx context.Context, signer address.Address, toSign []byte, meta api.MsgMeta) (*crypto.Signature, error) {
+	sh, skipped, err := s.find(ctx, signer)
+	if err != nil {
+		return nil, err
+	}
+	if sh == nil {
+		return nil, keyNotFound(skipped)
+	}
+
+	sig, err := sh.WalletSign(ctx, signer, toSign, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, _ = tag.New(ctx, tag.Upsert(metrics.WalletBackend, sh.Name))
+	if err := verifyBackendSignature(ctx, sig, signer, toSign); err != nil {
+		return nil, xerrors.Errorf("shard %s: %w", sh.Name, err)
+	}
+
+	return sig, nil
+}
+
+func (s *ShardedWallet) WalletExport(ctx context.Context, addr address.Address) (*types.KeyInfo, error) {
+	sh, skipped, err := s.find(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	if sh == nil {
+		return nil, keyNotFound(skipped)
+	}
+
+	return sh.WalletExport(ctx, addr)
+}
+
+func (s *ShardedWallet) WalletImport(ctx context.Context, ki *types.KeyInfo) (address.Address, error) {
+	shards := s.shards
+	if k, err := NewKey(*ki); err == nil {
+		shards = s.order(k.Address)
+	}
+
+	shards, _ = s.healthy(shards)
+	if len(shards) == 0 {
+		return address.Undef, xerrors.Errorf("no healthy wallet shards")
+	}
+
+	a, err := shards[0].WalletImport(ctx, ki)
+	if err == nil {
+		s.index.put(a, shards[0].Name)
+	}
+	return a, err
+}
+
+func (s *ShardedWallet) WalletDelete(ctx context.Context, addr address.Address) error {
+	// remove copies from all shards
+	shards, skipped := s.healthy(s.order(addr))
+	if len(skipped) > 0 {
+		// copies of the key may remain on skipped shards
+		return xerrors.Errorf("can't make sure the key is deleted from all shards: %w", skipped[0])
+	}
+	for _, sh := range shards {
+		have, err := sh.WalletHas(ctx, addr)
+		s.breakers.record(sh.Name, sh.WalletAPI, err)
+		if err != nil {
+			return xerrors.Errorf("checking shard %s: %w", sh.Name, err)
+		}
+		if !have {
+			continue
+		}
+
+		if err := sh.WalletDelete(ctx, addr); err != nil {
+			return xerrors.Errorf("deleting from shard %s: %w", sh.Name, err)
+		}
+	}
+
+	s.index.remove(addr)
+	return nil
+}
+
+var _ api.WalletAPI = &ShardedWallet{}
@@ -0,0 +1,112 @@
+package wallet
+
+import (
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// SimulatedDenial is a recorded sign request which a proposed policy would
+// have denied
+type SimulatedDenial struct {
+	Entry  api.AuditEntry
+	Reason error
+}
+
+// SimulatePolicies replays sign requests which succeeded according to the
+// audit log against proposed address policies, and returns the ones which the
+// proposed policies would deny. Addresses without a proposed policy are
+// unrestricted. Entries must be ordered oldest first, as returned by
+// WalletAuditQuery.
+//
+// Audit entries don't carry the full message, so fields which weren't
+// recorded (e.g. the gas fee cap of entries from older versions) aren't
+// checked. Deadline budgets aren't simulated either, as the gas limit of
+// messages isn't recorded.
+func SimulatePolicies(entries []api.AuditEntry, pols []api.AddressPolicy) []SimulatedDenial {
+	byAddr := map[address.Address]*api.SigningPolicy{}
+	for i := range pols {
+		byAddr[pols[i].Address] = &pols[i].Policy
+	}
+
+	type spend struct {
+		time  time.Time
+		value big.Int
+	}
+	spends := map[address.Address][]spend{}
+
+	var out []SimulatedDenial
+	for _, e := range entries {
+		if e.Op != AuditOpSign || e.Error != "" {
+			continue
+		}
+
+		pol, ok := byAddr[e.Address]
+		if !ok {
+			continue
+		}
+
+		if err := simulateCheck(pol, e); err != nil {
+			out = append(out, SimulatedDenial{Entry: e, Reason: err})
+			continue
+		}
+
+		if pol.DailyLimit == nil || e.Value == nil {
+			continue
+		}
+
+		// rolling window of requests the proposed policy let through
+		window := spends[e.Address][:0]
+		spent := big.Zero()
+		for _, s := range spends[e.Address] {
+			if e.Time.Sub(s.time) < SpendWindow {
+				window = append(window, s)
+				spent = big.Add(spent, s.value)
+			}
+		}
+		spends[e.Address] = window
+
+		if big.Add(spent, *e.Value).GreaterThan(*pol.DailyLimit) {
+			out = append(out, SimulatedDenial{
+				Entry:  e,
+				Reason: xerrors.Errorf("%w: value %s would exceed the daily limit of %s (%s spent in the last %s)", ErrPolicyViolation, types.FIL(*e.Value), types.FIL(*pol.DailyLimit), types.FIL(spent), SpendWindow),
+			})
+			continue
+		}
+
+		spends[e.Address] = append(window, spend{time: e.Time, value: *e.Value})
+	}
+
+	return out
+}
+
+func simulateCheck(pol *api.SigningPolicy, e api.AuditEntry) error {
+	if len(pol.AllowedTypes) > 0 {
+		allowed := false
+		for _, t := range pol.AllowedTypes {
+			if t == e.Type {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return xerrors.Errorf("%w: type %q not allowed", ErrPolicyViolation, e.Type)
+		}
+	}
+
+	if e.Type != api.MTChainMsg {
+		return nil
+	}
+	if e.To == nil {
+		// the live check fails on messages which can't be decoded
+		return xerrors.Errorf("%w: message wasn't decoded", ErrPolicyViolation)
+	}
+
+	return checkMsgPolicy(pol, *e.To, e.Value, e.GasFeeCap)
+}
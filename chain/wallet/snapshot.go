@@ -0,0 +1,211 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/backupds"
+)
+
+// SnapshotVersion is the version of the snapshot file format
+const SnapshotVersion = 1
+
+// scrypt parameters for deriving the snapshot key from a passphrase
+const (
+	snapshotScryptN = 1 << 18
+	snapshotScryptR = 8
+	snapshotScryptP = 1
+)
+
+// Snapshot is an encrypted copy of the keystore and the metadata datastore of
+// a wallet repo, taken at one point in time.
+type Snapshot struct {
+	Version     int
+	Created     time.Time
+	RepoVersion int
+
+	// scrypt parameters and salt of the key sealing Box
+	ScryptN, ScryptR, ScryptP int
+	Salt                      []byte
+
+	Nonce []byte
+	// nacl secretbox sealing the json-encoded snapshotContents
+	Box []byte
+}
+
+type snapshotContents struct {
+	Keys map[string]types.KeyInfo
+	// metadata datastore in the repo backup format
+	Metadata []byte
+}
+
+func snapshotKey(passphrase []byte, s *Snapshot) (*[32]byte, error) {
+	k, err := scrypt.Key(passphrase, s.Salt, s.ScryptN, s.ScryptR, s.ScryptP, 32)
+	if err != nil {
+		return nil, xerrors.Errorf("deriving snapshot key: %w", err)
+	}
+
+	var key [32]byte
+	copy(key[:], k)
+	return &key, nil
+}
+
+// WriteSnapshot writes an encrypted snapshot of all keys and metadata. The
+// repo must not be in use, so that keys and metadata are consistent with
+// each other.
+func WriteSnapshot(out io.Writer, ks types.KeyStore, ds datastore.Batching, passphrase []byte) error {
+	if len(passphrase) == 0 {
+		return xerrors.Errorf("snapshot passphrase can't be empty")
+	}
+
+	rv, err := GetRepoVersion(ds)
+	if err != nil {
+		return err
+	}
+
+	names, err := ks.List()
+	if err != nil {
+		return xerrors.Errorf("listing keys: %w", err)
+	}
+	c := snapshotContents{Keys: map[string]types.KeyInfo{}}
+	for _, n := range names {
+		ki, err := ks.Get(n)
+		if err != nil {
+			return xerrors.Errorf("getting key %s: %w", n, err)
+		}
+		c.Keys[n] = ki
+	}
+
+	var md bytes.Buffer
+	if err := backupds.Wrap(ds).Backup(&md); err != nil {
+		return xerrors.Errorf("backing up metadata: %w", err)
+	}
+	c.Metadata = md.Bytes()
+
+	plain, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	s := Snapshot{
+		Version:     SnapshotVersion,
+		Created:     time.Now(),
+		RepoVersion: rv,
+		ScryptN:     snapshotScryptN,
+		ScryptR:     snapshotScryptR,
+		ScryptP:     snapshotScryptP,
+		Salt:        make([]byte, 32),
+		Nonce:       make([]byte, 24),
+	}
+	if _, err := rand.Read(s.Salt); err != nil {
+		return err
+	}
+	if _, err := rand.Read(s.Nonce); err != nil {
+		return err
+	}
+
+	key, err := snapshotKey(passphrase, &s)
+	if err != nil {
+		return err
+	}
+	var nonce [24]byte
+	copy(nonce[:], s.Nonce)
+	s.Box = secretbox.Seal(nil, plain, &nonce, key)
+
+	return json.NewEncoder(out).Encode(s)
+}
+
+// readSnapshot decrypts a snapshot, returning its header and contents
+func readSnapshot(in io.Reader, passphrase []byte) (*Snapshot, *snapshotContents, error) {
+	b, err := ioutil.ReadAll(in)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("reading snapshot: %w", err)
+	}
+
+	var s Snapshot
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, nil, xerrors.Errorf("parsing snapshot: %w", err)
+	}
+	if s.Version != SnapshotVersion {
+		return nil, nil, xerrors.Errorf("unsupported snapshot version %d, expected %d", s.Version, SnapshotVersion)
+	}
+	if s.RepoVersion > RepoVersion {
+		return nil, nil, xerrors.Errorf("snapshot is of repo version %d, newer than the version supported by this build (%d)", s.RepoVersion, RepoVersion)
+	}
+	if len(s.Nonce) != 24 {
+		return nil, nil, xerrors.Errorf("malformed snapshot nonce")
+	}
+
+	key, err := snapshotKey(passphrase, &s)
+	if err != nil {
+		return nil, nil, err
+	}
+	var nonce [24]byte
+	copy(nonce[:], s.Nonce)
+	plain, ok := secretbox.Open(nil, s.Box, &nonce, key)
+	if !ok {
+		return nil, nil, xerrors.Errorf("decrypting snapshot failed, wrong passphrase or corrupted file")
+	}
+
+	var c snapshotContents
+	if err := json.Unmarshal(plain, &c); err != nil {
+		return nil, nil, xerrors.Errorf("parsing snapshot contents: %w", err)
+	}
+	return &s, &c, nil
+}
+
+// RestoreSnapshot replaces the metadata of a repo with the contents of a
+// snapshot and restores the keys of the snapshot. Keys not in the snapshot
+// are only removed when pruneKeys is set. After restoring, the metadata is
+// migrated to RepoVersion on the next start as usual.
+func RestoreSnapshot(in io.Reader, ks types.KeyStore, ds datastore.Batching, passphrase []byte, pruneKeys bool) (*Snapshot, error) {
+	s, c, err := readSnapshot(in, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	// restore metadata first, it is verified before anything is removed
+	if err := restoreRepoFrom(ds, bytes.NewReader(c.Metadata)); err != nil {
+		return nil, xerrors.Errorf("restoring metadata: %w", err)
+	}
+
+	if pruneKeys {
+		names, err := ks.List()
+		if err != nil {
+			return nil, xerrors.Errorf("listing keys: %w", err)
+		}
+		for _, n := range names {
+			if _, ok := c.Keys[n]; ok {
+				continue
+			}
+			if err := ks.Delete(n); err != nil {
+				return nil, xerrors.Errorf("removing key %s: %w", n, err)
+			}
+		}
+	}
+	for n, ki := range c.Keys {
+		if old, err := ks.Get(n); err == nil {
+			if old.Type == ki.Type && bytes.Equal(old.PrivateKey, ki.PrivateKey) {
+				continue
+			}
+			if err := ks.Delete(n); err != nil {
+				return nil, xerrors.Errorf("replacing key %s: %w", n, err)
+			}
+		}
+		if err := ks.Put(n, ki); err != nil {
+			return nil, xerrors.Errorf("restoring key %s: %w", n, err)
+		}
+	}
+
+	return s, nil
+}
@@ -0,0 +1,65 @@
+package wallet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	passphrase := []byte("snapshot passphrase")
+
+	ks := NewMemKeyStore()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	require.NoError(t, setRepoVersion(ds, RepoVersion))
+	require.NoError(t, ks.Put("wallet-a", types.KeyInfo{Type: types.KTSecp256k1, PrivateKey: []byte("key a")}))
+	require.NoError(t, ks.Put("wallet-b", types.KeyInfo{Type: types.KTBLS, PrivateKey: []byte("key b")}))
+	require.NoError(t, ds.Put(datastore.NewKey("/meta/a"), []byte("meta a")))
+
+	var buf bytes.Buffer
+	require.Error(t, WriteSnapshot(&buf, ks, ds, nil))
+	require.NoError(t, WriteSnapshot(&buf, ks, ds, passphrase))
+	snap := buf.Bytes()
+	require.NotContains(t, string(snap), "key a")
+	require.NotContains(t, string(snap), "meta a")
+
+	// change the repo after the snapshot was taken
+	require.NoError(t, ks.Delete("wallet-a"))
+	require.NoError(t, ks.Put("wallet-a", types.KeyInfo{Type: types.KTSecp256k1, PrivateKey: []byte("changed")}))
+	require.NoError(t, ks.Put("wallet-c", types.KeyInfo{Type: types.KTBLS, PrivateKey: []byte("key c")}))
+	require.NoError(t, ds.Put(datastore.NewKey("/meta/a"), []byte("changed")))
+	require.NoError(t, ds.Put(datastore.NewKey("/meta/b"), []byte("meta b")))
+
+	_, err := RestoreSnapshot(bytes.NewReader(snap), ks, ds, []byte("wrong"), false)
+	require.Error(t, err)
+	v, err := ds.Get(datastore.NewKey("/meta/a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("changed"), v, "failed restore must not touch the repo")
+
+	s, err := RestoreSnapshot(bytes.NewReader(snap), ks, ds, passphrase, false)
+	require.NoError(t, err)
+	require.Equal(t, RepoVersion, s.RepoVersion)
+
+	v, err = ds.Get(datastore.NewKey("/meta/a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("meta a"), v)
+	_, err = ds.Get(datastore.NewKey("/meta/b"))
+	require.Equal(t, datastore.ErrNotFound, err)
+
+	ki, err := ks.Get("wallet-a")
+	require.NoError(t, err)
+	require.Equal(t, []byte("key a"), ki.PrivateKey)
+	_, err = ks.Get("wallet-c")
+	require.NoError(t, err, "keys not in the snapshot are kept without pruning")
+
+	_, err = RestoreSnapshot(bytes.NewReader(snap), ks, ds, passphrase, true)
+	require.NoError(t, err)
+	names, err := ks.List()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"wallet-a", "wallet-b"}, names)
+}
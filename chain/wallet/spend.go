@@ -0,0 +1,245 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/retryhint"
+)
+
+var dsSpendPrefix = "/spend/"
+
+// SpendWindow is the rolling window over which SigningPolicy.DailyLimit
+// applies
+const SpendWindow = 24 * time.Hour
+
+type spendEntry struct {
+	Cid   cid.Cid
+	Time  time.Time
+	Value types.BigInt
+}
+
+func spendKey(addr address.Address) datastore.Key {
+	return datastore.NewKey(dsSpendPrefix + addr.String())
+}
+
+// spends returns spends of the address within the window ending now. Must be
+// called with p.spendLk held.
+func (p *PolicyWallet) spends(addr address.Address, now time.Time) ([]spendEntry, error) {
+	b, err := p.ds.Get(spendKey(addr))
+	if err == datastore.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("getting spends: %w", err)
+	}
+
+	var all []spendEntry
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, xerrors.Errorf("unmarshaling spends: %w", err)
+	}
+
+	out := all[:0]
+	for _, e := range all {
+		if now.Sub(e.Time) < SpendWindow {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (p *PolicyWallet) putSpends(addr address.Address, es []spendEntry) error {
+	if len(es) == 0 {
+		return p.ds.Delete(spendKey(addr))
+	}
+
+	b, err := json.Marshal(es)
+	if err != nil {
+		return xerrors.Errorf("marshaling spends: %w", err)
+	}
+	return p.ds.Put(spendKey(addr), b)
+}
+
+func sumSpends(es []spendEntry) types.BigInt {
+	sum := big.Zero()
+	for _, e := range es {
+		sum = big.Add(sum, e.Value)
+	}
+	return sum
+}
+
+// spendRef identifies the reservation of a message
+type spendRef struct {
+	signer address.Address
+	msg    cid.Cid
+}
+
+// pendingSpend tracks the sign requests in flight for a reserved message
+type pendingSpend struct {
+	requests int
+	signed   bool
+}
+
+// reserveSpend records the value of a message against the daily limit of the
+// signer, failing if the limit would be exceeded. Messages which were already
+// counted are not counted again. When enough spends will leave the window for
+// the message to fit, the error has a retry-after hint.
+//
+// The caller must call the returned function once the message is signed or
+// signing failed. A reservation is released when none of the requests for the
+// message got signed.
+func (p *PolicyWallet) reserveSpend(signer address.Address, limit types.BigInt, msg *types.Message) (func(signed bool), error) {
+	p.spendLk.Lock()
+	defer p.spendLk.Unlock()
+
+	es, err := p.spends(signer, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	mcid := msg.Cid()
+	ref := spendRef{signer: signer, msg: mcid}
+	for _, e := range es {
+		if e.Cid.Equals(mcid) {
+			// while the requests which reserved the message are in flight,
+			// the reservation must also outlive this one
+			if ps, ok := p.pendingSpends[ref]; ok {
+				ps.requests++
+				return p.spendDone(ref), nil
+			}
+			return func(bool) {}, nil
+		}
+	}
+
+	spent := sumSpends(es)
+	if big.Add(spent, msg.Value).GreaterThan(limit) {
+		err := xerrors.Errorf("%w: value %s would exceed the daily limit of %s (%s spent in the last %s)", ErrPolicyViolation, types.FIL(msg.Value), types.FIL(limit), types.FIL(spent), SpendWindow)
+		if after, ok := spendFitsAfter(es, limit, msg.Value, time.Now()); ok {
+			err = retryhint.Later(after, err)
+		}
+		return nil, err
+	}
+
+	if err := p.putSpends(signer, append(es, spendEntry{
+		Cid:   mcid,
+		Time:  time.Now(),
+		Value: msg.Value,
+	})); err != nil {
+		return nil, err
+	}
+
+	if p.pendingSpends == nil {
+		p.pendingSpends = map[spendRef]*pendingSpend{}
+	}
+	p.pendingSpends[ref] = &pendingSpend{requests: 1}
+	return p.spendDone(ref), nil
+}
+
+// spendDone returns the function a sign request calls when it is done with a
+// reservation
+func (p *PolicyWallet) spendDone(ref spendRef) func(signed bool) {
+	return func(signed bool) {
+		p.spendLk.Lock()
+		defer p.spendLk.Unlock()
+
+		ps := p.pendingSpends[ref]
+		ps.requests--
+		ps.signed = ps.signed || signed
+		if ps.requests > 0 {
+			return
+		}
+
+		delete(p.pendingSpends, ref)
+		if !ps.signed {
+			p.releaseSpend(ref.signer, ref.msg)
+		}
+	}
+}
+
+// spendFitsAfter returns how long until enough spends leave the window for
+// value to fit in the limit. Spends are ordered by time.
+func spendFitsAfter(es []spendEntry, limit, value types.BigInt, now time.Time) (time.Duration, bool) {
+	if value.GreaterThan(limit) {
+		return 0, false
+	}
+
+	remaining := sumSpends(es)
+	for _, e := range es {
+		remaining = big.Sub(remaining, e.Value)
+		if !big.Add(remaining, value).GreaterThan(limit) {
+			return e.Time.Add(SpendWindow).Sub(now), true
+		}
+	}
+	return 0, false
+}
+
+// releaseSpend removes a reservation made for a message which didn't get
+// signed. Must be called with p.spendLk held.
+func (p *PolicyWallet) releaseSpend(signer address.Address, mcid cid.Cid) {
+	es, err := p.spends(signer, time.Now())
+	if err == nil {
+		for i, e := range es {
+			if e.Cid.Equals(mcid) {
+				err = p.putSpends(signer, append(es[:i], es[i+1:]...))
+				break
+			}
+		}
+	}
+	if err != nil {
+		log.Errorw("releasing spend reservation", "signer", signer, "cid", mcid, "error", err)
+	}
+}
+
+func (p *PolicyWallet) WalletSpendGet(ctx context.Context, addr address.Address) (*api.SpendStatus, error) {
+	pol, err := p.WalletPolicyGet(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	p.spendLk.Lock()
+	es, err := p.spends(addr, time.Now())
+	p.spendLk.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	st := &api.SpendStatus{
+		Address: addr,
+		Window:  SpendWindow,
+		Spent:   sumSpends(es),
+		Count:   len(es),
+	}
+	if pol != nil {
+		st.Limit = pol.DailyLimit
+	}
+
+	groups, err := p.groupsOf(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		if g.Policy != nil && g.Policy.DailyLimit != nil && (st.Limit == nil || g.Policy.DailyLimit.LessThan(*st.Limit)) {
+			st.Limit = g.Policy.DailyLimit
+		}
+	}
+
+	return st, nil
+}
+
+func (p *PolicyWallet) WalletSpendReset(ctx context.Context, addr address.Address) error {
+	p.spendLk.Lock()
+	defer p.spendLk.Unlock()
+
+	log.Warnw("resetting spend counter", "address", addr)
+	return p.putSpends(addr, nil)
+}
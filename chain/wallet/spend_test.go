@@ -0,0 +1,75 @@
+package wallet
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func TestSpendLimitConcurrentSigns(t *testing.T) {
+	ctx := context.Background()
+	pw, signer := newTestPolicyWallet(t)
+
+	limit := types.NewInt(100)
+	require.NoError(t, pw.WalletPolicySet(ctx, signer, &api.SigningPolicy{DailyLimit: &limit}))
+
+	const n = 20
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		msg := testMessage(t, signer, 10)
+		msg.Nonce = uint64(i)
+		meta := chainMsgMeta(t, msg)
+		go func() {
+			_, err := pw.WalletSign(ctx, signer, msg.Cid().Bytes(), meta)
+			errs <- err
+		}()
+	}
+
+	signed := 0
+	for i := 0; i < n; i++ {
+		err := <-errs
+		if err == nil {
+			signed++
+			continue
+		}
+		require.True(t, xerrors.Is(err, ErrPolicyViolation), err)
+	}
+	require.Equal(t, 10, signed)
+
+	st, err := pw.WalletSpendGet(ctx, signer)
+	require.NoError(t, err)
+	require.Equal(t, 10, st.Count)
+	require.True(t, st.Spent.Equals(limit), st.Spent)
+}
+
+func TestSpendLimitSameMessageCountsOnce(t *testing.T) {
+	ctx := context.Background()
+	pw, signer := newTestPolicyWallet(t)
+
+	limit := types.NewInt(10)
+	require.NoError(t, pw.WalletPolicySet(ctx, signer, &api.SigningPolicy{DailyLimit: &limit}))
+
+	msg := testMessage(t, signer, 10)
+	meta := chainMsgMeta(t, msg)
+
+	const n = 10
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := pw.WalletSign(ctx, signer, msg.Cid().Bytes(), meta)
+			errs <- err
+		}()
+	}
+	for i := 0; i < n; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	st, err := pw.WalletSpendGet(ctx, signer)
+	require.NoError(t, err)
+	require.Equal(t, 1, st.Count)
+}
@@ -0,0 +1,202 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/multiformats/go-multihash"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+var dsSummaryPrefix = "/summary/"
+
+const summaryCacheSize = 1024
+
+// MsgSummaries decodes signed payloads once and keeps the result in the
+// datastore, keyed by CID, with the most recent ones cached in memory, so the
+// audit log, approvals and api clients share one decode path and don't
+// re-parse payloads of large batches. Payloads which aren't CIDs are keyed by
+// the raw sha256 CID of the signed bytes. Stored summaries are removed by
+// Prune.
+type MsgSummaries struct {
+	ds     datastore.Datastore
+	recent *lru.ARCCache
+}
+
+// storedSummary is a summary as kept in the datastore, with the time it was
+// stored at for retention
+type storedSummary struct {
+	api.MsgSummary
+	Stored time.Time
+}
+
+func NewMsgSummaries(ds datastore.Datastore) *MsgSummaries {
+	recent, _ := lru.NewARC(summaryCacheSize)
+	return &MsgSummaries{
+		ds:     ds,
+		recent: recent,
+	}
+}
+
+func summaryKey(c cid.Cid) datastore.Key {
+	return datastore.NewKey(dsSummaryPrefix + c.String())
+}
+
+// payloadCid returns the CID signed payloads are keyed by
+func payloadCid(toSign []byte) (cid.Cid, error) {
+	if _, c, err := cid.CidFromBytes(toSign); err == nil {
+		return c, nil
+	}
+	return cid.NewPrefixV1(cid.Raw, multihash.SHA2_256).Sum(toSign)
+}
+
+// Summarize returns the summary of a sign request, decoding it if it wasn't
+// seen before. It fails if a chain message doesn't match the signed bytes.
+func (s *MsgSummaries) Summarize(toSign []byte, meta api.MsgMeta) (*api.MsgSummary, error) {
+	key, err := payloadCid(toSign)
+	if err != nil {
+		return nil, xerrors.Errorf("computing payload cid: %w", err)
+	}
+
+	if sum, err := s.get(key); err == nil && sum.Type == meta.Type {
+		return sum, nil
+	}
+
+	sum := &api.MsgSummary{Type: meta.Type}
+	if meta.Type == api.MTChainMsg {
+		msg, err := decodeChainMsg(toSign, meta)
+		if err != nil {
+			return nil, err
+		}
+		c := msg.Cid()
+		sum.Cid = &c
+		sum.Message = msg
+	} else {
+		if _, c, err := cid.CidFromBytes(toSign); err == nil {
+			sum.Cid = &c
+		}
+		sum.Summary = describeMsg(toSign, meta)
+	}
+
+	b, err := json.Marshal(storedSummary{MsgSummary: *sum, Stored: time.Now()})
+	if err != nil {
+		return nil, xerrors.Errorf("marshaling summary: %w", err)
+	}
+	if err := s.ds.Put(summaryKey(key), b); err != nil {
+		// only a cache, the request can go on
+		log.Warnw("storing message summary", "cid", key, "error", err)
+	}
+	s.recent.Add(key, sum)
+
+	return sum, nil
+}
+
+func (s *MsgSummaries) get(key cid.Cid) (*api.MsgSummary, error) {
+	if v, ok := s.recent.Get(key); ok {
+		return v.(*api.MsgSummary), nil
+	}
+
+	b, err := s.ds.Get(summaryKey(key))
+	if err != nil {
+		return nil, err
+	}
+
+	var ss storedSummary
+	if err := json.Unmarshal(b, &ss); err != nil {
+		return nil, xerrors.Errorf("unmarshaling summary: %w", err)
+	}
+	s.recent.Add(key, &ss.MsgSummary)
+	return &ss.MsgSummary, nil
+}
+
+// WalletMsgSummary returns the summary of a payload signed, or asked to be
+// signed, by this wallet
+func (s *MsgSummaries) WalletMsgSummary(ctx context.Context, c cid.Cid) (*api.MsgSummary, error) {
+	sum, err := s.get(c)
+	if err == datastore.ErrNotFound {
+		return nil, xerrors.Errorf("no summary of %s", c)
+	}
+	return sum, err
+}
+
+// Prune removes summaries stored longer than maxAge ago, and then the oldest
+// summaries until at most maxEntries remain. Zero values disable the
+// respective limit. Returns the number of removed summaries.
+func (s *MsgSummaries) Prune(ctx context.Context, maxAge time.Duration, maxEntries int) (int, error) {
+	res, err := s.ds.Query(query.Query{Prefix: dsSummaryPrefix})
+	if err != nil {
+		return 0, xerrors.Errorf("querying message summaries: %w", err)
+	}
+	defer res.Close() //nolint:errcheck
+
+	type entry struct {
+		key string
+		t   time.Time
+	}
+	var entries []entry
+	for r := range res.Next() {
+		if r.Error != nil {
+			return 0, xerrors.Errorf("iterating message summaries: %w", r.Error)
+		}
+
+		var ss storedSummary
+		if err := json.Unmarshal(r.Value, &ss); err != nil {
+			log.Warnw("malformed message summary, treating as oldest", "key", r.Key, "error", err)
+		}
+		entries = append(entries, entry{key: r.Key, t: ss.Stored})
+	}
+
+	sort.Slice(entries, func(i, k int) bool {
+		return entries[i].t.Before(entries[k].t)
+	})
+
+	drop := 0
+	if maxAge > 0 {
+		cutoff := time.Now().Add(-maxAge)
+		for drop < len(entries) && entries[drop].t.Before(cutoff) {
+			drop++
+		}
+	}
+	if maxEntries > 0 && len(entries)-drop > maxEntries {
+		drop = len(entries) - maxEntries
+	}
+
+	for _, e := range entries[:drop] {
+		k := datastore.NewKey(e.key)
+		if err := s.ds.Delete(k); err != nil {
+			return 0, xerrors.Errorf("removing message summary: %w", err)
+		}
+		if c, err := cid.Decode(k.BaseNamespace()); err == nil {
+			s.recent.Remove(c)
+		}
+	}
+
+	return drop, nil
+}
+
+// RunRetention prunes stored summaries periodically until the context is
+// cancelled.
+func (s *MsgSummaries) RunRetention(ctx context.Context, interval, maxAge time.Duration, maxEntries int) {
+	for {
+		n, err := s.Prune(ctx, maxAge, maxEntries)
+		if err != nil {
+			log.Errorw("pruning message summaries", "error", err)
+		} else if n > 0 {
+			log.Infow("pruned message summaries", "removed", n)
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+func TestMsgSummariesPersistAndPrune(t *testing.T) {
+	ctx := context.Background()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	s := NewMsgSummaries(ds)
+
+	var cids []cid.Cid
+	for i := 0; i < 5; i++ {
+		payload := []byte(fmt.Sprintf("payload %d", i))
+		_, err := s.Summarize(payload, api.MsgMeta{Type: api.MTUnknown})
+		require.NoError(t, err)
+
+		c, err := payloadCid(payload)
+		require.NoError(t, err)
+		cids = append(cids, c)
+		time.Sleep(time.Millisecond)
+	}
+
+	// a restarted daemon finds the summaries in the datastore
+	s = NewMsgSummaries(ds)
+	for _, c := range cids {
+		sum, err := s.WalletMsgSummary(ctx, c)
+		require.NoError(t, err)
+		require.EqualValues(t, api.MTUnknown, sum.Type)
+	}
+
+	n, err := s.Prune(ctx, 0, 3)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	for i, c := range cids {
+		_, err := s.WalletMsgSummary(ctx, c)
+		if i < 2 {
+			require.Error(t, err, "summary %d", i)
+		} else {
+			require.NoError(t, err, "summary %d", i)
+		}
+	}
+
+	n, err = s.Prune(ctx, time.Hour, 0)
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	time.Sleep(10 * time.Millisecond)
+	n, err = s.Prune(ctx, 5*time.Millisecond, 0)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+}
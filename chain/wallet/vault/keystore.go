@@ -0,0 +1,206 @@
+// Package vault implements a key store backed by the key/value secrets engine
+// (version 2) of a HashiCorp Vault server, so that wallet keys are not stored
+// on the wallet host.
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+var log = logging.Logger("wallet-vault")
+
+type Config struct {
+	// Vault server url, e.g. https://vault.example.com:8200
+	Address string
+	Token   string
+
+	// Mount path of the kv v2 secrets engine
+	Mount string
+	// Path under the mount keys are stored at
+	Path string
+}
+
+// KeyStore stores every key as a secret under Config.Path. Keys are only
+// written once; deleting a key removes all its versions.
+type KeyStore struct {
+	cfg    Config
+	client *http.Client
+}
+
+var _ types.KeyStore = (*KeyStore)(nil)
+
+// NewKeyStore returns a key store using the given Vault server, after checking
+// that the server can be reached with the token.
+func NewKeyStore(cfg Config) (*KeyStore, error) {
+	if cfg.Address == "" || cfg.Token == "" {
+		return nil, xerrors.Errorf("vault address and token must be set")
+	}
+	if cfg.Mount == "" {
+		cfg.Mount = "secret"
+	}
+	cfg.Address = strings.TrimSuffix(cfg.Address, "/")
+	cfg.Mount = strings.Trim(cfg.Mount, "/")
+	cfg.Path = strings.Trim(cfg.Path, "/")
+
+	ks := &KeyStore{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+
+	if _, err := ks.List(); err != nil {
+		return nil, xerrors.Errorf("checking vault access: %w", err)
+	}
+
+	log.Infow("using vault key store", "address", cfg.Address, "mount", cfg.Mount, "path", cfg.Path)
+	return ks, nil
+}
+
+func (ks *KeyStore) url(kind string, name string) string {
+	p := ks.cfg.Mount + "/" + kind
+	if ks.cfg.Path != "" {
+		p += "/" + ks.cfg.Path
+	}
+	if name != "" {
+		p += "/" + url.PathEscape(name)
+	}
+	return ks.cfg.Address + "/v1/" + p
+}
+
+// do makes a request, decoding the response into out if it's not nil.
+// Returns the response status code.
+func (ks *KeyStore) do(method string, u string, body interface{}, out interface{}) (int, error) {
+	var rd io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return 0, xerrors.Errorf("marshaling request: %w", err)
+		}
+		rd = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, u, rd)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("X-Vault-Token", ks.cfg.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := ks.client.Do(req)
+	if err != nil {
+		return 0, xerrors.Errorf("vault request: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode >= 300 {
+		if resp.StatusCode != http.StatusNotFound && resp.StatusCode != http.StatusBadRequest {
+			msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<10))
+			return resp.StatusCode, xerrors.Errorf("vault %s request failed: %s: %s", method, resp.Status, strings.TrimSpace(string(msg)))
+		}
+		return resp.StatusCode, nil
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, xerrors.Errorf("decoding vault response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+func (ks *KeyStore) List() ([]string, error) {
+	var resp struct {
+		Data struct {
+			Keys []string `json:"keys"`
+		} `json:"data"`
+	}
+
+	code, err := ks.do("LIST", ks.url("metadata", ""), nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if code == http.StatusNotFound {
+		return nil, nil
+	}
+	if code == http.StatusBadRequest {
+		return nil, xerrors.Errorf("vault rejected list request")
+	}
+
+	out := make([]string, 0, len(resp.Data.Keys))
+	for _, k := range resp.Data.Keys {
+		if strings.HasSuffix(k, "/") {
+			continue // a sub-path, not a key
+		}
+		out = append(out, k)
+	}
+	return out, nil
+}
+
+func (ks *KeyStore) Get(name string) (types.KeyInfo, error) {
+	var resp struct {
+		Data struct {
+			Data *types.KeyInfo `json:"data"`
+		} `json:"data"`
+	}
+
+	code, err := ks.do(http.MethodGet, ks.url("data", name), nil, &resp)
+	if err != nil {
+		return types.KeyInfo{}, err
+	}
+	if code == http.StatusNotFound || (code < 300 && resp.Data.Data == nil) {
+		return types.KeyInfo{}, xerrors.Errorf("getting key '%s': %w", name, types.ErrKeyInfoNotFound)
+	}
+	if code == http.StatusBadRequest {
+		return types.KeyInfo{}, xerrors.Errorf("vault rejected get request for key '%s'", name)
+	}
+
+	return *resp.Data.Data, nil
+}
+
+func (ks *KeyStore) Put(name string, info types.KeyInfo) error {
+	req := map[string]interface{}{
+		// only write if the key doesn't exist yet
+		"options": map[string]interface{}{"cas": 0},
+		"data":    info,
+	}
+
+	code, err := ks.do(http.MethodPost, ks.url("data", name), req, nil)
+	if err != nil {
+		return err
+	}
+	if code == http.StatusBadRequest {
+		return xerrors.Errorf("putting key '%s': %w", name, types.ErrKeyExists)
+	}
+	if code == http.StatusNotFound {
+		return xerrors.Errorf("putting key '%s': mount %s not found", name, ks.cfg.Mount)
+	}
+	return nil
+}
+
+func (ks *KeyStore) Delete(name string) error {
+	if _, err := ks.Get(name); err != nil {
+		return err
+	}
+
+	code, err := ks.do(http.MethodDelete, ks.url("metadata", name), nil, nil)
+	if err != nil {
+		return err
+	}
+	if code >= 300 {
+		return xerrors.Errorf("deleting key '%s': vault returned %d", name, code)
+	}
+	return nil
+}
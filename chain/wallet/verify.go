@@ -0,0 +1,31 @@
+package wallet
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/lotus/lib/sigs"
+	"github.com/filecoin-project/lotus/metrics"
+)
+
+// verifyBackendSignature checks a signature returned by a backend which
+// doesn't hold its keys in this process (remote wallets, shards, hardware),
+// so that corrupted or malicious responses are never passed on to callers.
+func verifyBackendSignature(ctx context.Context, sig *crypto.Signature, signer address.Address, toSign []byte) error {
+	err := xerrors.Errorf("backend returned no signature")
+	if sig != nil {
+		err = sigs.Verify(sig, signer, toSign)
+	}
+	if err != nil {
+		stats.Record(ctx, metrics.WalletSignVerifyFailure.M(1))
+		log.Errorw("signature returned by wallet backend failed verification", "signer", signer, "error", err)
+		return xerrors.Errorf("signature returned by backend failed verification: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// Webhook formats
+const (
+	// POST {"text": "..."}, as expected by Slack incoming webhooks and the
+	// Telegram sendMessage method (with chat_id in the url query)
+	WebhookSlack    = "slack"
+	WebhookTelegram = "telegram"
+	// POST {"Event": "pending-sign", "Request": api.PendingSign}
+	WebhookGeneric = "generic"
+)
+
+const webhookTimeout = 10 * time.Second
+
+type Webhook struct {
+	Format string
+	URL    string
+}
+
+// ParseWebhook parses a webhook given as format=url, or just a url for the
+// generic format
+func ParseWebhook(s string) (Webhook, error) {
+	wh := Webhook{Format: WebhookGeneric, URL: s}
+	if kv := strings.SplitN(s, "=", 2); len(kv) == 2 && !strings.Contains(kv[0], "/") {
+		wh.Format, wh.URL = kv[0], kv[1]
+	}
+
+	switch wh.Format {
+	case WebhookSlack, WebhookTelegram, WebhookGeneric:
+	default:
+		return Webhook{}, xerrors.Errorf("unknown webhook format %q, expected slack, telegram or generic", wh.Format)
+	}
+	if !strings.HasPrefix(wh.URL, "http://") && !strings.HasPrefix(wh.URL, "https://") {
+		return Webhook{}, xerrors.Errorf("webhook url %q must be http(s)", wh.URL)
+	}
+	return wh, nil
+}
+
+// WebhookNotifier posts new pending sign requests to webhooks, so approvers
+// don't have to poll for them. Delivery is best effort: failures are logged
+// and not retried.
+type WebhookNotifier struct {
+	hooks  []Webhook
+	client *http.Client
+}
+
+func NewWebhookNotifier(hooks []Webhook) *WebhookNotifier {
+	return &WebhookNotifier{
+		hooks:  hooks,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// NotifyPending posts the request to all webhooks in the background
+func (n *WebhookNotifier) NotifyPending(p api.PendingSign) {
+	for _, wh := range n.hooks {
+		go func(wh Webhook) {
+			if err := n.post(wh, p); err != nil {
+				log.Warnw("sending sign request webhook", "format", wh.Format, "id", p.ID, "error", err)
+			}
+		}(wh)
+	}
+}
+
+func (n *WebhookNotifier) post(wh Webhook, p api.PendingSign) error {
+	var body interface{}
+	switch wh.Format {
+	case WebhookSlack, WebhookTelegram:
+		body = map[string]string{"text": DescribePending(p)}
+	default:
+		body = struct {
+			Event   string
+			Request api.PendingSign
+		}{
+			Event:   "pending-sign",
+			Request: p,
+		}
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.client.Post(wh.URL, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return xerrors.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// DescribePending returns a one line description of a pending sign request
+func DescribePending(p api.PendingSign) string {
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, "Sign request %d waiting for approval (%d needed): %s signing %s", p.ID, p.Required, p.Signer, p.Type)
+	if m := p.Message; m != nil {
+		_, _ = fmt.Fprintf(&sb, ", %s to %s, method %d", types.FIL(m.Value), m.To, m.Method)
+	} else if p.Summary != "" {
+		_, _ = fmt.Fprintf(&sb, ", %s", p.Summary)
+	}
+	if p.Caller != "" {
+		_, _ = fmt.Fprintf(&sb, ", from %s", p.Caller)
+	}
+	if p.Reason != "" {
+		_, _ = fmt.Fprintf(&sb, ", reason: %s", p.Reason)
+	}
+	return sb.String()
+}
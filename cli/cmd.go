@@ -56,6 +56,8 @@ func flagForAPI(t repo.RepoType) string {
 		return "miner-api-url"
 	case repo.Worker:
 		return "worker-api-url"
+	case repo.Wallet:
+		return "wallet-api-url"
 	default:
 		panic(fmt.Sprintf("Unknown repo type: %v", t))
 	}
@@ -69,6 +71,8 @@ func flagForRepo(t repo.RepoType) string {
 		return "miner-repo"
 	case repo.Worker:
 		return "worker-repo"
+	case repo.Wallet:
+		return "wallet-repo"
 	default:
 		panic(fmt.Sprintf("Unknown repo type: %v", t))
 	}
@@ -82,6 +86,8 @@ func envForRepo(t repo.RepoType) string {
 		return "MINER_API_INFO"
 	case repo.Worker:
 		return "WORKER_API_INFO"
+	case repo.Wallet:
+		return "WALLET_API_INFO"
 	default:
 		panic(fmt.Sprintf("Unknown repo type: %v", t))
 	}
@@ -96,6 +102,8 @@ func envForRepoDeprecation(t repo.RepoType) string {
 		return "STORAGE_API_INFO"
 	case repo.Worker:
 		return "WORKER_API_INFO"
+	case repo.Wallet:
+		return "WALLET_API_INFO"
 	default:
 		panic(fmt.Sprintf("Unknown repo type: %v", t))
 	}
@@ -260,6 +268,23 @@ func GetWorkerAPI(ctx *cli.Context) (api.WorkerAPI, jsonrpc.ClientCloser, error)
 	return client.NewWorkerRPC(ctx.Context, addr, headers)
 }
 
+func GetWalletAPI(ctx *cli.Context) (api.WalletDaemonAPI, jsonrpc.ClientCloser, error) {
+	addr, headers, err := GetRawAPI(ctx, repo.Wallet)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	headers, err = cliutil.SignWalletRequest(addr, headers)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cliutil.WalletTLSFromEnv().Apply(); err != nil {
+		return nil, nil, err
+	}
+
+	return client.NewWalletDaemonRPC(ctx.Context, addr, headers)
+}
+
 func GetGatewayAPI(ctx *cli.Context) (api.GatewayAPI, jsonrpc.ClientCloser, error) {
 	addr, headers, err := GetRawAPI(ctx, repo.FullNode)
 	if err != nil {
@@ -94,7 +94,7 @@ var sendCmd = &cli.Command{
 
 			fromAddr = defaddr
 		} else {
-			addr, err := address.NewFromString(from)
+			addr, err := ParseWalletAddress(ctx, api, from)
 			if err != nil {
 				return err
 			}
@@ -44,7 +44,13 @@ func (a APIInfo) DialArgs() (string, error) {
 			return "", err
 		}
 
-		return "ws://" + addr + "/rpc/v0", nil
+		// endpoints served over TLS end with /wss or /https
+		scheme := "ws"
+		if hasProtocol(ma, multiaddr.P_WSS) || hasProtocol(ma, multiaddr.P_HTTPS) {
+			scheme = "wss"
+		}
+
+		return scheme + "://" + addr + "/rpc/v0", nil
 	}
 
 	_, err = url.Parse(a.Addr)
@@ -54,6 +60,11 @@ func (a APIInfo) DialArgs() (string, error) {
 	return a.Addr + "/rpc/v0", nil
 }
 
+func hasProtocol(ma multiaddr.Multiaddr, code int) bool {
+	_, err := ma.ValueForProtocol(code)
+	return err == nil
+}
+
 func (a APIInfo) Host() (string, error) {
 	ma, err := multiaddr.NewMultiaddr(a.Addr)
 	if err == nil {
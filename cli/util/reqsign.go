@@ -0,0 +1,44 @@
+package cliutil
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/lib/reqsign"
+)
+
+// WalletRequestSigningKeyEnv holds the shared key wallet api clients sign
+// requests with, for wallet daemons run with --request-signing-key. The daemon
+// reads its key from the same variable.
+const WalletRequestSigningKeyEnv = "LOTUS_WALLET_REQUEST_SIGNING_KEY"
+
+// SignWalletRequest adds request signature headers for the wallet api at addr
+// to headers, if WalletRequestSigningKeyEnv is set. Websocket clients only sign
+// the handshake request.
+func SignWalletRequest(addr string, headers http.Header) (http.Header, error) {
+	key, ok := os.LookupEnv(WalletRequestSigningKeyEnv)
+	if !ok {
+		return headers, nil
+	}
+
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, xerrors.Errorf("parsing wallet api URL: %w", err)
+	}
+
+	sh, err := reqsign.Headers([]byte(key), http.MethodGet, u.Path, nil)
+	if err != nil {
+		return nil, xerrors.Errorf("signing request: %w", err)
+	}
+
+	if headers == nil {
+		headers = http.Header{}
+	}
+	for k, v := range sh {
+		headers[k] = v
+	}
+	return headers, nil
+}
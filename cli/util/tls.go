@@ -0,0 +1,82 @@
+package cliutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/xerrors"
+)
+
+// Environment variables holding the client TLS settings of wallet api clients,
+// see TLSClientConfig
+const (
+	WalletTLSCertEnv = "LOTUS_WALLET_TLS_CERT"
+	WalletTLSKeyEnv  = "LOTUS_WALLET_TLS_KEY"
+	WalletTLSCAEnv   = "LOTUS_WALLET_TLS_CA"
+)
+
+// TLSClientConfig holds paths of PEM files used when connecting to wallet
+// daemons serving the api over TLS, possibly requiring client certificates.
+type TLSClientConfig struct {
+	// Client certificate and key presented to servers asking for one
+	Cert string
+	Key  string
+	// CA bundle server certificates are verified against, on top of the
+	// system roots
+	CA string
+}
+
+// WalletTLSFromEnv returns the client TLS settings in the WalletTLS*Env
+// variables
+func WalletTLSFromEnv() TLSClientConfig {
+	return TLSClientConfig{
+		Cert: os.Getenv(WalletTLSCertEnv),
+		Key:  os.Getenv(WalletTLSKeyEnv),
+		CA:   os.Getenv(WalletTLSCAEnv),
+	}
+}
+
+// Apply makes websocket api clients of this process use the settings. The
+// JSON-RPC client dials with the default websocket dialer, so the settings
+// apply to all websocket connections of the process. Apply does nothing when
+// no setting is set.
+func (c TLSClientConfig) Apply() error {
+	if c.Cert == "" && c.Key == "" && c.CA == "" {
+		return nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.Cert != "" || c.Key != "" {
+		if c.Cert == "" || c.Key == "" {
+			return xerrors.Errorf("client tls certificate and key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+		if err != nil {
+			return xerrors.Errorf("loading client tls key pair: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if c.CA != "" {
+		caPEM, err := ioutil.ReadFile(c.CA)
+		if err != nil {
+			return xerrors.Errorf("reading tls CA: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			log.Warnf("loading system certificate pool, only trusting %s: %s", c.CA, err)
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return xerrors.Errorf("no certificates found in tls CA file %s", c.CA)
+		}
+		cfg.RootCAs = pool
+	}
+
+	websocket.DefaultDialer.TLSClientConfig = cfg
+	return nil
+}
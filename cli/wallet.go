@@ -184,7 +184,7 @@ var walletBalance = &cli.Command{
 
 		var addr address.Address
 		if cctx.Args().First() != "" {
-			addr, err = address.NewFromString(cctx.Args().First())
+			addr, err = ParseWalletAddress(ctx, api, cctx.Args().First())
 		} else {
 			addr, err = api.WalletDefaultAddress(ctx)
 		}
@@ -244,7 +244,7 @@ var walletSetDefault = &cli.Command{
 			return fmt.Errorf("must pass address to set as default")
 		}
 
-		addr, err := address.NewFromString(cctx.Args().First())
+		addr, err := ParseWalletAddress(ctx, api, cctx.Args().First())
 		if err != nil {
 			return err
 		}
@@ -269,7 +269,7 @@ var walletExport = &cli.Command{
 			return fmt.Errorf("must specify key to export")
 		}
 
-		addr, err := address.NewFromString(cctx.Args().First())
+		addr, err := ParseWalletAddress(ctx, api, cctx.Args().First())
 		if err != nil {
 			return err
 		}
@@ -402,7 +402,7 @@ var walletSign = &cli.Command{
 			return fmt.Errorf("must specify signing address and message to sign")
 		}
 
-		addr, err := address.NewFromString(cctx.Args().First())
+		addr, err := ParseWalletAddress(ctx, api, cctx.Args().First())
 
 		if err != nil {
 			return err
@@ -443,7 +443,7 @@ var walletVerify = &cli.Command{
 			return fmt.Errorf("must specify signing address, message, and signature to verify")
 		}
 
-		addr, err := address.NewFromString(cctx.Args().First())
+		addr, err := ParseWalletAddress(ctx, api, cctx.Args().First())
 
 		if err != nil {
 			return err
@@ -495,7 +495,7 @@ var walletDelete = &cli.Command{
 			return fmt.Errorf("must specify address to delete")
 		}
 
-		addr, err := address.NewFromString(cctx.Args().First())
+		addr, err := ParseWalletAddress(ctx, api, cctx.Args().First())
 		if err != nil {
 			return err
 		}
@@ -538,7 +538,7 @@ var walletMarketWithdraw = &cli.Command{
 
 		var from address.Address
 		if cctx.String("from") != "" {
-			from, err = address.NewFromString(cctx.String("from"))
+			from, err = ParseWalletAddress(ctx, api, cctx.String("from"))
 			if err != nil {
 				return xerrors.Errorf("parsing from address: %w", err)
 			}
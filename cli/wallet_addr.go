@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+)
+
+// minAddrPrefixLen is the shortest address prefix accepted for matching
+// against wallet addresses. Anything shorter is too likely to be a typo.
+const minAddrPrefixLen = 6
+
+type walletLister interface {
+	WalletList(context.Context) ([]address.Address, error)
+}
+
+// ParseWalletAddress parses s as an address. If s isn't a valid address it is
+// treated as a prefix and matched against addresses known to the wallet. The
+// match must be unambiguous.
+func ParseWalletAddress(ctx context.Context, w walletLister, s string) (address.Address, error) {
+	a, err := address.NewFromString(s)
+	if err == nil {
+		return a, nil
+	}
+	if len(s) < minAddrPrefixLen {
+		return address.Undef, xerrors.Errorf("parsing address %q: %w", s, err)
+	}
+
+	addrs, lerr := w.WalletList(ctx)
+	if lerr != nil {
+		return address.Undef, xerrors.Errorf("parsing address %q: %w (listing wallet for prefix match: %s)", s, err, lerr)
+	}
+
+	matches := matchAddrPrefix(addrs, s)
+	switch len(matches) {
+	case 0:
+		return address.Undef, xerrors.Errorf("parsing address %q: %w (no wallet address has this prefix)", s, err)
+	case 1:
+		return matches[0], nil
+	default:
+		strs := make([]string, len(matches))
+		for i, m := range matches {
+			strs[i] = m.String()
+		}
+		return address.Undef, xerrors.Errorf("address prefix %q is ambiguous, matches: %s", s, strings.Join(strs, ", "))
+	}
+}
+
+// matchAddrPrefix returns the addresses which start with the given prefix. The
+// network prefix (f/t) is ignored.
+func matchAddrPrefix(addrs []address.Address, prefix string) []address.Address {
+	prefix = strings.ToLower(prefix)
+	var out []address.Address
+	for _, a := range addrs {
+		if strings.HasPrefix(a.String()[1:], prefix[1:]) {
+			out = append(out, a)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].String() < out[j].String()
+	})
+
+	return out
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+)
+
+type staticLister []address.Address
+
+func (l staticLister) WalletList(context.Context) ([]address.Address, error) {
+	return l, nil
+}
+
+func TestParseWalletAddress(t *testing.T) {
+	ctx := context.Background()
+
+	// find two addresses sharing a prefix to test disambiguation
+	var a1, a2 address.Address
+	seen := map[string]address.Address{}
+	for i := uint64(0); a2 == address.Undef; i++ {
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], i)
+		a, err := address.NewSecp256k1Address(b[:])
+		require.NoError(t, err)
+
+		p := a.String()[:minAddrPrefixLen]
+		if o, ok := seen[p]; ok {
+			a1, a2 = o, a
+		}
+		seen[p] = a
+	}
+
+	a3, err := address.NewSecp256k1Address([]byte("unique"))
+	require.NoError(t, err)
+
+	w := staticLister{a1, a2, a3}
+
+	// full addresses are parsed without consulting the wallet
+	a, err := ParseWalletAddress(ctx, staticLister{}, a1.String())
+	require.NoError(t, err)
+	require.Equal(t, a1, a)
+
+	a, err = ParseWalletAddress(ctx, w, a3.String()[:10])
+	require.NoError(t, err)
+	require.Equal(t, a3, a)
+
+	// network prefix is ignored
+	a, err = ParseWalletAddress(ctx, w, "t"+a3.String()[1:10])
+	require.NoError(t, err)
+	require.Equal(t, a3, a)
+
+	_, err = ParseWalletAddress(ctx, w, a1.String()[:minAddrPrefixLen])
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "ambiguous")
+
+	_, err = ParseWalletAddress(ctx, w, a3.String()[:minAddrPrefixLen-1])
+	require.Error(t, err)
+
+	_, err = ParseWalletAddress(ctx, w, "f1zzzzzzzz")
+	require.Error(t, err)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/wallet"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var approvalsCmd = &cli.Command{
+	Name:  "approvals",
+	Usage: "Manage sign requests waiting for approval (requires run --manual-approval or --approval-threshold)",
+	Subcommands: []*cli.Command{
+		approvalsListCmd,
+		approvalsWatchCmd,
+		approvalsApproveCmd,
+		approvalsRejectCmd,
+	},
+}
+
+var approvalsListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "List pending sign requests",
+	Flags: []cli.Flag{
+		&lcli.CidBaseFlag,
+	},
+	Action: func(cctx *cli.Context) error {
+		enc, err := lcli.GetCidEncoder(cctx)
+		if err != nil {
+			return err
+		}
+
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		pending, err := api.WalletApprovalList(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "ID\tReceived\tSigner\tType\tTo\tValue\tMethod\tPayload\tCaller\tApprovals\tReason")
+		for _, p := range pending {
+			to, value, method, c := "-", "-", "-", "-"
+			if p.Message != nil {
+				to = p.Message.To.String()
+				value = types.FIL(p.Message.Value).String()
+				method = fmt.Sprint(p.Message.Method)
+			}
+			if p.Summary != "" {
+				c = p.Summary
+			} else if p.Cid != nil {
+				c = enc.Encode(*p.Cid)
+			}
+
+			_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d/%d\t%s\n",
+				p.ID, p.Received.Format("2006-01-02 15:04:05"), p.Signer, p.Type, to, value, method, c, p.Caller, len(p.Approvals), p.Required, p.Reason)
+		}
+		return tw.Flush()
+	},
+}
+
+var approvalsWatchCmd = &cli.Command{
+	Name:  "watch",
+	Usage: "Print pending sign requests, then new ones as they arrive",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		sub, err := api.WalletApprovalSubscribe(ctx)
+		if err != nil {
+			return err
+		}
+
+		for p := range sub {
+			fmt.Printf("%s  %s\n", p.Received.Format("2006-01-02 15:04:05"), wallet.DescribePending(p))
+		}
+		return ctx.Err()
+	},
+}
+
+var approvalsApproveCmd = &cli.Command{
+	Name:      "approve",
+	Usage:     "Approve a pending sign request",
+	ArgsUsage: "<id>",
+	Description: `Shows the decoded request, including the recipient, value, method and gas
+   fee cap of chain messages, and asks for confirmation before approving.`,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "yes",
+			Usage: "approve without showing the request and asking for confirmation",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		return decideApproval(cctx, true)
+	},
+}
+
+var approvalsRejectCmd = &cli.Command{
+	Name:      "reject",
+	Usage:     "Reject a pending sign request",
+	ArgsUsage: "<id>",
+	Action: func(cctx *cli.Context) error {
+		return decideApproval(cctx, false)
+	},
+}
+
+func decideApproval(cctx *cli.Context, approve bool) error {
+	if cctx.NArg() != 1 {
+		return xerrors.Errorf("must specify the request id")
+	}
+
+	id, err := strconv.ParseUint(cctx.Args().First(), 10, 64)
+	if err != nil {
+		return xerrors.Errorf("parsing request id: %w", err)
+	}
+
+	api, closer, err := lcli.GetWalletAPI(cctx)
+	if err != nil {
+		return err
+	}
+	defer closer()
+	ctx := lcli.ReqContext(cctx)
+
+	if approve && !cctx.Bool("yes") {
+		pending, err := api.WalletApprovalList(ctx)
+		if err != nil {
+			return err
+		}
+
+		found := false
+		for _, p := range pending {
+			if p.ID != id {
+				continue
+			}
+			found = true
+
+			if err := printSignPreview(os.Stdout, p); err != nil {
+				return err
+			}
+		}
+		if !found {
+			return xerrors.Errorf("no pending sign request with id %d", id)
+		}
+
+		ok, err := confirm("Approve this request?")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return xerrors.Errorf("not approved")
+		}
+	}
+
+	return api.WalletApprovalDecide(ctx, id, approve)
+}
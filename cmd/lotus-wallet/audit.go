@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var auditCmd = &cli.Command{
+	Name:  "audit",
+	Usage: "Query the log of sign, export and delete requests of a running lotus wallet",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "address",
+			Usage: "only show entries for this address",
+		},
+		&cli.StringFlag{
+			Name:  "since",
+			Usage: "only show entries recorded after this time (RFC3339, or a duration like '24h' meaning that long ago)",
+		},
+		&cli.StringFlag{
+			Name:  "until",
+			Usage: "only show entries recorded before this time (RFC3339, or a duration like '1h' meaning that long ago)",
+		},
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "show at most this many of the most recent matching entries (0 for no limit)",
+			Value: 100,
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "print entries as JSON",
+		},
+		&lcli.CidBaseFlag,
+	},
+	Action: func(cctx *cli.Context) error {
+		f := api.AuditFilter{
+			Limit: cctx.Int("limit"),
+		}
+
+		if s := cctx.String("address"); s != "" {
+			a, err := address.NewFromString(s)
+			if err != nil {
+				return xerrors.Errorf("parsing address: %w", err)
+			}
+			f.Address = &a
+		}
+
+		enc, err := lcli.GetCidEncoder(cctx)
+		if err != nil {
+			return err
+		}
+
+		if f.Since, err = parseAuditTime(cctx.String("since")); err != nil {
+			return xerrors.Errorf("parsing --since: %w", err)
+		}
+		if f.Until, err = parseAuditTime(cctx.String("until")); err != nil {
+			return xerrors.Errorf("parsing --until: %w", err)
+		}
+
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		entries, err := api.WalletAuditQuery(ctx, f)
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("json") {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(entries)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Time\tOp\tAddress\tCaller\tPayload\tTo\tMethod\tValue\tResult")
+		for _, e := range entries {
+			c, to, method, value := "-", "-", "-", "-"
+			if e.Summary != "" {
+				c = e.Summary
+			} else if e.Cid != nil {
+				c = enc.Encode(*e.Cid)
+			}
+			if e.To != nil {
+				to = e.To.String()
+				method = fmt.Sprint(e.Method)
+			}
+			if e.Value != nil {
+				value = types.FIL(*e.Value).String()
+			}
+			res := "ok"
+			if e.Error != "" {
+				res = "error: " + e.Error
+			}
+
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				e.Time.Format("2006-01-02 15:04:05"), e.Op, e.Address, e.Caller, c, to, method, value, res)
+		}
+		return tw.Flush()
+	},
+}
+
+// parseAuditTime parses an RFC3339 time, or a duration meaning that long ago.
+// Durations may also be given in days, like '30d'. An empty string gives the
+// zero time.
+func parseAuditTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if days, err := strconv.Atoi(strings.TrimSuffix(s, "d")); err == nil && strings.HasSuffix(s, "d") {
+		return time.Now().AddDate(0, 0, -days), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,577 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-jsonrpc/auth"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/apistruct"
+	"github.com/filecoin-project/lotus/chain/wallet"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/node/repo"
+)
+
+var dsTokenPrefix = "/tokens/"
+
+// how often the last use of a token is persisted
+const tokenLastUsedResolution = time.Minute
+
+// walletAuth issues and verifies api tokens signed with the wallet repo JWT
+// secret. Issued tokens carry an ID under which their metadata is kept in the
+// datastore, so they can be listed and revoked. Tokens without an ID, issued
+// before IDs were introduced, are rejected. Revoking a token closes the
+// websocket connections opened with it.
+type walletAuth struct {
+	secret *jwt.HMACSHA
+	ds     datastore.Datastore
+	seclog *wallet.SecurityLog
+
+	// serializes read-modify-writes of token records, so that last use
+	// updates don't overwrite revocations
+	lk sync.Mutex
+
+	connLk sync.Mutex
+	conns  map[string]map[net.Conn]struct{}
+}
+
+// tokenPayload is the JWT payload of wallet api tokens
+type tokenPayload struct {
+	Allow []auth.Permission
+	ID    string `json:",omitempty"`
+
+	// Set on tokens identifying a registered approver
+	Approver string `json:",omitempty"`
+	// Restrictions on top of Allow, see api.TokenScope*
+	Scopes []string `json:",omitempty"`
+}
+
+type scopesKey struct{}
+
+// hasScope returns whether the token a request was made with has the scope
+func hasScope(ctx context.Context, scope string) bool {
+	scopes, _ := ctx.Value(scopesKey{}).([]string)
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func tokenKey(id string) datastore.Key {
+	return datastore.NewKey(dsTokenPrefix + id)
+}
+
+func (a *walletAuth) getToken(id string) (*api.TokenInfo, error) {
+	b, err := a.ds.Get(tokenKey(id))
+	if err == datastore.ErrNotFound {
+		return nil, xerrors.Errorf("unknown token %s", id)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("getting token: %w", err)
+	}
+
+	var ti api.TokenInfo
+	if err := json.Unmarshal(b, &ti); err != nil {
+		return nil, xerrors.Errorf("unmarshaling token info: %w", err)
+	}
+	return &ti, nil
+}
+
+func (a *walletAuth) putToken(ti *api.TokenInfo) error {
+	b, err := json.Marshal(ti)
+	if err != nil {
+		return xerrors.Errorf("marshaling token info: %w", err)
+	}
+	return a.ds.Put(tokenKey(ti.ID), b)
+}
+
+func (a *walletAuth) verify(token string) (*tokenPayload, error) {
+	var payload tokenPayload
+	if _, err := jwt.Verify([]byte(token), a.secret, &payload); err != nil {
+		return nil, xerrors.Errorf("JWT Verification failed: %w", err)
+	}
+	if payload.ID == "" {
+		return nil, xerrors.Errorf("token has no ID, it was issued before tokens were tracked and has to be replaced (see auth create-token)")
+	}
+
+	ti, err := a.getToken(payload.ID)
+	if err != nil {
+		return nil, err
+	}
+	if ti.Revoked != nil {
+		return nil, xerrors.Errorf("token %s was revoked", ti.ID)
+	}
+
+	// don't write to the datastore on every request
+	if time.Since(ti.LastUsed) > tokenLastUsedResolution {
+		if err := a.touchToken(ti.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return &payload, nil
+}
+
+// touchToken records the use of a token, failing if it was revoked since it
+// was last read
+func (a *walletAuth) touchToken(id string) error {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	ti, err := a.getToken(id)
+	if err != nil {
+		return err
+	}
+	if ti.Revoked != nil {
+		return xerrors.Errorf("token %s was revoked", ti.ID)
+	}
+	if time.Since(ti.LastUsed) <= tokenLastUsedResolution {
+		return nil
+	}
+
+	ti.LastUsed = time.Now()
+	if err := a.putToken(ti); err != nil {
+		log.Warnw("updating token last use", "id", ti.ID, "error", err)
+	}
+	return nil
+}
+
+func (a *walletAuth) AuthVerify(ctx context.Context, token string) ([]auth.Permission, error) {
+	payload, err := a.verify(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return payload.Allow, nil
+}
+
+func (a *walletAuth) newToken(ctx context.Context, perms []auth.Permission, approver string, scopes []string) ([]byte, error) {
+	for _, s := range scopes {
+		if s != api.TokenScopeHasOnly {
+			return nil, xerrors.Errorf("unknown token scope %q", s)
+		}
+	}
+
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, xerrors.Errorf("generating token id: %w", err)
+	}
+
+	ti := &api.TokenInfo{
+		ID:        hex.EncodeToString(id[:]),
+		Perms:     perms,
+		Approver:  approver,
+		Scopes:    scopes,
+		CreatedBy: wallet.CallerFromContext(ctx),
+		Created:   time.Now(),
+	}
+	if err := a.putToken(ti); err != nil {
+		return nil, err
+	}
+	a.seclog.Record(ctx, wallet.SecEvTokenNew, ti.ID, fmt.Sprintf("perms: %v, approver: %q, scopes: %v", perms, approver, scopes))
+
+	return jwt.Sign(&tokenPayload{
+		Allow:    perms,
+		ID:       ti.ID,
+		Approver: approver,
+		Scopes:   scopes,
+	}, a.secret)
+}
+
+// ensureRepoToken makes sure the admin token in the repo, which local clients
+// use, is tracked in the token store. The token modules.APISecret writes on
+// first run has no ID, it gets replaced with one that can be listed and
+// revoked. A revoked repo token is replaced as well.
+func (a *walletAuth) ensureRepoToken(ctx context.Context, r repo.Repo, lr repo.LockedRepo) error {
+	if tok, err := r.APIToken(); err == nil {
+		if _, err := a.verify(string(tok)); err == nil {
+			return nil
+		}
+	}
+
+	tok, err := a.newToken(wallet.WithCaller(ctx, "repo"), apistruct.AllPermissions, "", nil)
+	if err != nil {
+		return xerrors.Errorf("issuing repo api token: %w", err)
+	}
+	if err := lr.SetAPIToken(tok); err != nil {
+		return xerrors.Errorf("setting repo api token: %w", err)
+	}
+	log.Info("issued a new repo api token")
+	return nil
+}
+
+func (a *walletAuth) AuthNew(ctx context.Context, perms []auth.Permission) ([]byte, error) {
+	return a.newToken(ctx, perms, "", nil)
+}
+
+func (a *walletAuth) AuthNewScoped(ctx context.Context, perms []auth.Permission, scopes []string) ([]byte, error) {
+	return a.newToken(ctx, perms, "", scopes)
+}
+
+func (a *walletAuth) newApproverToken(ctx context.Context, name string) ([]byte, error) {
+	return a.newToken(ctx, []auth.Permission{apistruct.PermRead}, name, nil)
+}
+
+func (a *walletAuth) AuthList(ctx context.Context) ([]api.TokenInfo, error) {
+	res, err := a.ds.Query(query.Query{Prefix: dsTokenPrefix})
+	if err != nil {
+		return nil, xerrors.Errorf("querying tokens: %w", err)
+	}
+	defer res.Close() //nolint:errcheck
+
+	var out []api.TokenInfo
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, xerrors.Errorf("iterating tokens: %w", r.Error)
+		}
+
+		var ti api.TokenInfo
+		if err := json.Unmarshal(r.Value, &ti); err != nil {
+			return nil, xerrors.Errorf("unmarshaling token info %s: %w", r.Key, err)
+		}
+		out = append(out, ti)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Created.Before(out[j].Created)
+	})
+
+	return out, nil
+}
+
+func (a *walletAuth) AuthRevoke(ctx context.Context, id string) error {
+	if err := a.revoke(ctx, id); err != nil {
+		return err
+	}
+
+	if n := a.closeConns(id); n > 0 {
+		log.Warnw("closed connections of revoked api token", "id", id, "connections", n)
+	}
+	return nil
+}
+
+func (a *walletAuth) revoke(ctx context.Context, id string) error {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	ti, err := a.getToken(id)
+	if err != nil {
+		return err
+	}
+	if ti.Revoked != nil {
+		return nil
+	}
+
+	now := time.Now()
+	ti.Revoked = &now
+
+	log.Warnw("revoking api token", "id", id, "perms", ti.Perms, "by", wallet.CallerFromContext(ctx))
+	if err := a.putToken(ti); err != nil {
+		return err
+	}
+
+	a.seclog.Record(ctx, wallet.SecEvTokenRevoke, id, "")
+	return nil
+}
+
+// trackConn registers a connection opened with a token, so that it is closed
+// when the token gets revoked. It fails if the token was revoked already.
+func (a *walletAuth) trackConn(id string, c net.Conn) error {
+	a.connLk.Lock()
+	if a.conns == nil {
+		a.conns = map[string]map[net.Conn]struct{}{}
+	}
+	if a.conns[id] == nil {
+		a.conns[id] = map[net.Conn]struct{}{}
+	}
+	a.conns[id][c] = struct{}{}
+	a.connLk.Unlock()
+
+	// AuthRevoke marks the token before closing its connections, so either
+	// it sees this connection, or this sees the revocation
+	ti, err := a.getToken(id)
+	if err == nil && ti.Revoked != nil {
+		err = xerrors.Errorf("token %s was revoked", id)
+	}
+	if err != nil {
+		a.untrackConn(id, c)
+		return err
+	}
+	return nil
+}
+
+func (a *walletAuth) untrackConn(id string, c net.Conn) {
+	a.connLk.Lock()
+	defer a.connLk.Unlock()
+
+	delete(a.conns[id], c)
+	if len(a.conns[id]) == 0 {
+		delete(a.conns, id)
+	}
+}
+
+// closeConns closes the connections opened with a token, returning how many
+// were closed
+func (a *walletAuth) closeConns(id string) int {
+	a.connLk.Lock()
+	conns := a.conns[id]
+	delete(a.conns, id)
+	a.connLk.Unlock()
+
+	for c := range conns {
+		if err := c.Close(); err != nil {
+			log.Debugw("closing connection of revoked token", "id", id, "error", err)
+		}
+	}
+	return len(conns)
+}
+
+// tokenConnWriter tracks the connection of websocket requests made with a
+// token once it is hijacked from the http server
+type tokenConnWriter struct {
+	http.ResponseWriter
+
+	a    *walletAuth
+	id   string
+	conn net.Conn
+}
+
+func (w *tokenConnWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, xerrors.Errorf("response writer doesn't support hijacking")
+	}
+
+	c, rw, err := h.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := w.a.trackConn(w.id, c); err != nil {
+		_ = c.Close()
+		return nil, nil, err
+	}
+	w.conn = c
+	return c, rw, nil
+}
+
+// withTokenClaims marks requests with the approver identity and scopes of the
+// token they were made with, and tracks websocket connections opened with
+// tokens that can be revoked
+func (a *walletAuth) withTokenClaims(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("Authorization")
+		if token == "" {
+			token = "Bearer " + r.FormValue("token")
+		}
+
+		if strings.HasPrefix(token, "Bearer ") {
+			if payload, err := a.verify(strings.TrimPrefix(token, "Bearer ")); err == nil {
+				ctx := r.Context()
+				if payload.Approver != "" {
+					ctx = wallet.WithApprover(ctx, payload.Approver)
+				}
+				if len(payload.Scopes) > 0 {
+					ctx = context.WithValue(ctx, scopesKey{}, payload.Scopes)
+				}
+				r = r.WithContext(ctx)
+
+				upgrade := strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+				if upgrade {
+					tw := &tokenConnWriter{ResponseWriter: w, a: a, id: payload.ID}
+					defer func() {
+						if tw.conn != nil {
+							a.untrackConn(tw.id, tw.conn)
+						}
+					}()
+					w = tw
+				}
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+var authCmd = &cli.Command{
+	Name:  "auth",
+	Usage: "Manage RPC permissions",
+	Subcommands: []*cli.Command{
+		authCreateTokenCmd,
+		authCreateApproverTokenCmd,
+		authListCmd,
+		authRevokeCmd,
+	},
+}
+
+var authCreateTokenCmd = &cli.Command{
+	Name:  "create-token",
+	Usage: "Create token",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "perm",
+			Usage:    "permission to assign to the token, one of: read, write, sign, admin",
+			Required: true,
+		},
+		&cli.BoolFlag{
+			Name:  "has-only",
+			Usage: "don't allow the token to list wallet addresses, only to check for specific ones with WalletHas",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		perm := cctx.String("perm")
+		idx := 0
+		for i, p := range apistruct.AllPermissions {
+			if auth.Permission(perm) == p {
+				idx = i + 1
+			}
+		}
+
+		if idx == 0 {
+			return fmt.Errorf("--perm flag has to be one of: %s", apistruct.AllPermissions)
+		}
+
+		var scopes []string
+		if cctx.Bool("has-only") {
+			scopes = append(scopes, api.TokenScopeHasOnly)
+		}
+
+		// slice on [:idx] so for example: 'sign' gives you [read, write, sign]
+		token, err := napi.AuthNewScoped(ctx, apistruct.AllPermissions[:idx], scopes)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(token))
+		return nil
+	},
+}
+
+var authCreateApproverTokenCmd = &cli.Command{
+	Name:      "create-approver-token",
+	Usage:     "Create a token for a registered approver (see run --approver)",
+	ArgsUsage: "<approver name>",
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return xerrors.Errorf("must specify the approver name")
+		}
+
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		token, err := api.WalletApproverTokenNew(ctx, cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(token))
+		return nil
+	},
+}
+
+var authListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "List issued tokens",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "include revoked tokens",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		tokens, err := api.AuthList(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "ID\tPerms\tScopes\tApprover\tCreated\tCreated By\tLast Used\tRevoked")
+		for _, t := range tokens {
+			if t.Revoked != nil && !cctx.Bool("all") {
+				continue
+			}
+
+			lastUsed, revoked := "never", "-"
+			if !t.LastUsed.IsZero() {
+				lastUsed = t.LastUsed.Format("2006-01-02 15:04")
+			}
+			if t.Revoked != nil {
+				revoked = t.Revoked.Format("2006-01-02 15:04")
+			}
+			approver := t.Approver
+			if approver == "" {
+				approver = "-"
+			}
+			scopes := strings.Join(t.Scopes, ",")
+			if scopes == "" {
+				scopes = "-"
+			}
+
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				t.ID, t.Perms, scopes, approver, t.Created.Format("2006-01-02 15:04"), t.CreatedBy, lastUsed, revoked)
+		}
+		return tw.Flush()
+	},
+}
+
+var authRevokeCmd = &cli.Command{
+	Name:      "revoke",
+	Usage:     "Revoke an issued token",
+	ArgsUsage: "<token id>",
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return xerrors.Errorf("must specify the token id")
+		}
+
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		return api.AuthRevoke(ctx, cctx.Args().First())
+	},
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-jsonrpc/auth"
+
+	"github.com/filecoin-project/lotus/api/apistruct"
+	"github.com/filecoin-project/lotus/chain/wallet"
+)
+
+func newTestAuth() *walletAuth {
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	return &walletAuth{
+		secret: jwt.NewHS256([]byte("test secret")),
+		ds:     ds,
+		seclog: wallet.NewSecurityLog(nil, ds),
+	}
+}
+
+func TestAuthRevoke(t *testing.T) {
+	ctx := context.Background()
+	a := newTestAuth()
+
+	tok, err := a.AuthNew(ctx, []auth.Permission{apistruct.PermRead, apistruct.PermWrite})
+	require.NoError(t, err)
+	other, err := a.AuthNew(ctx, []auth.Permission{apistruct.PermRead})
+	require.NoError(t, err)
+
+	perms, err := a.AuthVerify(ctx, string(tok))
+	require.NoError(t, err)
+	require.Equal(t, []auth.Permission{apistruct.PermRead, apistruct.PermWrite}, perms)
+
+	tokens, err := a.AuthList(ctx)
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+
+	require.NoError(t, a.AuthRevoke(ctx, tokens[0].ID))
+
+	_, err = a.AuthVerify(ctx, string(tok))
+	require.Error(t, err)
+	_, err = a.AuthVerify(ctx, string(other))
+	require.NoError(t, err)
+
+	tokens, err = a.AuthList(ctx)
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+	require.NotNil(t, tokens[0].Revoked)
+	require.Nil(t, tokens[1].Revoked)
+
+	require.Error(t, a.AuthRevoke(ctx, "unknown"))
+}
+
+func TestAuthTouchToken(t *testing.T) {
+	ctx := context.Background()
+	a := newTestAuth()
+
+	tok, err := a.AuthNew(ctx, []auth.Permission{apistruct.PermRead})
+	require.NoError(t, err)
+
+	tokens, err := a.AuthList(ctx)
+	require.NoError(t, err)
+	require.True(t, tokens[0].LastUsed.IsZero())
+
+	_, err = a.AuthVerify(ctx, string(tok))
+	require.NoError(t, err)
+
+	tokens, err = a.AuthList(ctx)
+	require.NoError(t, err)
+	used := tokens[0].LastUsed
+	require.WithinDuration(t, time.Now(), used, time.Minute)
+
+	// uses within the resolution aren't written
+	_, err = a.AuthVerify(ctx, string(tok))
+	require.NoError(t, err)
+	tokens, err = a.AuthList(ctx)
+	require.NoError(t, err)
+	require.True(t, tokens[0].LastUsed.Equal(used))
+
+	// a revocation racing with a use is kept
+	require.NoError(t, a.AuthRevoke(ctx, tokens[0].ID))
+	require.Error(t, a.touchToken(tokens[0].ID))
+	tokens, err = a.AuthList(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, tokens[0].Revoked)
+}
+
+func TestAuthRejectsTokensWithoutID(t *testing.T) {
+	ctx := context.Background()
+	a := newTestAuth()
+
+	tok, err := jwt.Sign(&tokenPayload{Allow: apistruct.AllPermissions}, a.secret)
+	require.NoError(t, err)
+
+	_, err = a.AuthVerify(ctx, string(tok))
+	require.Error(t, err)
+}
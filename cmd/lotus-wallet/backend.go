@@ -0,0 +1,306 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/wallet"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var backendCmd = &cli.Command{
+	Name:  "backend",
+	Usage: "Inspect, attach and detach the signing backends of a running lotus wallet, and manage their maintenance mode and routing",
+	Subcommands: []*cli.Command{
+		backendListCmd,
+		backendRemotesCmd,
+		backendAttachCmd,
+		backendDetachCmd,
+		backendMaintenanceCmd,
+		backendResumeCmd,
+		backendRoutingCmd,
+	},
+}
+
+var backendListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "List backends and their state",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		st, err := api.WalletStatus(ctx)
+		if err != nil {
+			return err
+		}
+		infos, err := api.WalletBackendList(ctx)
+		if err != nil {
+			return err
+		}
+		endpoints := map[string]string{}
+		for _, i := range infos {
+			endpoints[i.Name] = i.Endpoint
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Backend\tEndpoint\tState\tIndexed Keys\tFailures\tDetail")
+		for _, b := range st.Backends {
+			detail := b.LastError
+			if b.MaintenanceReason != "" {
+				detail = b.MaintenanceReason
+			}
+			endpoint := endpoints[b.Name]
+			if endpoint == "" {
+				endpoint = "-"
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%s\n", b.Name, endpoint, b.State, b.IndexedKeys, b.Failures, detail)
+		}
+		return tw.Flush()
+	},
+}
+
+var backendRemotesCmd = &cli.Command{
+	Name:  "remotes",
+	Usage: "Show the connection state of remote wallets, shards and their verifiers",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		remotes, err := napi.WalletRemoteStatus(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Backend\tRole\tAddress\tState\tActive\tLast Seen\tError")
+		for _, r := range remotes {
+			seen := "never"
+			if !r.LastSeen.IsZero() {
+				seen = time.Since(r.LastSeen).Truncate(time.Second).String() + " ago"
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\t%s\t%s\n", r.Backend, r.Role, r.Addr, r.State, r.Active, seen, r.LastError)
+		}
+		return tw.Flush()
+	},
+}
+
+var backendAttachCmd = &cli.Command{
+	Name:      "attach",
+	Usage:     "Attach a remote or ledger backend to the running wallet",
+	ArgsUsage: "<remote|ledger>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "remote-info",
+			Usage: "api info (token:multiaddr) of the remote wallet",
+		},
+		&cli.StringSliceFlag{
+			Name:  "remote-verify",
+			Usage: "api info of an independent upstream which must confirm the answers of the remote wallet (can be repeated)",
+		},
+		&cli.StringFlag{
+			Name:   "ledger-speculos",
+			Usage:  "talk to the Speculos ledger emulator on this host:port instead of a ledger device (for tests only)",
+			Hidden: true,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return xerrors.Errorf("must specify the backend type")
+		}
+
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		return napi.WalletBackendAdd(ctx, api.BackendConfig{
+			Type:         cctx.Args().First(),
+			RemoteInfo:   cctx.String("remote-info"),
+			RemoteVerify: cctx.StringSlice("remote-verify"),
+			Speculos:     cctx.String("ledger-speculos"),
+		})
+	},
+}
+
+var backendDetachCmd = &cli.Command{
+	Name:      "detach",
+	Usage:     "Detach a backend from the running wallet",
+	ArgsUsage: "<backend>",
+	Description: `Sign requests already using the backend are finished first. Consider putting
+   the backend into maintenance first, to see which requests would fail.`,
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return xerrors.Errorf("must specify the backend name")
+		}
+
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		return napi.WalletBackendRemove(ctx, cctx.Args().First())
+	},
+}
+
+var backendMaintenanceCmd = &cli.Command{
+	Name:      "maintenance",
+	Usage:     "Put a backend into maintenance mode",
+	ArgsUsage: "<backend>",
+	Description: `While in maintenance, the backend isn't used. Requests for its addresses fail
+   over to other backends holding the key, or fail right away with an error
+   naming the backend.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "reason",
+			Usage: "reason shown in errors and the backend list, e.g. 'swapping ledger'",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		return setBackendMaintenance(cctx, true)
+	},
+}
+
+var backendResumeCmd = &cli.Command{
+	Name:      "resume",
+	Usage:     "Take a backend out of maintenance mode",
+	ArgsUsage: "<backend>",
+	Action: func(cctx *cli.Context) error {
+		return setBackendMaintenance(cctx, false)
+	},
+}
+
+func setBackendMaintenance(cctx *cli.Context, on bool) error {
+	if cctx.NArg() != 1 {
+		return xerrors.Errorf("must specify the backend name")
+	}
+
+	api, closer, err := lcli.GetWalletAPI(cctx)
+	if err != nil {
+		return err
+	}
+	defer closer()
+	ctx := lcli.ReqContext(cctx)
+
+	return api.WalletBackendMaintenance(ctx, cctx.Args().First(), on, cctx.String("reason"))
+}
+
+var backendRoutingCmd = &cli.Command{
+	Name:  "routing",
+	Usage: "Manage which backends handle requests",
+	Subcommands: []*cli.Command{
+		backendRoutingShowCmd,
+		backendRoutingSetCmd,
+		backendRoutingResetCmd,
+	},
+}
+
+var backendRoutingShowCmd = &cli.Command{
+	Name:  "show",
+	Usage: "Print the backend routing",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		r, err := napi.WalletRoutingGet(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(r.Priority) > 0 {
+			fmt.Printf("Priority: %s\n", strings.Join(r.Priority, ", "))
+		} else {
+			fmt.Println("Priority: default")
+		}
+
+		kts := make([]string, 0, len(r.NewKeys))
+		for kt := range r.NewKeys {
+			kts = append(kts, string(kt))
+		}
+		sort.Strings(kts)
+		fmt.Println("New keys:")
+		for _, kt := range kts {
+			fmt.Printf("  %s: %s\n", kt, r.NewKeys[types.KeyType(kt)])
+		}
+
+		fmt.Println("Pinned addresses:")
+		for _, p := range r.Pins {
+			fmt.Printf("  %s: %s\n", p.Address, p.Backend)
+		}
+		return nil
+	},
+}
+
+var backendRoutingSetCmd = &cli.Command{
+	Name:      "set",
+	Usage:     "Replace the backend routing with the contents of a file",
+	ArgsUsage: "<routing file>",
+	Description: `The file has the format of 'run --routing-file':
+
+   Priority = ["ledger", "local"]
+
+   [NewKeys]
+   secp256k1 = "remote"
+
+   [[Pin]]
+   Address = "f1..."
+   Backend = "ledger"`,
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return xerrors.Errorf("must specify the routing file")
+		}
+
+		r, err := wallet.LoadRoutingFile(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		return napi.WalletRoutingSet(ctx, r)
+	},
+}
+
+var backendRoutingResetCmd = &cli.Command{
+	Name:  "reset",
+	Usage: "Go back to the default backend routing",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		return napi.WalletRoutingSet(ctx, &api.BackendRouting{})
+	},
+}
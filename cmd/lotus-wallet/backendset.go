@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/ipfs/go-datastore"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/wallet"
+	ledgerwallet "github.com/filecoin-project/lotus/chain/wallet/ledger"
+	"github.com/filecoin-project/lotus/chain/wallet/remotewallet"
+	cliutil "github.com/filecoin-project/lotus/cli/util"
+)
+
+// backendOrder is the order of backends in listings, matching the order
+// MultiWallet prefers them in reverse
+var backendOrder = map[string]int{"local": 0, "ledger": 1, "remote": 2}
+
+// routableBackends are the backends routing can refer to, including ones
+// which may only be attached later
+var routableBackends = []string{"local", "ledger", "remote"}
+
+// backendSet holds the MultiWallet sign requests are routed through, and
+// allows attaching and detaching the remote and ledger backends of a running
+// wallet. Requests already in flight finish on the backends they started on;
+// a detached backend is closed once they are done.
+type backendSet struct {
+	ds datastore.Batching
+
+	// backends can't change when running as signing coordinator
+	fixed bool
+
+	lk       sync.RWMutex
+	mw       wallet.MultiWallet
+	backends []namedBackend
+	closers  map[string]func()
+	inflight *sync.WaitGroup
+	// closed once requests started before the last swap are done
+	drained chan struct{}
+}
+
+func closedCh() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
+
+func newBackendSet(ds datastore.Batching, mw wallet.MultiWallet, backends []namedBackend, closers map[string]func()) *backendSet {
+	return &backendSet{
+		ds:       ds,
+		mw:       mw,
+		backends: backends,
+		closers:  closers,
+		inflight: new(sync.WaitGroup),
+		drained:  closedCh(),
+	}
+}
+
+// newFixedBackendSet only lists the given backends, for the signing
+// coordinator, which routes requests through a ShardedWallet instead
+func newFixedBackendSet(backends []namedBackend) *backendSet {
+	return &backendSet{
+		fixed:    true,
+		backends: backends,
+		inflight: new(sync.WaitGroup),
+		drained:  closedCh(),
+	}
+}
+
+// list returns the current backends in local, ledger, remote order
+func (s *backendSet) list() []namedBackend {
+	s.lk.RLock()
+	defer s.lk.RUnlock()
+	return append([]namedBackend(nil), s.backends...)
+}
+
+func (s *backendSet) ledger() *ledgerwallet.LedgerWallet {
+	s.lk.RLock()
+	defer s.lk.RUnlock()
+	return s.mw.Ledger
+}
+
+func (s *backendSet) get() (wallet.MultiWallet, func()) {
+	s.lk.RLock()
+	defer s.lk.RUnlock()
+
+	wg := s.inflight
+	wg.Add(1)
+	return s.mw, wg.Done
+}
+
+// swap must be called with the lock held. The returned function waits for
+// requests started before the swap, including ones started before earlier
+// swaps, then calls closer.
+func (s *backendSet) swap(mw wallet.MultiWallet, closer func()) func() {
+	old, prev := s.inflight, s.drained
+	drained := make(chan struct{})
+	go func() {
+		<-prev
+		old.Wait()
+		close(drained)
+	}()
+
+	s.mw = mw
+	s.inflight = new(sync.WaitGroup)
+	s.drained = drained
+
+	return func() {
+		<-drained
+		if closer != nil {
+			closer()
+		}
+	}
+}
+
+func (s *backendSet) attached(name string) bool {
+	for _, b := range s.backends {
+		if b.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// open connects a new backend
+func (s *backendSet) open(ctx context.Context, cfg api.BackendConfig) (namedBackend, func(), error) {
+	nb := namedBackend{name: cfg.Type}
+	switch cfg.Type {
+	case "remote":
+		if cfg.RemoteInfo == "" {
+			return nb, nil, xerrors.Errorf("remote backend needs the api info of the remote wallet")
+		}
+		rw, closer, err := remotewallet.NewRemoteWallet(ctx, cfg.RemoteInfo, cfg.RemoteVerify...)
+		if err != nil {
+			return nb, nil, xerrors.Errorf("connecting to remote wallet: %w", err)
+		}
+		nb.w = rw
+		nb.endpoint = cliutil.ParseApiInfo(cfg.RemoteInfo).Addr
+		return nb, closer, nil
+	case "ledger":
+		lw := ledgerwallet.NewWallet(s.ds)
+		if cfg.Speculos != "" {
+			log.Warnw("using the speculos ledger emulator, keys are not secure", "address", cfg.Speculos)
+			lw = ledgerwallet.NewSpeculosWallet(s.ds, cfg.Speculos)
+			nb.endpoint = cfg.Speculos
+		}
+		if err := lw.CheckDevice(); err != nil {
+			return nb, nil, err
+		}
+		nb.w = lw
+		return nb, nil, nil
+	default:
+		return nb, nil, xerrors.Errorf("unknown backend type %q, expected remote or ledger", cfg.Type)
+	}
+}
+
+func (s *backendSet) add(ctx context.Context, cfg api.BackendConfig) error {
+	if s.fixed {
+		return xerrors.Errorf("backends can't be changed when running as signing coordinator")
+	}
+
+	s.lk.RLock()
+	dup := s.attached(cfg.Type)
+	s.lk.RUnlock()
+	if dup {
+		return xerrors.Errorf("the %s backend is already attached", cfg.Type)
+	}
+
+	// connect without holding the lock, so requests aren't held up by a slow
+	// backend
+	nb, closer, err := s.open(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if s.attached(cfg.Type) {
+		if closer != nil {
+			closer()
+		}
+		return xerrors.Errorf("the %s backend is already attached", cfg.Type)
+	}
+
+	mw := s.mw
+	switch w := nb.w.(type) {
+	case *remotewallet.RemoteWallet:
+		mw.Remote = w
+	case *ledgerwallet.LedgerWallet:
+		mw.Ledger = w
+	}
+
+	backends := append(append([]namedBackend(nil), s.backends...), nb)
+	sort.SliceStable(backends, func(i, j int) bool {
+		return backendOrder[backends[i].name] < backendOrder[backends[j].name]
+	})
+	s.backends = backends
+	if closer != nil {
+		s.closers[cfg.Type] = closer
+	}
+	// nothing was detached, there is nothing to close, but requests started
+	// before still count for later removals
+	s.swap(mw, nil)
+
+	log.Warnw("attached wallet backend", "backend", cfg.Type, "endpoint", nb.endpoint)
+	return nil
+}
+
+func (s *backendSet) remove(name string) error {
+	if s.fixed {
+		return xerrors.Errorf("backends can't be changed when running as signing coordinator")
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	mw := s.mw
+	switch name {
+	case "remote":
+		mw.Remote = nil
+	case "ledger":
+		mw.Ledger = nil
+	case "local":
+		return xerrors.Errorf("the local backend can't be detached")
+	default:
+		return xerrors.Errorf("unknown backend %q", name)
+	}
+
+	var backends []namedBackend
+	for _, b := range s.backends {
+		if b.name != name {
+			backends = append(backends, b)
+		}
+	}
+	if !s.attached(name) {
+		return xerrors.Errorf("the %s backend is not attached", name)
+	}
+	s.backends = backends
+
+	closer := s.closers[name]
+	delete(s.closers, name)
+	go s.swap(mw, closer)()
+
+	log.Warnw("detached wallet backend", "backend", name)
+	return nil
+}
+
+// close closes all backends, at shutdown
+func (s *backendSet) close() {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	for name, c := range s.closers {
+		c()
+		delete(s.closers, name)
+	}
+}
+
+func (s *backendSet) WalletNew(ctx context.Context, typ types.KeyType) (address.Address, error) {
+	mw, done := s.get()
+	defer done()
+	return mw.WalletNew(ctx, typ)
+}
+
+func (s *backendSet) WalletHas(ctx context.Context, addr address.Address) (bool, error) {
+	mw, done := s.get()
+	defer done()
+	return mw.WalletHas(ctx, addr)
+}
+
+func (s *backendSet) WalletList(ctx context.Context) ([]address.Address, error) {
+	mw, done := s.get()
+	defer done()
+	return mw.WalletList(ctx)
+}
+
+func (s *backendSet) WalletSign(ctx context.Context, signer address.Address, toSign []byte, meta api.MsgMeta) (*crypto.Signature, error) {
+	mw, done := s.get()
+	defer done()
+	return mw.WalletSign(ctx, signer, toSign, meta)
+}
+
+func (s *backendSet) WalletExport(ctx context.Context, addr address.Address) (*types.KeyInfo, error) {
+	mw, done := s.get()
+	defer done()
+	return mw.WalletExport(ctx, addr)
+}
+
+func (s *backendSet) WalletImport(ctx context.Context, ki *types.KeyInfo) (address.Address, error) {
+	mw, done := s.get()
+	defer done()
+	return mw.WalletImport(ctx, ki)
+}
+
+func (s *backendSet) WalletDelete(ctx context.Context, addr address.Address) error {
+	mw, done := s.get()
+	defer done()
+	return mw.WalletDelete(ctx, addr)
+}
+
+var _ api.WalletAPI = &backendSet{}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/chain/wallet"
+)
+
+func TestBackendSetRemoveWaitsForEarlierRequests(t *testing.T) {
+	s := newBackendSet(nil, wallet.MultiWallet{}, nil, map[string]func(){})
+
+	// a request started before a backend was attached
+	_, done := s.get()
+
+	s.lk.Lock()
+	s.swap(s.mw, nil) // attach
+	closed := make(chan struct{})
+	wait := s.swap(s.mw, func() { close(closed) }) // remove
+	s.lk.Unlock()
+	go wait()
+
+	select {
+	case <-closed:
+		t.Fatal("backend closed while a request started before attaching it was in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	done()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("backend not closed after requests finished")
+	}
+}
+
+func TestBackendSetConcurrentSwaps(t *testing.T) {
+	s := newBackendSet(nil, wallet.MultiWallet{}, nil, map[string]func(){})
+
+	stop := make(chan struct{})
+	var inflight int64
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				_, done := s.get()
+				atomic.AddInt64(&inflight, 1)
+				time.Sleep(time.Millisecond)
+				atomic.AddInt64(&inflight, -1)
+				done()
+			}
+		}()
+	}
+
+	const swaps = 50
+	var closed int64
+	var waits sync.WaitGroup
+	for i := 0; i < swaps; i++ {
+		s.lk.Lock()
+		wait := s.swap(s.mw, func() { atomic.AddInt64(&closed, 1) })
+		s.lk.Unlock()
+
+		waits.Add(1)
+		go func() {
+			defer waits.Done()
+			wait()
+		}()
+		time.Sleep(time.Millisecond)
+	}
+
+	close(stop)
+	wg.Wait()
+	waits.Wait()
+
+	require.Equal(t, int64(0), atomic.LoadInt64(&inflight))
+	require.Equal(t, int64(swaps), atomic.LoadInt64(&closed))
+}
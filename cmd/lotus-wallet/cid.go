@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/wallet"
+)
+
+var walletCid = &cli.Command{
+	Name:      "cid",
+	Usage:     "Show a CID in base32 and base16, and check whether CIDs in different encodings are the same (works offline)",
+	ArgsUsage: "<cid> [<cid>...]",
+	Description: `CIDs are accepted in any multibase encoding (bafy..., f0171...), or as bare
+   hex as shown by some explorers. With more than one CID, exits with an error
+   unless all of them are the same CID.`,
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Args().Present() {
+			return xerrors.Errorf("must specify a cid")
+		}
+
+		first, err := wallet.ParseCid(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		e, err := wallet.DescribeCid(first)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Version: %d\n", e.Version)
+		fmt.Printf("Codec:   %s\n", e.Codec)
+		fmt.Printf("Base32:  %s\n", e.Base32)
+		fmt.Printf("Base16:  %s\n", e.Base16)
+
+		for _, s := range cctx.Args().Slice()[1:] {
+			c, err := wallet.ParseCid(s)
+			if err != nil {
+				return err
+			}
+			if !c.Equals(first) {
+				return xerrors.Errorf("%s is a different cid", s)
+			}
+		}
+		if cctx.NArg() > 1 {
+			fmt.Println("All CIDs match")
+		}
+		return nil
+	},
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"syscall"
+
+	"github.com/BurntSushi/toml"
+	"github.com/filecoin-project/go-address"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/mitchellh/go-homedir"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/wallet"
+)
+
+const configFileName = "config.toml"
+
+// walletConfig is the content of a wallet config file. Top level keys are
+// the names of 'run' flags, e.g.
+//
+//	listen = "0.0.0.0:1777"
+//	remote = ["<token>:/ip4/10.0.0.2/tcp/1234/http"]
+//	policy-file = "/etc/lotus-wallet/policies.toml"
+//	disable-auth = false
+//
+//	[Logging]
+//	wallet = "info"
+//	rpc = "warn"
+//
+// Flags given on the command line take precedence over the file.
+type walletConfig struct {
+	Flags   map[string][]string
+	Logging map[string]string
+}
+
+func loadConfigFile(path string) (*walletConfig, error) {
+	var raw map[string]interface{}
+	if _, err := toml.DecodeFile(path, &raw); err != nil {
+		return nil, xerrors.Errorf("decoding config file: %w", err)
+	}
+
+	cfg := &walletConfig{
+		Flags:   map[string][]string{},
+		Logging: map[string]string{},
+	}
+	for k, v := range raw {
+		if k == "Logging" {
+			levels, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, xerrors.Errorf("config key Logging must be a table")
+			}
+			for sub, lvl := range levels {
+				s, ok := lvl.(string)
+				if !ok {
+					return nil, xerrors.Errorf("log level of %q must be a string", sub)
+				}
+				cfg.Logging[sub] = s
+			}
+			continue
+		}
+
+		switch v := v.(type) {
+		case []interface{}:
+			vals := make([]string, 0, len(v))
+			for _, e := range v {
+				vals = append(vals, fmt.Sprint(e))
+			}
+			cfg.Flags[k] = vals
+		case map[string]interface{}, []map[string]interface{}:
+			return nil, xerrors.Errorf("config key %q must be a value, not a table", k)
+		default:
+			cfg.Flags[k] = []string{fmt.Sprint(v)}
+		}
+	}
+
+	return cfg, nil
+}
+
+// configPath returns the config file used by 'run': the --config flag, or
+// config.toml in the wallet repo when it exists. It returns an empty string
+// when there is no config file.
+func configPath(cctx *cli.Context) (string, error) {
+	if cctx.IsSet("config") {
+		return cctx.String("config"), nil
+	}
+	if cctx.Bool("in-memory") {
+		return "", nil
+	}
+
+	dir, err := homedir.Expand(cctx.String(FlagWalletRepo))
+	if err != nil {
+		return "", err
+	}
+	p := filepath.Join(dir, configFileName)
+	if _, err := os.Stat(p); err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return p, nil
+}
+
+// configReloader applies a wallet config file to the flags of 'run' at
+// startup, and re-reads it on SIGHUP. Log levels and the policy file are
+// applied again on reload, removing policies of addresses dropped from the
+// policy file; changes to other settings are logged as needing a restart.
+type configReloader struct {
+	path string
+
+	// flags set on the command line, these are never taken from the file
+	cmdline map[string]bool
+	// flag values taken from the file at startup
+	applied map[string][]string
+
+	policyFile string
+	// addresses which policies were set from the policy file
+	filePolicies []address.Address
+}
+
+func newConfigReloader(cctx *cli.Context) (*configReloader, error) {
+	path, err := configPath(cctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cr := &configReloader{
+		path:       path,
+		cmdline:    map[string]bool{},
+		applied:    map[string][]string{},
+		policyFile: cctx.String("policy-file"),
+	}
+	for _, f := range cctx.Command.Flags {
+		for _, n := range f.Names() {
+			if cctx.IsSet(n) {
+				cr.cmdline[f.Names()[0]] = true
+			}
+		}
+	}
+	if path == "" {
+		return cr, nil
+	}
+
+	cfg, err := loadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[string]string{}
+	for _, f := range cctx.Command.Flags {
+		for _, n := range f.Names() {
+			known[n] = f.Names()[0]
+		}
+	}
+	for k, vals := range cfg.Flags {
+		name, ok := known[k]
+		if !ok || name == "config" {
+			return nil, xerrors.Errorf("config file %s: unknown setting %q", path, k)
+		}
+		if cr.cmdline[name] {
+			continue
+		}
+		for _, v := range vals {
+			if err := cctx.Set(name, v); err != nil {
+				return nil, xerrors.Errorf("config file %s: setting %s: %w", path, k, err)
+			}
+		}
+		cr.applied[name] = vals
+	}
+	cr.policyFile = cctx.String("policy-file")
+
+	if err := applyLogLevels(cfg.Logging); err != nil {
+		return nil, xerrors.Errorf("config file %s: %w", path, err)
+	}
+
+	log.Infow("loaded config file", "path", path, "settings", len(cr.applied))
+	return cr, nil
+}
+
+func applyLogLevels(levels map[string]string) error {
+	for sub, lvl := range levels {
+		if err := logging.SetLogLevel(sub, lvl); err != nil {
+			return xerrors.Errorf("setting log level of %s: %w", sub, err)
+		}
+	}
+	return nil
+}
+
+// run reloads the config file and the policy file on SIGHUP until ctx is
+// done. Policies are set through setPolicy, so that reloads show up in the
+// security log.
+func (cr *configReloader) run(ctx context.Context, setPolicy func(context.Context, address.Address, *api.SigningPolicy) error) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+
+	for {
+		select {
+		case <-sigCh:
+			if err := cr.reload(ctx, setPolicy); err != nil {
+				log.Errorf("reloading configuration: %s", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (cr *configReloader) reload(ctx context.Context, setPolicy func(context.Context, address.Address, *api.SigningPolicy) error) error {
+	policyFile := cr.policyFile
+
+	if cr.path != "" {
+		cfg, err := loadConfigFile(cr.path)
+		if err != nil {
+			return err
+		}
+		if err := applyLogLevels(cfg.Logging); err != nil {
+			return err
+		}
+
+		var restart []string
+		for name := range unionKeys(cfg.Flags, cr.applied) {
+			if cr.cmdline[name] {
+				continue
+			}
+			if name == "policy-file" {
+				policyFile = ""
+				if v := cfg.Flags[name]; len(v) > 0 {
+					policyFile = v[len(v)-1]
+				}
+				continue
+			}
+			if !reflect.DeepEqual(cfg.Flags[name], cr.applied[name]) {
+				restart = append(restart, name)
+			}
+		}
+		if len(restart) > 0 {
+			sort.Strings(restart)
+			log.Warnw("config file changes need a restart to take effect", "settings", restart)
+		}
+		log.Infow("reloaded config file", "path", cr.path, "logging", len(cfg.Logging))
+	}
+
+	var set []address.Address
+	if policyFile != "" {
+		var err error
+		if set, err = applyPolicyFile(ctx, policyFile, setPolicy); err != nil {
+			return err
+		}
+	}
+	cr.policyFile = policyFile
+
+	inFile := map[address.Address]struct{}{}
+	for _, a := range set {
+		inFile[a] = struct{}{}
+	}
+	for _, a := range cr.filePolicies {
+		if _, ok := inFile[a]; ok {
+			continue
+		}
+		if err := setPolicy(ctx, a, nil); err != nil {
+			return xerrors.Errorf("removing policy of %s, which was dropped from the policy file: %w", a, err)
+		}
+		log.Infow("removed signing policy dropped from the policy file", "address", a)
+	}
+	cr.filePolicies = set
+	return nil
+}
+
+func unionKeys(a, b map[string][]string) map[string]struct{} {
+	out := map[string]struct{}{}
+	for k := range a {
+		out[k] = struct{}{}
+	}
+	for k := range b {
+		out[k] = struct{}{}
+	}
+	return out
+}
+
+// applyPolicyFile sets the policies of a policy file through set, returning
+// the addresses with a policy in the file.
+func applyPolicyFile(ctx context.Context, path string, set func(context.Context, address.Address, *api.SigningPolicy) error) ([]address.Address, error) {
+	pols, err := wallet.LoadPolicyFile(path)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]address.Address, 0, len(pols))
+	for _, ap := range pols {
+		ap := ap
+		if err := set(ctx, ap.Address, &ap.Policy); err != nil {
+			return nil, xerrors.Errorf("setting policy for %s: %w", ap.Address, err)
+		}
+		addrs = append(addrs, ap.Address)
+	}
+	log.Infow("loaded signing policies", "file", path, "count", len(pols))
+	return addrs, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/wallet"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+// custodyConfig records daemon settings which only exist as run flags, for
+// custody reports
+type custodyConfig struct {
+	keystore       string
+	tls            bool
+	tlsClientAuth  bool
+	authRequired   bool
+	requestSigning bool
+}
+
+func (d *walletDaemon) WalletCustodyReport(ctx context.Context) (*api.CustodyReport, error) {
+	r := &api.CustodyReport{
+		Generated:      time.Now(),
+		Version:        build.UserVersion(),
+		InstancePubkey: d.instanceKey.PublicKey(),
+		Keystore:       d.custody.keystore,
+		TLS:            d.custody.tls,
+		TLSClientAuth:  d.custody.tlsClientAuth,
+		AuthRequired:   d.custody.authRequired,
+		RequestSigning: d.custody.requestSigning,
+		FrozenGroups:   []string{},
+		Keys:           []api.CustodyKey{},
+	}
+
+	tokens, err := d.AuthList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tokens {
+		switch {
+		case t.Revoked != nil:
+			r.RevokedTokens++
+		case t.Approver != "":
+			r.ApproverTokens++
+		default:
+			r.ActiveTokens++
+		}
+	}
+
+	if d.approval != nil {
+		cfg := d.approval.Config()
+		r.Approvals = &api.CustodyApprovals{
+			All:       cfg.All,
+			Threshold: cfg.Threshold,
+			Approvers: cfg.Approvers,
+			Required:  cfg.Required,
+		}
+	}
+
+	pols, err := d.policy.WalletPolicyList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.PolicyCount = len(pols)
+	hasPolicy := map[address.Address]bool{}
+	for _, p := range pols {
+		hasPolicy[p.Address] = true
+	}
+
+	groups, err := d.policy.WalletGroupList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groupsOf := map[address.Address][]api.KeyGroup{}
+	for _, g := range groups {
+		if g.Frozen {
+			r.FrozenGroups = append(r.FrozenGroups, g.Name)
+		}
+		for _, m := range g.Members {
+			groupsOf[m] = append(groupsOf[m], g)
+		}
+	}
+
+	held, err := d.listBackends(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range held {
+		k := api.CustodyKey{
+			Address: h.addr,
+			Backend: h.backend.name,
+			Policy:  hasPolicy[h.addr],
+		}
+		for _, g := range groupsOf[h.addr] {
+			k.Groups = append(k.Groups, g.Name)
+			k.Frozen = k.Frozen || g.Frozen
+		}
+		r.Keys = append(r.Keys, k)
+	}
+
+	return r, nil
+}
+
+var custodyReportCmd = &cli.Command{
+	Name:  "custody-report",
+	Usage: "Write a report of the key custody controls in effect on a running lotus wallet",
+	Description: `The report is written as JSON. With --signer, the sha256 digest of the report
+   is signed like with 'wallet sign-file', and the detached signature is written
+   next to it, so auditors can check it with 'wallet verify-file'.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "out",
+			Usage: "file to write the report to",
+			Value: "custody-report.json",
+		},
+		&cli.StringFlag{
+			Name:  "signer",
+			Usage: "address to sign the report with",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		r, err := napi.WalletCustodyReport(ctx)
+		if err != nil {
+			return err
+		}
+
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		b = append(b, '\n')
+
+		out := cctx.String("out")
+		if err := ioutil.WriteFile(out, b, 0644); err != nil {
+			return xerrors.Errorf("writing report: %w", err)
+		}
+		fmt.Printf("custody report written to %s\n", out)
+
+		s := cctx.String("signer")
+		if s == "" {
+			return nil
+		}
+
+		signer, err := address.NewFromString(s)
+		if err != nil {
+			return xerrors.Errorf("parsing signer address: %w", err)
+		}
+
+		digest, err := digestFile(out)
+		if err != nil {
+			return err
+		}
+
+		fs, err := wallet.SignFileDigest(ctx, napi, signer, digest, "custody report")
+		if err != nil {
+			return xerrors.Errorf("signing report: %w", err)
+		}
+		fs.Name = filepath.Base(out)
+
+		if err := writeFileSignature(out+fileSignatureSuffix, fs); err != nil {
+			return err
+		}
+
+		fmt.Printf("signed with %s, signature written to %s\n", signer, out+fileSignatureSuffix)
+		return nil
+	},
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-jsonrpc/auth"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/apistruct"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/wallet"
+	"github.com/filecoin-project/lotus/chain/wallet/remotewallet"
+)
+
+// walletDaemon combines the signing backends with daemon-level services into
+// the API served by lotus-wallet.
+type walletDaemon struct {
+	api.WalletAPI
+	*walletAuth
+
+	*wallet.BLSAggregator
+	*wallet.HDWallet
+
+	instanceKey *wallet.InstanceKey
+	journal     *wallet.SignatureJournal
+	audit       *wallet.AuditWallet
+	seclog      *wallet.SecurityLog
+	summaries   *wallet.MsgSummaries
+	policy      *wallet.PolicyWallet
+	approval    *wallet.ApprovalWallet // nil unless sign requests need approval
+	services    *wallet.ServiceKeys    // nil unless service keys are enabled
+
+	backends *backendSet
+	breakers *wallet.Breakers
+	index    *wallet.BackendIndex
+	routing  *wallet.Router // nil when running as signing coordinator
+	owned    ownershipProofs
+	custody  custodyConfig
+
+	started time.Time
+	clients *wsClients
+
+	// advertised through Version, and the random id returned by Session
+	apiVersion build.Version
+	session    uuid.UUID
+
+	devLk sync.Mutex
+}
+
+func (d *walletDaemon) WalletImport(ctx context.Context, ki *types.KeyInfo) (address.Address, error) {
+	if ki.Type == wallet.KTWrapped {
+		uki, err := d.instanceKey.Unwrap(ki)
+		if err != nil {
+			return address.Undef, err
+		}
+		ki = uki
+	}
+
+	return d.WalletAPI.WalletImport(ctx, ki)
+}
+
+func (d *walletDaemon) WalletList(ctx context.Context) ([]address.Address, error) {
+	if hasScope(ctx, api.TokenScopeHasOnly) {
+		return nil, xerrors.Errorf("token is restricted to checking for specific addresses")
+	}
+
+	return d.WalletAPI.WalletList(ctx)
+}
+
+func (d *walletDaemon) LogList(context.Context) ([]string, error) {
+	return logging.GetSubsystems(), nil
+}
+
+func (d *walletDaemon) LogSetLevel(ctx context.Context, subsystem, level string) error {
+	log.Infow("setting log level", "subsystem", subsystem, "level", level, "by", wallet.CallerFromContext(ctx))
+	return logging.SetLogLevel(subsystem, level)
+}
+
+func (d *walletDaemon) Version(context.Context) (api.Version, error) {
+	return api.Version{
+		Version:    build.UserVersion(),
+		APIVersion: d.apiVersion,
+
+		BlockDelay: build.BlockDelaySecs,
+	}, nil
+}
+
+func (d *walletDaemon) Session(context.Context) (uuid.UUID, error) {
+	return d.session, nil
+}
+
+// parseAPIVersion parses a major.minor.patch api version
+func parseAPIVersion(s string) (build.Version, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return 0, xerrors.Errorf("api version %q isn't in major.minor.patch form", s)
+	}
+
+	var v uint32
+	for _, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 8)
+		if err != nil {
+			return 0, xerrors.Errorf("parsing api version %q: %w", s, err)
+		}
+		v = v<<8 | uint32(n)
+	}
+	return build.Version(v), nil
+}
+
+const (
+	// maxHasMany is the most addresses WalletHasMany checks in one call
+	maxHasMany = 1000
+	// hasManyParallel is how many addresses WalletHasMany checks at once
+	hasManyParallel = 16
+)
+
+func (d *walletDaemon) WalletHasMany(ctx context.Context, addrs []address.Address) ([]bool, error) {
+	if len(addrs) > maxHasMany {
+		return nil, xerrors.Errorf("can check at most %d addresses at once, got %d", maxHasMany, len(addrs))
+	}
+
+	// probe concurrently, with remote backends each check is a round trip
+	out := make([]bool, len(addrs))
+	eg, ectx := errgroup.WithContext(ctx)
+	throttle := make(chan struct{}, hasManyParallel)
+	for i, a := range addrs {
+		i, a := i, a
+
+		select {
+		case throttle <- struct{}{}:
+		case <-ectx.Done():
+			return nil, eg.Wait()
+		}
+		eg.Go(func() error {
+			defer func() { <-throttle }()
+
+			have, err := d.WalletAPI.WalletHas(ectx, a)
+			if err != nil {
+				return xerrors.Errorf("checking %s: %w", a, err)
+			}
+			out[i] = have
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (d *walletDaemon) WalletInstancePubkey(ctx context.Context) ([]byte, error) {
+	return d.instanceKey.PublicKey(), nil
+}
+
+func (d *walletDaemon) WalletFindSignature(ctx context.Context, c cid.Cid) (*api.SignatureRecord, error) {
+	return d.journal.WalletFindSignature(ctx, c)
+}
+
+func (d *walletDaemon) WalletMsgSummary(ctx context.Context, c cid.Cid) (*api.MsgSummary, error) {
+	return d.summaries.WalletMsgSummary(ctx, c)
+}
+
+func (d *walletDaemon) WalletAuditQuery(ctx context.Context, f api.AuditFilter) ([]api.AuditEntry, error) {
+	return d.audit.WalletAuditQuery(ctx, f)
+}
+
+func (d *walletDaemon) WalletJournalPrune(ctx context.Context, maxAge time.Duration, maxEntries int) (int, error) {
+	return d.journal.WalletJournalPrune(ctx, maxAge, maxEntries)
+}
+
+func (d *walletDaemon) WalletSecurityEvents(ctx context.Context, f api.SecurityEventFilter) ([]api.SecurityEvent, error) {
+	return d.seclog.WalletSecurityEvents(ctx, f)
+}
+
+func (d *walletDaemon) WalletPolicySet(ctx context.Context, addr address.Address, p *api.SigningPolicy) error {
+	if err := d.policy.WalletPolicySet(ctx, addr, p); err != nil {
+		return err
+	}
+
+	if p == nil {
+		d.seclog.Record(ctx, wallet.SecEvPolicyRemove, addr.String(), "")
+		return nil
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	d.seclog.Record(ctx, wallet.SecEvPolicySet, addr.String(), string(b))
+	return nil
+}
+
+func (d *walletDaemon) WalletPolicyGet(ctx context.Context, addr address.Address) (*api.SigningPolicy, error) {
+	return d.policy.WalletPolicyGet(ctx, addr)
+}
+
+func (d *walletDaemon) WalletPolicyList(ctx context.Context) ([]api.AddressPolicy, error) {
+	if hasScope(ctx, api.TokenScopeHasOnly) {
+		return nil, xerrors.Errorf("token is restricted to checking for specific addresses")
+	}
+
+	return d.policy.WalletPolicyList(ctx)
+}
+
+func (d *walletDaemon) WalletSpendGet(ctx context.Context, addr address.Address) (*api.SpendStatus, error) {
+	return d.policy.WalletSpendGet(ctx, addr)
+}
+
+func (d *walletDaemon) WalletSpendReset(ctx context.Context, addr address.Address) error {
+	return d.policy.WalletSpendReset(ctx, addr)
+}
+
+func (d *walletDaemon) WalletGroupSet(ctx context.Context, g api.KeyGroup) error {
+	if err := d.policy.WalletGroupSet(ctx, g); err != nil {
+		return err
+	}
+
+	d.seclog.Record(ctx, wallet.SecEvGroupSet, g.Name, fmt.Sprintf("members: %d, policy: %t, frozen: %t", len(g.Members), g.Policy != nil, g.Frozen))
+	return nil
+}
+
+func (d *walletDaemon) WalletGroupGet(ctx context.Context, name string) (*api.KeyGroup, error) {
+	if hasScope(ctx, api.TokenScopeHasOnly) {
+		return nil, xerrors.Errorf("token is restricted to checking for specific addresses")
+	}
+
+	return d.policy.WalletGroupGet(ctx, name)
+}
+
+func (d *walletDaemon) WalletGroupRemove(ctx context.Context, name string) error {
+	if err := d.policy.WalletGroupRemove(ctx, name); err != nil {
+		return err
+	}
+
+	d.seclog.Record(ctx, wallet.SecEvGroupRemove, name, "")
+	return nil
+}
+
+func (d *walletDaemon) WalletGroupFreeze(ctx context.Context, name string, frozen bool) error {
+	if err := d.policy.WalletGroupFreeze(ctx, name, frozen); err != nil {
+		return err
+	}
+
+	kind := wallet.SecEvGroupUnfreeze
+	if frozen {
+		kind = wallet.SecEvGroupFreeze
+	}
+	d.seclog.Record(ctx, kind, name, "")
+	return nil
+}
+
+func (d *walletDaemon) WalletGroupList(ctx context.Context) ([]api.KeyGroup, error) {
+	if hasScope(ctx, api.TokenScopeHasOnly) {
+		return nil, xerrors.Errorf("token is restricted to checking for specific addresses")
+	}
+
+	return d.policy.WalletGroupList(ctx)
+}
+
+// checkApprovalReader makes sure the caller may see pending sign requests:
+// registered approvers, whose tokens only carry read permission, and callers
+// with write permission
+func checkApprovalReader(ctx context.Context) error {
+	if hasScope(ctx, api.TokenScopeHasOnly) {
+		return xerrors.Errorf("token is restricted to checking for specific addresses")
+	}
+	if wallet.ApproverFromContext(ctx) == "" && !auth.HasPerm(ctx, apistruct.DefaultPerms, apistruct.PermWrite) {
+		return xerrors.Errorf("listing sign requests requires an approver or write token")
+	}
+	return nil
+}
+
+func (d *walletDaemon) WalletApprovalList(ctx context.Context) ([]api.PendingSign, error) {
+	if err := checkApprovalReader(ctx); err != nil {
+		return nil, err
+	}
+	if d.approval == nil {
+		return nil, nil
+	}
+	return d.approval.WalletApprovalList(ctx)
+}
+
+func (d *walletDaemon) WalletApprovalSubscribe(ctx context.Context) (<-chan api.PendingSign, error) {
+	if err := checkApprovalReader(ctx); err != nil {
+		return nil, err
+	}
+	if d.approval == nil {
+		return nil, xerrors.Errorf("sign request approvals are not enabled")
+	}
+	return d.approval.WalletApprovalSubscribe(ctx)
+}
+
+func (d *walletDaemon) WalletHDList(ctx context.Context) ([]api.HDAccount, error) {
+	if hasScope(ctx, api.TokenScopeHasOnly) {
+		return nil, xerrors.Errorf("token is restricted to checking for specific addresses")
+	}
+
+	return d.HDWallet.WalletHDList(ctx)
+}
+
+func (d *walletDaemon) WalletBLSAggregateList(ctx context.Context) ([]api.BLSAggregate, error) {
+	if hasScope(ctx, api.TokenScopeHasOnly) {
+		return nil, xerrors.Errorf("token is restricted to checking for specific addresses")
+	}
+
+	return d.BLSAggregator.WalletBLSAggregateList(ctx)
+}
+
+func (d *walletDaemon) WalletApprovalDecide(ctx context.Context, id uint64, approve bool) error {
+	if d.approval == nil {
+		return xerrors.Errorf("sign request approvals are not enabled")
+	}
+
+	// without registered approvers, any admin can decide
+	if wallet.ApproverFromContext(ctx) == "" && !auth.HasPerm(ctx, apistruct.DefaultPerms, apistruct.PermAdmin) {
+		return xerrors.Errorf("deciding sign requests requires an approver or admin token")
+	}
+
+	return d.approval.WalletApprovalDecide(ctx, id, approve)
+}
+
+func (d *walletDaemon) WalletApproverTokenNew(ctx context.Context, name string) ([]byte, error) {
+	if d.approval == nil || !d.approval.IsApprover(name) {
+		return nil, xerrors.Errorf("%q is not a registered approver", name)
+	}
+
+	return d.walletAuth.newApproverToken(ctx, name)
+}
+
+func (d *walletDaemon) WalletStatus(ctx context.Context) (*api.WalletDaemonStatus, error) {
+	tracked := map[string]api.BackendStatus{}
+	for _, bs := range d.breakers.Status() {
+		tracked[bs.Name] = bs
+	}
+
+	keys := d.index.Counts()
+
+	out := &api.WalletDaemonStatus{
+		Version:      build.UserVersion(),
+		Started:      d.started,
+		Uptime:       time.Since(d.started).Truncate(time.Second),
+		WSClients:    d.clients.connected(),
+		FrozenGroups: []string{},
+	}
+	if d.approval != nil {
+		pending, err := d.approval.WalletApprovalList(ctx)
+		if err != nil {
+			return nil, err
+		}
+		out.PendingApprovals = len(pending)
+	}
+	groups, err := d.policy.WalletGroupList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		if g.Frozen {
+			out.FrozenGroups = append(out.FrozenGroups, g.Name)
+		}
+	}
+	for _, b := range d.backends.list() {
+		bs, ok := tracked[b.name]
+		if !ok {
+			bs = api.BackendStatus{Name: b.name, State: wallet.BackendOK}
+		}
+		bs.IndexedKeys = keys[b.name]
+		out.Backends = append(out.Backends, bs)
+	}
+
+	return out, nil
+}
+
+func (d *walletDaemon) WalletBackendMaintenance(ctx context.Context, backend string, on bool, reason string) error {
+	known := false
+	for _, b := range d.backends.list() {
+		known = known || b.name == backend
+	}
+	if !known {
+		return xerrors.Errorf("unknown backend %q", backend)
+	}
+
+	if err := d.breakers.SetMaintenance(backend, on, reason); err != nil {
+		return err
+	}
+
+	kind := wallet.SecEvMaintenanceOff
+	if on {
+		kind = wallet.SecEvMaintenanceOn
+	}
+	d.seclog.Record(ctx, kind, backend, reason)
+	return nil
+}
+
+func (d *walletDaemon) WalletRoutingGet(ctx context.Context) (*api.BackendRouting, error) {
+	// pins list wallet addresses
+	if hasScope(ctx, api.TokenScopeHasOnly) {
+		return nil, xerrors.Errorf("token is restricted to checking for specific addresses")
+	}
+
+	r := d.routing.Get()
+	return &r, nil
+}
+
+func (d *walletDaemon) WalletRoutingSet(ctx context.Context, r *api.BackendRouting) error {
+	if d.routing == nil {
+		return xerrors.Errorf("backend routing is not supported when running as signing coordinator")
+	}
+	if err := d.routing.Set(*r, routableBackends); err != nil {
+		return err
+	}
+
+	log.Warnw("backend routing changed", "priority", r.Priority, "newKeys", r.NewKeys, "pins", len(r.Pins))
+	return nil
+}
+
+func (d *walletDaemon) WalletCidEncodings(ctx context.Context, s string) (*api.CidEncodings, error) {
+	c, err := wallet.ParseCid(s)
+	if err != nil {
+		return nil, err
+	}
+	return wallet.DescribeCid(c)
+}
+
+func (d *walletDaemon) WalletBackendAdd(ctx context.Context, cfg api.BackendConfig) error {
+	if err := d.backends.add(ctx, cfg); err != nil {
+		return err
+	}
+
+	d.seclog.Record(ctx, wallet.SecEvBackendAttach, cfg.Type, "")
+	return nil
+}
+
+func (d *walletDaemon) WalletBackendRemove(ctx context.Context, backend string) error {
+	if err := d.backends.remove(backend); err != nil {
+		return err
+	}
+
+	d.seclog.Record(ctx, wallet.SecEvBackendDetach, backend, "")
+	return nil
+}
+
+func (d *walletDaemon) WalletBackendList(ctx context.Context) ([]api.BackendInfo, error) {
+	out := []api.BackendInfo{}
+	for _, b := range d.backends.list() {
+		out = append(out, api.BackendInfo{Name: b.name, Endpoint: b.endpoint})
+	}
+	return out, nil
+}
+
+func (d *walletDaemon) WalletRemoteStatus(ctx context.Context) ([]api.RemoteStatus, error) {
+	out := []api.RemoteStatus{}
+	for _, b := range d.backends.list() {
+		rw, ok := b.w.(*remotewallet.RemoteWallet)
+		if !ok {
+			continue
+		}
+		for _, st := range rw.Status() {
+			st.Backend = b.name
+			out = append(out, st)
+		}
+	}
+	return out, nil
+}
+
+var _ api.WalletDaemonAPI = &walletDaemon{}
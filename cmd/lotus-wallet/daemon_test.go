@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-jsonrpc/auth"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/apistruct"
+)
+
+// staticWallet holds a fixed set of addresses
+type staticWallet struct {
+	api.WalletAPI
+
+	addrs []address.Address
+}
+
+func (w *staticWallet) WalletList(ctx context.Context) ([]address.Address, error) {
+	return w.addrs, nil
+}
+
+func (w *staticWallet) WalletHas(ctx context.Context, addr address.Address) (bool, error) {
+	for _, a := range w.addrs {
+		if a == addr {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// requestContext returns the context requests made with the token are served
+// with
+func requestContext(t *testing.T, a *walletAuth, token []byte) context.Context {
+	var ctx context.Context
+	h := a.withTokenClaims(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/rpc/v0", nil)
+	req.Header.Set("Authorization", "Bearer "+string(token))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.NotNil(t, ctx)
+	return ctx
+}
+
+func TestHasOnlyScope(t *testing.T) {
+	a := newTestAuth()
+	addr, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+	d := &walletDaemon{WalletAPI: &staticWallet{addrs: []address.Address{addr}}}
+
+	_, err = a.AuthNewScoped(context.Background(), []auth.Permission{apistruct.PermRead}, []string{"unknown"})
+	require.Error(t, err)
+
+	tok, err := a.AuthNewScoped(context.Background(), []auth.Permission{apistruct.PermRead, apistruct.PermWrite}, []string{api.TokenScopeHasOnly})
+	require.NoError(t, err)
+	ctx := requestContext(t, a, tok)
+	require.True(t, hasScope(ctx, api.TokenScopeHasOnly))
+
+	have, err := d.WalletHas(ctx, addr)
+	require.NoError(t, err)
+	require.True(t, have)
+
+	_, err = d.WalletList(ctx)
+	require.Error(t, err)
+	_, err = d.WalletPolicyList(ctx)
+	require.Error(t, err)
+	_, err = d.WalletGroupList(ctx)
+	require.Error(t, err)
+	_, err = d.WalletHDList(ctx)
+	require.Error(t, err)
+	_, err = d.WalletRoutingGet(ctx)
+	require.Error(t, err)
+
+	// tokens without the scope can list
+	tok, err = a.AuthNew(context.Background(), []auth.Permission{apistruct.PermRead})
+	require.NoError(t, err)
+	ctx = requestContext(t, a, tok)
+	require.False(t, hasScope(ctx, api.TokenScopeHasOnly))
+
+	l, err := d.WalletList(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []address.Address{addr}, l)
+}
+
+func TestWalletHasMany(t *testing.T) {
+	ctx := context.Background()
+
+	var addrs []address.Address
+	for i := 0; i < 100; i++ {
+		a, err := address.NewIDAddress(uint64(1000 + i))
+		require.NoError(t, err)
+		addrs = append(addrs, a)
+	}
+	d := &walletDaemon{WalletAPI: &staticWallet{addrs: addrs[:50]}}
+
+	have, err := d.WalletHasMany(ctx, addrs)
+	require.NoError(t, err)
+	require.Len(t, have, len(addrs))
+	for i := range addrs {
+		require.Equal(t, i < 50, have[i], "address %d", i)
+	}
+
+	_, err = d.WalletHasMany(ctx, make([]address.Address, maxHasMany+1))
+	require.Error(t, err)
+}
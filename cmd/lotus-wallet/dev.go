@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+func (d *walletDaemon) WalletDevNew(ctx context.Context, typ types.KeyType) (address.Address, error) {
+	if address.CurrentNetwork == address.Mainnet {
+		return address.Undef, xerrors.Errorf("development keys can't be created on mainnet")
+	}
+
+	d.devLk.Lock()
+	defer d.devLk.Unlock()
+
+	groups, err := d.policy.WalletGroupList(ctx)
+	if err != nil {
+		return address.Undef, err
+	}
+	g := api.KeyGroup{Name: api.DevKeyGroup}
+	for _, eg := range groups {
+		if eg.Name == api.DevKeyGroup {
+			g = eg
+		}
+	}
+
+	a, err := d.WalletAPI.WalletNew(ctx, typ)
+	if err != nil {
+		return address.Undef, err
+	}
+
+	g.Members = append(g.Members, a)
+	if err := d.policy.WalletGroupSet(ctx, g); err != nil {
+		return address.Undef, xerrors.Errorf("marking %s as a development key: %w", a, err)
+	}
+
+	log.Warnw("created development key", "address", a)
+	return a, nil
+}
+
+var walletDev = &cli.Command{
+	Name:  "dev",
+	Usage: "Development keys and testnet funds",
+	Subcommands: []*cli.Command{
+		walletDevNew,
+		walletDevFaucet,
+	},
+}
+
+var walletDevNew = &cli.Command{
+	Name:      "new",
+	Usage:     "Generate a development key, refused on mainnet",
+	ArgsUsage: "[bls|secp256k1 (default secp256k1)]",
+	Description: `Development keys are added to the '` + api.DevKeyGroup + `' key group so they are easy to tell
+   apart, and to freeze or remove. They must never hold mainnet funds.`,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "insecure",
+			Usage: "confirm the key is only used for development",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Bool("insecure") {
+			return xerrors.Errorf("development keys are not meant to secure funds, pass --insecure to confirm")
+		}
+
+		t := cctx.Args().First()
+		if t == "" {
+			t = "secp256k1"
+		}
+
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		a, err := napi.WalletDevNew(ctx, types.KeyType(t))
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(a)
+		_, _ = fmt.Fprintln(os.Stderr, "INSECURE DEVELOPMENT KEY, don't send mainnet funds to it")
+		return nil
+	},
+}
+
+var walletDevFaucet = &cli.Command{
+	Name:      "faucet",
+	Usage:     "Request testnet funds for an address from a faucet",
+	ArgsUsage: "[address]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "faucet",
+			Usage: "faucet url",
+			Value: "https://faucet.calibration.fildev.network",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("expected 1 argument")
+		}
+
+		a, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			return xerrors.Errorf("parsing address: %w", err)
+		}
+
+		ctx, cancel := context.WithTimeout(lcli.ReqContext(cctx), time.Minute)
+		defer cancel()
+
+		form := url.Values{"address": {a.String()}}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(cctx.String("faucet"), "/")+"/send", strings.NewReader(form.Encode()))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return xerrors.Errorf("requesting funds: %w", err)
+		}
+		defer resp.Body.Close() //nolint:errcheck
+
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return xerrors.Errorf("reading faucet response: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return xerrors.Errorf("faucet returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+
+		fmt.Printf("requested funds for %s, message: %s\n", a, strings.TrimSpace(string(body)))
+		return nil
+	},
+}
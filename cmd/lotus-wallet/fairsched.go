@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/wallet"
+	"github.com/filecoin-project/lotus/lib/retryhint"
+	"github.com/filecoin-project/lotus/metrics"
+)
+
+// fairScheduler limits the number of concurrent WalletSign calls. When all
+// slots are busy, waiting requests are admitted per caller host in weighted
+// round robin order, so that one busy client can't starve the others. With
+// maxQueued set, requests arriving while the queue is full are refused with a
+// retry-after hint.
+//
+// Critical requests (see wallet.SignPriority) skip the per caller queues and
+// the queue limit, and get the next free slot. With slots set to 0 there is
+// no limit, and the scheduler only records latency metrics.
+type fairScheduler struct {
+	api.WalletAPI
+
+	slots     int
+	maxQueued int
+	weights   map[string]int
+	slo       map[string]time.Duration // latency objective per priority
+
+	lk      sync.Mutex
+	running int
+	queued  int
+	queues  map[string][]*schedWaiter
+	urgent  []*schedWaiter // critical requests, admitted before all others
+	ring    []string       // callers with waiting requests
+	cur     int            // index into ring
+	credit  int            // requests ring[cur] may still be admitted this round
+	avgSign time.Duration  // moving average of sign request duration
+}
+
+type schedWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+func newFairScheduler(under api.WalletAPI, slots, maxQueued int, weights map[string]int, slo map[string]time.Duration) *fairScheduler {
+	return &fairScheduler{
+		WalletAPI: under,
+		slots:     slots,
+		maxQueued: maxQueued,
+		weights:   weights,
+		slo:       slo,
+		queues:    map[string][]*schedWaiter{},
+	}
+}
+
+// parseCallerWeights parses host=weight pairs.
+func parseCallerWeights(specs []string) (map[string]int, error) {
+	out := map[string]int{}
+	for _, s := range specs {
+		kv := strings.SplitN(s, "=", 2)
+		if len(kv) != 2 {
+			return nil, xerrors.Errorf("caller weight %q not in host=weight format", s)
+		}
+		w, err := strconv.Atoi(kv[1])
+		if err != nil || w < 1 {
+			return nil, xerrors.Errorf("caller weight %q must be a positive integer", s)
+		}
+		out[kv[0]] = w
+	}
+	return out, nil
+}
+
+func callerHost(ctx context.Context) string {
+	c := wallet.CallerFromContext(ctx)
+	if h, _, err := net.SplitHostPort(c); err == nil {
+		return h
+	}
+	return c
+}
+
+func (s *fairScheduler) weight(caller string) int {
+	if w, ok := s.weights[caller]; ok {
+		return w
+	}
+	return 1
+}
+
+func (s *fairScheduler) WalletSign(ctx context.Context, signer address.Address, toSign []byte, meta api.MsgMeta) (*crypto.Signature, error) {
+	received := time.Now()
+	prio := wallet.SignPriority(toSign, meta)
+	defer s.recordLatency(prio, received)
+
+	actx, span := trace.StartSpan(ctx, "fairScheduler.acquire")
+	span.AddAttributes(trace.StringAttribute("priority", prio))
+	err := s.acquire(actx, callerHost(ctx), prio == wallet.PriorityCritical)
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	defer func() {
+		s.release(time.Since(start))
+	}()
+
+	return s.WalletAPI.WalletSign(ctx, signer, toSign, meta)
+}
+
+func (s *fairScheduler) recordLatency(prio string, received time.Time) {
+	took := time.Since(received)
+
+	ctx, _ := tag.New(context.Background(), tag.Upsert(metrics.WalletPriority, prio))
+	stats.Record(ctx, metrics.WalletSignLatency.M(float64(took.Nanoseconds())/1e6))
+
+	if slo, ok := s.slo[prio]; ok && slo > 0 && took > slo {
+		log.Warnw("sign request exceeded latency objective", "priority", prio, "took", took, "objective", slo)
+		stats.Record(ctx, metrics.WalletSignSLOMissed.M(1))
+	}
+}
+
+func (s *fairScheduler) acquire(ctx context.Context, caller string, critical bool) error {
+	if s.slots <= 0 {
+		return nil
+	}
+
+	s.lk.Lock()
+	if s.running < s.slots && len(s.ring) == 0 && len(s.urgent) == 0 {
+		s.running++
+		s.lk.Unlock()
+		return nil
+	}
+
+	if critical {
+		w := &schedWaiter{ready: make(chan struct{})}
+		s.urgent = append(s.urgent, w)
+		s.lk.Unlock()
+
+		return s.wait(ctx, w, func() {
+			for i := range s.urgent {
+				if s.urgent[i] == w {
+					s.urgent = append(s.urgent[:i], s.urgent[i+1:]...)
+					break
+				}
+			}
+		})
+	}
+
+	if s.maxQueued > 0 && s.queued >= s.maxQueued {
+		// every queued request still needs a slot before this one would run
+		after := s.avgSign * time.Duration(s.queued/s.slots+1)
+		s.lk.Unlock()
+		return retryhint.Later(after, xerrors.Errorf("sign queue is full (%d requests waiting)", s.maxQueued))
+	}
+
+	w := &schedWaiter{ready: make(chan struct{})}
+	s.queued++
+	if len(s.queues[caller]) == 0 {
+		s.ring = append(s.ring, caller)
+		if len(s.ring) == 1 {
+			s.cur = 0
+			s.credit = s.weight(caller)
+		}
+	}
+	s.queues[caller] = append(s.queues[caller], w)
+	s.recordDepth(caller)
+	s.lk.Unlock()
+
+	return s.wait(ctx, w, func() {
+		s.queued--
+		q := s.queues[caller]
+		for i := range q {
+			if q[i] == w {
+				s.queues[caller] = append(q[:i], q[i+1:]...)
+				break
+			}
+		}
+		if len(s.queues[caller]) == 0 {
+			s.dropFromRing(caller)
+		}
+		s.recordDepth(caller)
+	})
+}
+
+// wait blocks until w is admitted. If ctx is cancelled first, dequeue is
+// called with s.lk held to remove the waiter.
+func (s *fairScheduler) wait(ctx context.Context, w *schedWaiter, dequeue func()) error {
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.lk.Lock()
+		defer s.lk.Unlock()
+
+		if w.granted {
+			// raced with dispatch, hand the slot on
+			s.running--
+			s.dispatch()
+			return ctx.Err()
+		}
+
+		dequeue()
+		return ctx.Err()
+	}
+}
+
+func (s *fairScheduler) release(took time.Duration) {
+	if s.slots <= 0 {
+		return
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if s.avgSign == 0 {
+		s.avgSign = took
+	} else {
+		s.avgSign += (took - s.avgSign) / 8
+	}
+
+	s.running--
+	s.dispatch()
+}
+
+// dispatch admits waiting requests while there are free slots. Must be called
+// with s.lk held.
+func (s *fairScheduler) dispatch() {
+	for s.running < s.slots && len(s.urgent) > 0 {
+		w := s.urgent[0]
+		s.urgent = s.urgent[1:]
+		w.granted = true
+		close(w.ready)
+		s.running++
+	}
+
+	for s.running < s.slots && len(s.ring) > 0 {
+		caller := s.ring[s.cur]
+		q := s.queues[caller]
+
+		w := q[0]
+		s.queues[caller] = q[1:]
+		s.queued--
+		w.granted = true
+		close(w.ready)
+		s.running++
+		s.credit--
+		s.recordDepth(caller)
+
+		if len(s.queues[caller]) == 0 {
+			s.dropFromRing(caller)
+		} else if s.credit <= 0 {
+			s.cur = (s.cur + 1) % len(s.ring)
+			s.credit = s.weight(s.ring[s.cur])
+		}
+	}
+}
+
+func (s *fairScheduler) dropFromRing(caller string) {
+	for i, c := range s.ring {
+		if c != caller {
+			continue
+		}
+
+		s.ring = append(s.ring[:i], s.ring[i+1:]...)
+		delete(s.queues, caller)
+		if len(s.ring) == 0 {
+			s.cur = 0
+			return
+		}
+		if i < s.cur {
+			s.cur--
+		} else if i == s.cur {
+			s.cur = s.cur % len(s.ring)
+			s.credit = s.weight(s.ring[s.cur])
+		}
+		return
+	}
+}
+
+func (s *fairScheduler) recordDepth(caller string) {
+	ctx, _ := tag.New(context.Background(), tag.Upsert(metrics.WalletCaller, caller))
+	stats.Record(ctx, metrics.WalletSignQueueDepth.M(int64(len(s.queues[caller]))))
+}
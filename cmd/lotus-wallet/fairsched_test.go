@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/wallet"
+)
+
+// countingWallet records how many sign requests run at the same time
+type countingWallet struct {
+	api.WalletAPI
+
+	lk      sync.Mutex
+	running int
+	max     int
+}
+
+func (w *countingWallet) WalletSign(ctx context.Context, signer address.Address, toSign []byte, meta api.MsgMeta) (*crypto.Signature, error) {
+	w.lk.Lock()
+	w.running++
+	if w.running > w.max {
+		w.max = w.running
+	}
+	w.lk.Unlock()
+
+	time.Sleep(2 * time.Millisecond)
+
+	w.lk.Lock()
+	w.running--
+	w.lk.Unlock()
+
+	return &crypto.Signature{Type: crypto.SigTypeSecp256k1}, nil
+}
+
+func requireSchedulerIdle(t *testing.T, s *fairScheduler) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	require.Equal(t, 0, s.running)
+	require.Equal(t, 0, s.queued)
+	require.Empty(t, s.ring)
+	require.Empty(t, s.urgent)
+}
+
+func TestFairSchedulerLimitsConcurrency(t *testing.T) {
+	cw := &countingWallet{}
+	s := newFairScheduler(cw, 2, 0, map[string]int{"10.0.0.1": 3}, nil)
+
+	const n = 30
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		ctx := wallet.WithCaller(context.Background(), fmt.Sprintf("10.0.0.%d:1234", i%3))
+		go func(i int) {
+			_, err := s.WalletSign(ctx, address.Undef, []byte(fmt.Sprintf("data %d", i)), api.MsgMeta{Type: api.MTUnknown})
+			errs <- err
+		}(i)
+	}
+	for i := 0; i < n; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	require.LessOrEqual(t, cw.max, 2)
+	requireSchedulerIdle(t, s)
+}
+
+func TestFairSchedulerCancelledWaiters(t *testing.T) {
+	cw := &countingWallet{}
+	s := newFairScheduler(cw, 1, 0, nil, nil)
+
+	const n = 20
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		ctx := wallet.WithCaller(context.Background(), fmt.Sprintf("10.0.0.%d:1234", i%4))
+		cancel := func() {}
+		if i%2 == 1 {
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(i)*time.Millisecond)
+		}
+		go func(i int) {
+			defer cancel()
+			_, err := s.WalletSign(ctx, address.Undef, []byte(fmt.Sprintf("data %d", i)), api.MsgMeta{Type: api.MTUnknown})
+			errs <- err
+		}(i)
+	}
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			require.True(t, xerrors.Is(err, context.DeadlineExceeded), err)
+		}
+	}
+
+	// abandoned requests must not leak slots
+	requireSchedulerIdle(t, s)
+	_, err := s.WalletSign(context.Background(), address.Undef, []byte("after"), api.MsgMeta{Type: api.MTUnknown})
+	require.NoError(t, err)
+	require.LessOrEqual(t, cw.max, 1)
+}
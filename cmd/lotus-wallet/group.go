@@ -0,0 +1,361 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var groupCmd = &cli.Command{
+	Name:  "group",
+	Usage: "Manage key groups of a running lotus wallet",
+	Subcommands: []*cli.Command{
+		groupListCmd,
+		groupCreateCmd,
+		groupAddCmd,
+		groupRemoveMemberCmd,
+		groupDeleteCmd,
+		groupFreezeCmd,
+		groupUnfreezeCmd,
+		groupSetPolicyCmd,
+		groupReportCmd,
+	},
+}
+
+func parseAddrs(args []string) ([]address.Address, error) {
+	out := make([]address.Address, len(args))
+	for i, s := range args {
+		a, err := address.NewFromString(s)
+		if err != nil {
+			return nil, xerrors.Errorf("parsing address %q: %w", s, err)
+		}
+		out[i] = a
+	}
+	return out, nil
+}
+
+var groupListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "List key groups",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		groups, err := api.WalletGroupList(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Name\tMembers\tFrozen\tPolicy")
+		for _, g := range groups {
+			pol := "none"
+			if g.Policy != nil {
+				pol = formatPolicy(g.Policy)
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%d\t%t\t%s\n", g.Name, len(g.Members), g.Frozen, pol)
+		}
+		return tw.Flush()
+	},
+}
+
+var groupCreateCmd = &cli.Command{
+	Name:      "create",
+	Usage:     "Create a key group",
+	ArgsUsage: "<name> [member addresses...]",
+	Flags:     []cli.Flag{typoCheckFlag},
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Args().Present() {
+			return xerrors.Errorf("must specify the group name")
+		}
+
+		members, err := parseAddrs(cctx.Args().Tail())
+		if err != nil {
+			return err
+		}
+
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		name := cctx.Args().First()
+		if _, err := napi.WalletGroupGet(ctx, name); err == nil {
+			return xerrors.Errorf("key group %q already exists", name)
+		}
+		if err := checkAddressTypos(ctx, cctx, napi, members...); err != nil {
+			return err
+		}
+
+		return napi.WalletGroupSet(ctx, api.KeyGroup{
+			Name:    name,
+			Members: members,
+		})
+	},
+}
+
+var groupAddCmd = &cli.Command{
+	Name:      "add",
+	Usage:     "Add addresses to a key group",
+	ArgsUsage: "<name> <addresses...>",
+	Flags:     []cli.Flag{typoCheckFlag},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() < 2 {
+			return xerrors.Errorf("must specify the group name and addresses")
+		}
+
+		add, err := parseAddrs(cctx.Args().Tail())
+		if err != nil {
+			return err
+		}
+
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		g, err := api.WalletGroupGet(ctx, cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		if err := checkAddressTypos(ctx, cctx, api, add...); err != nil {
+			return err
+		}
+
+		have := map[address.Address]struct{}{}
+		for _, m := range g.Members {
+			have[m] = struct{}{}
+		}
+		for _, a := range add {
+			if _, ok := have[a]; !ok {
+				g.Members = append(g.Members, a)
+				have[a] = struct{}{}
+			}
+		}
+
+		return api.WalletGroupSet(ctx, *g)
+	},
+}
+
+var groupRemoveMemberCmd = &cli.Command{
+	Name:      "remove-member",
+	Usage:     "Remove addresses from a key group",
+	ArgsUsage: "<name> <addresses...>",
+	Flags:     []cli.Flag{typoCheckFlag},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() < 2 {
+			return xerrors.Errorf("must specify the group name and addresses")
+		}
+
+		rm, err := parseAddrs(cctx.Args().Tail())
+		if err != nil {
+			return err
+		}
+
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		g, err := api.WalletGroupGet(ctx, cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		if err := checkAddressTypos(ctx, cctx, api, rm...); err != nil {
+			return err
+		}
+
+		drop := map[address.Address]struct{}{}
+		for _, a := range rm {
+			drop[a] = struct{}{}
+		}
+		members := g.Members[:0]
+		for _, m := range g.Members {
+			if _, ok := drop[m]; !ok {
+				members = append(members, m)
+			}
+		}
+		g.Members = members
+
+		return api.WalletGroupSet(ctx, *g)
+	},
+}
+
+var groupDeleteCmd = &cli.Command{
+	Name:      "delete",
+	Usage:     "Delete a key group, member keys are not affected",
+	ArgsUsage: "<name>",
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return xerrors.Errorf("must specify the group name")
+		}
+
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		return api.WalletGroupRemove(ctx, cctx.Args().First())
+	},
+}
+
+var groupFreezeCmd = &cli.Command{
+	Name:      "freeze",
+	Usage:     "Refuse all sign requests for members of a key group",
+	ArgsUsage: "<name>",
+	Action: func(cctx *cli.Context) error {
+		return setGroupFrozen(cctx, true)
+	},
+}
+
+var groupUnfreezeCmd = &cli.Command{
+	Name:      "unfreeze",
+	Usage:     "Allow members of a frozen key group to sign again",
+	ArgsUsage: "<name>",
+	Action: func(cctx *cli.Context) error {
+		return setGroupFrozen(cctx, false)
+	},
+}
+
+func setGroupFrozen(cctx *cli.Context, frozen bool) error {
+	if cctx.NArg() != 1 {
+		return xerrors.Errorf("must specify the group name")
+	}
+
+	api, closer, err := lcli.GetWalletAPI(cctx)
+	if err != nil {
+		return err
+	}
+	defer closer()
+	ctx := lcli.ReqContext(cctx)
+
+	return api.WalletGroupFreeze(ctx, cctx.Args().First(), frozen)
+}
+
+var groupSetPolicyCmd = &cli.Command{
+	Name:      "set-policy",
+	Usage:     "Set the signing policy applied to all members of a key group",
+	ArgsUsage: "<name>",
+	Flags: append([]cli.Flag{
+		&cli.BoolFlag{
+			Name:  "clear",
+			Usage: "remove the group policy",
+		},
+		typoCheckFlag,
+	}, policyFlags...),
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return xerrors.Errorf("must specify the group name")
+		}
+
+		pol, err := policyFromFlags(cctx)
+		if err != nil {
+			return err
+		}
+		if cctx.Bool("clear") {
+			pol = nil
+		}
+
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		g, err := api.WalletGroupGet(ctx, cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		if pol != nil {
+			if err := checkAddressTypos(ctx, cctx, api, pol.AllowedTo...); err != nil {
+				return err
+			}
+		}
+
+		g.Policy = pol
+		return api.WalletGroupSet(ctx, *g)
+	},
+}
+
+var groupReportCmd = &cli.Command{
+	Name:      "report",
+	Usage:     "Show policies and recent spend of every member of a key group",
+	ArgsUsage: "<name>",
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return xerrors.Errorf("must specify the group name")
+		}
+
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		g, err := api.WalletGroupGet(ctx, cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Group:  %s\n", g.Name)
+		fmt.Printf("Frozen: %t\n", g.Frozen)
+		if g.Policy != nil {
+			fmt.Printf("Policy: %s\n", formatPolicy(g.Policy))
+		} else {
+			fmt.Println("Policy: none")
+		}
+		fmt.Println()
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Address\tPresent\tSpent (24h)\tPolicy")
+		for _, m := range g.Members {
+			have, err := api.WalletHas(ctx, m)
+			if err != nil {
+				return xerrors.Errorf("checking %s: %w", m, err)
+			}
+
+			st, err := api.WalletSpendGet(ctx, m)
+			if err != nil {
+				return xerrors.Errorf("getting spend of %s: %w", m, err)
+			}
+
+			pol, err := api.WalletPolicyGet(ctx, m)
+			if err != nil {
+				return xerrors.Errorf("getting policy of %s: %w", m, err)
+			}
+			ps := "none"
+			if pol != nil {
+				ps = formatPolicy(pol)
+			}
+
+			_, _ = fmt.Fprintf(tw, "%s\t%t\t%s\t%s\n", m, have, types.FIL(st.Spent), ps)
+		}
+		return tw.Flush()
+	},
+}
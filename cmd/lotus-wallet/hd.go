@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/chain/wallet"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+func (d *walletDaemon) WalletHDInit(ctx context.Context, mnemonic, passphrase string) error {
+	if err := d.HDWallet.WalletHDInit(ctx, mnemonic, passphrase); err != nil {
+		return err
+	}
+
+	d.seclog.Record(ctx, wallet.SecEvHDInit, "", "")
+	return nil
+}
+
+var walletHD = &cli.Command{
+	Name:  "hd",
+	Usage: "Manage accounts derived from the wallet hd seed",
+	Description: `The hd seed is stored in the wallet repo, sealed to the wallet instance key.
+   Accounts are secp256k1 keys derived from it at m/44'/461'/0'/0/<index>
+   unless another path is given, so the mnemonic backs up all of them.`,
+	Subcommands: []*cli.Command{
+		walletHDInit,
+		walletHDDerive,
+		walletHDList,
+		walletHDLabel,
+	},
+}
+
+var walletHDInit = &cli.Command{
+	Name:  "init",
+	Usage: "Generate the hd seed, or set it from an existing mnemonic",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "restore",
+			Usage: "read an existing mnemonic from stdin instead of generating one",
+		},
+		&cli.IntFlag{
+			Name:  "words",
+			Usage: "number of words of a generated mnemonic, 12 or 24",
+			Value: 24,
+		},
+		&cli.BoolFlag{
+			Name:  "passphrase",
+			Usage: "prompt for a BIP39 passphrase protecting the mnemonic",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		var mnemonic string
+		var err error
+		if cctx.Bool("restore") {
+			mnemonic, err = readLine("Enter mnemonic: ")
+		} else {
+			mnemonic, err = wallet.NewMnemonic(cctx.Int("words"))
+		}
+		if err != nil {
+			return err
+		}
+
+		var passphrase string
+		if cctx.Bool("passphrase") {
+			if passphrase, err = readLine("Enter passphrase: "); err != nil {
+				return err
+			}
+		}
+
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		if err := napi.WalletHDInit(ctx, mnemonic, passphrase); err != nil {
+			return err
+		}
+
+		if !cctx.Bool("restore") {
+			fmt.Println(mnemonic)
+			_, _ = fmt.Fprintln(os.Stderr, "\nWrite down the mnemonic above, it is the only backup of hd accounts")
+		}
+		return nil
+	},
+}
+
+var walletHDDerive = &cli.Command{
+	Name:  "derive",
+	Usage: "Derive and import an account",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "index",
+			Usage: "derive the account with this index on the default path (default: the next unused index)",
+		},
+		&cli.StringFlag{
+			Name:  "path",
+			Usage: "derive the account at this BIP32 path",
+		},
+		&cli.StringFlag{
+			Name:  "label",
+			Usage: "label of the account",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		path := cctx.String("path")
+		if s := cctx.String("index"); s != "" {
+			if path != "" {
+				return xerrors.Errorf("--index and --path can't be combined")
+			}
+			idx, err := strconv.ParseUint(s, 10, 31)
+			if err != nil {
+				return xerrors.Errorf("parsing index: %w", err)
+			}
+			path = wallet.FormatPath(wallet.MnemonicPath(uint32(idx)))
+		}
+
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		a, err := napi.WalletNewHD(ctx, path)
+		if err != nil {
+			return err
+		}
+
+		if l := cctx.String("label"); l != "" {
+			if err := napi.WalletHDLabel(ctx, a, l); err != nil {
+				return xerrors.Errorf("labeling %s: %w", a, err)
+			}
+		}
+
+		fmt.Println(a)
+		return nil
+	},
+}
+
+var walletHDList = &cli.Command{
+	Name:  "list",
+	Usage: "List derived accounts",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		accts, err := napi.WalletHDList(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Path\tAddress\tLabel")
+		for _, a := range accts {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", a.Path, a.Address, a.Label)
+		}
+		return tw.Flush()
+	},
+}
+
+var walletHDLabel = &cli.Command{
+	Name:      "label",
+	Usage:     "Set the label of a derived account",
+	ArgsUsage: "[address or index] [label]",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 2 {
+			return xerrors.Errorf("expected 2 arguments")
+		}
+
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		a, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			idx, ierr := strconv.ParseUint(cctx.Args().First(), 10, 31)
+			if ierr != nil {
+				return xerrors.Errorf("expected an address or account index: %w", err)
+			}
+
+			accts, err := napi.WalletHDList(ctx)
+			if err != nil {
+				return err
+			}
+
+			path := wallet.FormatPath(wallet.MnemonicPath(uint32(idx)))
+			for _, acct := range accts {
+				if acct.Path == path {
+					a = acct.Address
+				}
+			}
+			if a == address.Undef {
+				return xerrors.Errorf("no account with index %d", idx)
+			}
+		}
+
+		return napi.WalletHDLabel(ctx, a, cctx.Args().Get(1))
+	},
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/wallet"
+	"github.com/filecoin-project/lotus/chain/wallet/remotewallet"
+)
+
+// ledgerCheckInterval limits how often readiness probes open the ledger
+// device, so that frequent probes don't get in the way of signing.
+const ledgerCheckInterval = 10 * time.Second
+
+// healthChecks serves the unauthenticated /healthz and /readyz endpoints on the
+// monitoring mux. /healthz reports whether the key store is accessible, /readyz
+// whether the configured backends can serve sign requests. Responses only name
+// the failing checks; errors and endpoints are logged.
+type healthChecks struct {
+	ks       types.KeyStore
+	backends *backendSet
+	breakers *wallet.Breakers
+
+	lk        sync.Mutex
+	ledgerAt  time.Time
+	ledgerErr error
+}
+
+func (h *healthChecks) healthz(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.ks.List(); err != nil {
+		log.Warnw("health check: listing keystore", "error", err)
+		respondHealth(w, []string{"keystore: not accessible"})
+		return
+	}
+	respondHealth(w, nil)
+}
+
+func (h *healthChecks) readyz(w http.ResponseWriter, r *http.Request) {
+	respondHealth(w, h.notReady())
+}
+
+// notReady returns the reasons the wallet can't serve sign requests
+func (h *healthChecks) notReady() []string {
+	var out []string
+
+	if _, err := h.ks.List(); err != nil {
+		log.Warnw("readiness check: listing keystore", "error", err)
+		out = append(out, "keystore: not accessible")
+	}
+
+	for _, bs := range h.breakers.Status() {
+		if bs.State == wallet.BackendDegraded {
+			log.Warnw("readiness check: backend degraded", "backend", bs.Name, "error", bs.LastError)
+			out = append(out, fmt.Sprintf("backend %s: degraded", bs.Name))
+		}
+	}
+
+	if lw := h.backends.ledger(); lw != nil {
+		if err := h.checkLedger(lw.CheckDevice); err != nil {
+			log.Warnw("readiness check: ledger", "error", err)
+			out = append(out, "backend ledger: device not reachable")
+		}
+	}
+
+	for _, b := range h.backends.list() {
+		rw, ok := b.w.(*remotewallet.RemoteWallet)
+		if !ok {
+			continue
+		}
+		for _, st := range rw.Status() {
+			// lazily connected backends haven't been dialed yet, which is fine
+			// as long as dialing didn't fail
+			if st.State == remotewallet.ConnUnreachable || (st.State == remotewallet.ConnNotConnected && st.LastError != "") {
+				log.Warnw("readiness check: remote wallet", "backend", b.name, "role", st.Role, "addr", st.Addr, "state", st.State, "error", st.LastError)
+				out = append(out, fmt.Sprintf("backend %s: %s %s", b.name, st.Role, st.State))
+			}
+		}
+	}
+
+	return out
+}
+
+func (h *healthChecks) checkLedger(check func() error) error {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	if time.Since(h.ledgerAt) < ledgerCheckInterval {
+		return h.ledgerErr
+	}
+	h.ledgerErr = check()
+	h.ledgerAt = time.Now()
+	return h.ledgerErr
+}
+
+func respondHealth(w http.ResponseWriter, problems []string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if len(problems) == 0 {
+		_, _ = fmt.Fprintln(w, "ok")
+		return
+	}
+
+	w.WriteHeader(http.StatusServiceUnavailable)
+	for _, p := range problems {
+		_, _ = fmt.Fprintln(w, p)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var journalCmd = &cli.Command{
+	Name:  "journal",
+	Usage: "Manage the signature journal of a running lotus wallet",
+	Subcommands: []*cli.Command{
+		journalPruneCmd,
+	},
+}
+
+var journalPruneCmd = &cli.Command{
+	Name:  "prune",
+	Usage: "Remove old signature records",
+	Flags: []cli.Flag{
+		&cli.DurationFlag{
+			Name:  "max-age",
+			Usage: "remove records older than this",
+		},
+		&cli.IntFlag{
+			Name:  "max-entries",
+			Usage: "keep at most this many records, removing the oldest",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if !cctx.IsSet("max-age") && !cctx.IsSet("max-entries") {
+			return fmt.Errorf("at least one of --max-age or --max-entries must be set")
+		}
+
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		n, err := api.WalletJournalPrune(ctx, cctx.Duration("max-age"), cctx.Int("max-entries"))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("removed %d records\n", n)
+		return nil
+	},
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+func (d *walletDaemon) WalletLedgerShow(ctx context.Context, addr address.Address) error {
+	lw := d.backends.ledger()
+	if lw == nil {
+		return xerrors.Errorf("the ledger backend is not enabled")
+	}
+
+	return lw.ShowAddress(ctx, addr)
+}
+
+var walletLedgerShow = &cli.Command{
+	Name:      "ledger-show",
+	Usage:     "Display a ledger address on the device screen for verification",
+	ArgsUsage: "<address>",
+	Description: `Compare the address on the device with the one you are about to share. A
+   compromised host can show a different address, the device can't.`,
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return xerrors.Errorf("must specify an address")
+		}
+
+		addr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		fmt.Printf("Check the ledger screen shows %s and confirm on the device\n", addr)
+		if err := napi.WalletLedgerShow(ctx, addr); err != nil {
+			return err
+		}
+
+		fmt.Println("address confirmed on device")
+		return nil
+	},
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"sort"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+type heldAddress struct {
+	addr    address.Address
+	backend namedBackend
+}
+
+// listBackends lists addresses of all backends sorted by address. Addresses
+// present in multiple backends are attributed to the one MultiWallet signs
+// with: remote, then ledger, then local.
+func (d *walletDaemon) listBackends(ctx context.Context) ([]heldAddress, error) {
+	return d.listHolders(ctx, false)
+}
+
+// listHolders lists addresses of all backends sorted by address, then by
+// the order MultiWallet prefers backends in. With all, an address is listed
+// once for every backend holding its key.
+func (d *walletDaemon) listHolders(ctx context.Context, all bool) ([]heldAddress, error) {
+	var out []heldAddress
+	seen := map[address.Address]struct{}{}
+
+	// backends are in local, ledger, remote order
+	backends := d.backends.list()
+	for i := len(backends) - 1; i >= 0; i-- {
+		b := backends[i]
+
+		addrs, err := b.w.WalletList(ctx)
+		if err != nil {
+			return nil, xerrors.Errorf("listing %s backend: %w", b.name, err)
+		}
+
+		for _, addr := range addrs {
+			if _, ok := seen[addr]; ok && !all {
+				continue
+			}
+			seen[addr] = struct{}{}
+
+			out = append(out, heldAddress{addr: addr, backend: b})
+		}
+	}
+
+	// stable, so that copies stay in backend preference order
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].addr.String() < out[j].addr.String()
+	})
+
+	return out, nil
+}
+
+func (d *walletDaemon) WalletListPage(ctx context.Context, f api.WalletListFilter) (*api.WalletListPage, error) {
+	if hasScope(ctx, api.TokenScopeHasOnly) {
+		return nil, xerrors.Errorf("token is restricted to checking for specific addresses")
+	}
+	if f.Offset < 0 || f.Limit < 0 {
+		return nil, xerrors.Errorf("offset and limit can't be negative")
+	}
+
+	held, err := d.listBackends(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var match []address.Address
+	for _, h := range held {
+		if f.Backend != "" && h.backend.name != f.Backend {
+			continue
+		}
+		if f.Protocol != nil && h.addr.Protocol() != *f.Protocol {
+			continue
+		}
+		match = append(match, h.addr)
+	}
+
+	page := &api.WalletListPage{
+		Total:     len(match),
+		Addresses: []address.Address{},
+	}
+	if f.Offset < len(match) {
+		match = match[f.Offset:]
+		if f.Limit > 0 && len(match) > f.Limit {
+			match = match[:f.Limit]
+		}
+		page.Addresses = match
+	}
+
+	return page, nil
+}
+
+func (d *walletDaemon) WalletBackends(ctx context.Context) ([]api.AddressBackend, error) {
+	if hasScope(ctx, api.TokenScopeHasOnly) {
+		return nil, xerrors.Errorf("token is restricted to checking for specific addresses")
+	}
+
+	held, err := d.listHolders(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]api.AddressBackend, 0, len(held))
+	for _, h := range held {
+		out = append(out, api.AddressBackend{
+			Address:  h.addr,
+			Backend:  h.backend.name,
+			Endpoint: h.backend.endpoint,
+		})
+	}
+	return out, nil
+}
@@ -57,6 +57,7 @@ func (c *LoggedWallet) WalletSign(ctx context.Context, k address.Address, msg []
 		log.Infow("WalletSign",
 			"address", k,
 			"type", meta.Type,
+			"reason", meta.Reason,
 			"from", cmsg.From,
 			"to", cmsg.To,
 			"value", types.FIL(cmsg.Value),
@@ -64,7 +65,7 @@ func (c *LoggedWallet) WalletSign(ctx context.Context, k address.Address, msg []
 			"method", cmsg.Method,
 			"params", hex.EncodeToString(cmsg.Params))
 	default:
-		log.Infow("WalletSign", "address", k, "type", meta.Type)
+		log.Infow("WalletSign", "address", k, "type", meta.Type, "reason", meta.Reason)
 	}
 
 	return c.under.WalletSign(ctx, k, msg, meta)
@@ -2,25 +2,51 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	"contrib.go.opencensus.io/exporter/jaeger"
+	"contrib.go.opencensus.io/exporter/prometheus"
+
+	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	logging "github.com/ipfs/go-log/v2"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	promclient "github.com/prometheus/client_golang/prometheus"
 	"github.com/urfave/cli/v2"
+	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/tag"
+	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-jsonrpc"
+	"github.com/filecoin-project/go-jsonrpc/auth"
 
 	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/apistruct"
 	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/chain/wallet"
 	ledgerwallet "github.com/filecoin-project/lotus/chain/wallet/ledger"
+	"github.com/filecoin-project/lotus/chain/wallet/remotewallet"
+	"github.com/filecoin-project/lotus/chain/wallet/vault"
 	lcli "github.com/filecoin-project/lotus/cli"
+	cliutil "github.com/filecoin-project/lotus/cli/util"
 	"github.com/filecoin-project/lotus/lib/lotuslog"
+	"github.com/filecoin-project/lotus/lib/reqsign"
+	"github.com/filecoin-project/lotus/lib/tracing"
 	"github.com/filecoin-project/lotus/metrics"
+	"github.com/filecoin-project/lotus/node/modules"
 	"github.com/filecoin-project/lotus/node/repo"
 )
 
@@ -33,6 +59,20 @@ func main() {
 
 	local := []*cli.Command{
 		runCmd,
+		walletCmd,
+		authCmd,
+		journalCmd,
+		policyCmd,
+		groupCmd,
+		approvalsCmd,
+		auditCmd,
+		securityLogCmd,
+		custodyReportCmd,
+		statusCmd,
+		backendCmd,
+		logCmd,
+		genVectorsCmd,
+		repoCmd,
 	}
 
 	app := &cli.App{
@@ -50,6 +90,7 @@ func main() {
 		Commands: local,
 	}
 	app.Setup()
+	app.Metadata["repoType"] = repo.Wallet
 
 	if err := app.Run(os.Args); err != nil {
 		log.Warnf("%+v", err)
@@ -57,19 +98,267 @@ func main() {
 	}
 }
 
+// wsClients counts api clients connected over websocket
+type wsClients struct {
+	n int64
+}
+
+// count keeps the count and the websocket client gauge up to date. The rpc
+// server serves websocket connections until they are closed.
+func (c *wsClients) count(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		atomic.AddInt64(&c.n, 1)
+		defer atomic.AddInt64(&c.n, -1)
+		stats.Record(r.Context(), metrics.WalletWSClients.M(1))
+		defer stats.Record(r.Context(), metrics.WalletWSClients.M(-1))
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *wsClients) connected() int {
+	return int(atomic.LoadInt64(&c.n))
+}
+
+// withCaller records the remote address of api clients in request contexts
+func withCaller(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(wallet.WithCaller(r.Context(), r.RemoteAddr)))
+	})
+}
+
 var runCmd = &cli.Command{
 	Name:  "run",
 	Usage: "Start lotus wallet",
 	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "config",
+			Usage: "TOML config file with 'run' flag values and log levels, re-read on SIGHUP (default: config.toml in the wallet repo, if present)",
+		},
 		&cli.StringFlag{
 			Name:  "listen",
 			Usage: "host address and port the wallet api will listen on",
 			Value: "0.0.0.0:1777",
 		},
+		&cli.BoolFlag{
+			Name:  "in-memory",
+			Usage: "keep keys and metadata in memory only, nothing is written to the repo; keys can be seeded from $" + envInMemoryKeys + " (for tests and throwaway devnets)",
+		},
 		&cli.BoolFlag{
 			Name:  "ledger",
 			Usage: "use a ledger device instead of an on-disk wallet",
 		},
+		&cli.BoolFlag{
+			Name:  "ownership-proofs-ledger",
+			Usage: "also sign WalletListOwned ownership proofs with ledger keys, every proof needs confirming on the device",
+		},
+		&cli.StringFlag{
+			Name:   "ledger-speculos",
+			Usage:  "talk to the Speculos ledger emulator listening for APDUs on this host:port instead of a ledger device (for tests only, implies --ledger)",
+			Hidden: true,
+		},
+		&cli.BoolFlag{
+			Name:  "enable-service-keys",
+			Usage: "allow creating and signing with non-Filecoin service keys (ed25519, secp256r1), kept apart from Filecoin keys",
+		},
+		&cli.StringSliceFlag{
+			Name:  "shard",
+			Usage: "run as a coordinator routing requests to this downstream wallet, as name=token:multiaddr (can be repeated; names must stay stable as they determine where keys are looked up)",
+		},
+		&cli.StringFlag{
+			Name:    "vault-addr",
+			Usage:   "keep local wallet keys in the kv v2 secrets engine of this HashiCorp Vault server instead of the repo keystore",
+			EnvVars: []string{"VAULT_ADDR"},
+		},
+		&cli.StringFlag{
+			Name:    "vault-token",
+			Usage:   "token used to access vault",
+			EnvVars: []string{"VAULT_TOKEN"},
+		},
+		&cli.StringFlag{
+			Name:  "vault-mount",
+			Usage: "mount path of the vault kv v2 secrets engine",
+			Value: "secret",
+		},
+		&cli.StringFlag{
+			Name:  "vault-path",
+			Usage: "path under the vault mount keys are stored at",
+			Value: "lotus-wallet",
+		},
+		&cli.StringSliceFlag{
+			Name:  "remote",
+			Usage: "api info (TOKEN:URL) of an upstream wallet to use as an additional backend; when repeated, requests fail over between the upstreams, which must hold the same keys",
+		},
+		&cli.StringSliceFlag{
+			Name:  "remote-verify",
+			Usage: "api info of an independent upstream which must confirm WalletHas/WalletList answers from --remote",
+		},
+		&cli.StringFlag{
+			Name:  "remote-tls-cert",
+			Usage: "PEM client certificate presented to --remote, --remote-verify and --shard wallets serving the api over TLS (with --remote-tls-key)",
+		},
+		&cli.StringFlag{
+			Name:  "remote-tls-key",
+			Usage: "PEM key of --remote-tls-cert",
+		},
+		&cli.StringFlag{
+			Name:  "remote-tls-ca",
+			Usage: "PEM CA bundle certificates of --remote, --remote-verify and --shard wallets are verified against, on top of the system roots",
+		},
+		&cli.StringFlag{
+			Name:  "tls-cert",
+			Usage: "serve the api over https/wss using this certificate (reloaded on SIGHUP); the recorded api endpoint ends with /wss, so clients reading it from the repo dial wss://",
+		},
+		&cli.StringFlag{
+			Name:  "tls-key",
+			Usage: "private key for --tls-cert",
+		},
+		&cli.StringFlag{
+			Name:  "tls-client-ca",
+			Usage: "require api clients to present a certificate signed by a CA from this PEM bundle (needs --tls-cert)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "tls-client-fingerprint",
+			Usage: "only accept client certificates with this hex sha256 fingerprint (can be repeated)",
+		},
+		&cli.BoolFlag{
+			Name:  "disable-auth",
+			Usage: "serve the api without requiring an auth token (anyone who can reach the api can sign)",
+		},
+		&cli.IntFlag{
+			Name:  "backend-failure-threshold",
+			Usage: "consecutive errors after which a backend is marked degraded and skipped",
+			Value: 3,
+		},
+		&cli.DurationFlag{
+			Name:  "backend-cooldown",
+			Usage: "how long a degraded backend is skipped before it is probed again",
+			Value: 30 * time.Second,
+		},
+		&cli.BoolFlag{
+			Name:  "manual-approval",
+			Usage: "hold every sign request until an operator approves it with 'lotus-wallet approvals approve'",
+		},
+		&cli.StringFlag{
+			Name:  "approval-threshold",
+			Usage: "hold messages with a value above this (e.g. '100 FIL'), and requests which can't be decoded, until they are approved",
+		},
+		&cli.StringSliceFlag{
+			Name:  "approver",
+			Usage: "name of an approver allowed to decide held sign requests (can be repeated); tokens are created with 'auth create-approver-token'",
+		},
+		&cli.StringSliceFlag{
+			Name:  "approval-webhook",
+			Usage: "post new sign requests waiting for approval to this url, as format=url where format is slack, telegram or generic (can be repeated)",
+		},
+		&cli.IntFlag{
+			Name:  "approvals-required",
+			Usage: "number of distinct approvers needed to release a held sign request",
+			Value: 1,
+		},
+		&cli.StringFlag{
+			Name:  "policy-file",
+			Usage: "TOML file with per-address signing policies, applied at startup and on SIGHUP on top of policies set through the api; on SIGHUP, policies of addresses dropped from the file are removed",
+		},
+		&cli.StringFlag{
+			Name:  "routing-file",
+			Usage: "TOML file with backend priorities, address pins and the backends creating new keys, applied at startup replacing routing set through the api",
+		},
+		&cli.IntFlag{
+			Name:  "sign-concurrency",
+			Usage: "maximum number of concurrent sign requests; when set, block and WindowPoSt signatures are admitted first, and other waiting requests fairly across caller hosts (0 for no limit)",
+		},
+		&cli.IntFlag{
+			Name:  "sign-queue-limit",
+			Usage: "with --sign-concurrency, refuse sign requests with a retry-after hint while this many are waiting (0 for no limit)",
+		},
+		&cli.DurationFlag{
+			Name:  "critical-sign-slo",
+			Usage: "latency objective for block and WindowPoSt signatures; slower requests are logged and counted in the wallet/sign_slo_missed metric (0 to disable)",
+			Value: time.Second,
+		},
+		&cli.DurationFlag{
+			Name:  "bulk-sign-slo",
+			Usage: "latency objective for all other signatures (0 to disable)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "caller-weight",
+			Usage: "share of signing slots given to a caller host relative to others, as host=weight (default weight is 1)",
+		},
+		&cli.DurationFlag{
+			Name:  "address-watch-interval",
+			Usage: "how often to list addresses of all backends, alerting on ones which weren't created or imported through this daemon (0 only checks when clients list addresses)",
+			Value: 5 * time.Minute,
+		},
+		&cli.DurationFlag{
+			Name:  "journal-max-age",
+			Usage: "remove signature journal records older than this (0 keeps records forever)",
+		},
+		&cli.IntFlag{
+			Name:  "journal-max-entries",
+			Usage: "keep at most this many signature journal records, removing the oldest (0 for no limit)",
+			Value: 1000000,
+		},
+		&cli.DurationFlag{
+			Name:  "audit-max-age",
+			Usage: "remove audit log entries older than this (0 keeps entries forever)",
+		},
+		&cli.IntFlag{
+			Name:  "audit-max-entries",
+			Usage: "keep at most this many audit log entries, removing the oldest (0 for no limit)",
+			Value: 1000000,
+		},
+		&cli.DurationFlag{
+			Name:  "summary-max-age",
+			Usage: "remove decoded sign request summaries stored longer than this ago (0 keeps summaries forever)",
+			Value: 30 * 24 * time.Hour,
+		},
+		&cli.IntFlag{
+			Name:  "summary-max-entries",
+			Usage: "keep at most this many decoded sign request summaries, removing the oldest (0 for no limit)",
+			Value: 100000,
+		},
+		&cli.StringSliceFlag{
+			Name:  "lazy-backends",
+			Usage: "backends (remote, ledger) which are only initialized on first use; other backends are checked at startup",
+			Value: cli.NewStringSlice("ledger"),
+		},
+		&cli.StringSliceFlag{
+			Name:  "preload",
+			Usage: "address which must be available in one of the backends at startup (can be repeated)",
+		},
+		&cli.StringFlag{
+			Name:  "jaeger-agent",
+			Usage: "export traces of api requests to the jaeger agent at this host:port (defaults to $LOTUS_JAEGER)",
+		},
+		&cli.StringFlag{
+			Name:  "monitoring-listen",
+			Usage: "serve monitoring endpoints (/status, /metrics, /debug) on this host address and port instead of the api listener",
+		},
+		&cli.StringFlag{
+			Name:    "request-signing-key",
+			Usage:   "require rpc requests to carry a timestamp and nonce signed with this shared key (websocket clients sign the handshake)",
+			EnvVars: []string{cliutil.WalletRequestSigningKeyEnv},
+		},
+		&cli.DurationFlag{
+			Name:  "request-skew",
+			Usage: "maximum clock skew allowed for signed request timestamps",
+			Value: 30 * time.Second,
+		},
+		&cli.StringFlag{
+			Name:  "api-version",
+			Usage: "api version advertised through the Version method, as major.minor.patch (default: the full node api version)",
+		},
+		&cli.StringFlag{
+			Name:    "status-token",
+			Usage:   "serve a read-only html status page at /status, accessible with this token",
+			EnvVars: []string{"LOTUS_WALLET_STATUS_TOKEN"},
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		log.Info("Starting lotus wallet")
@@ -78,6 +367,19 @@ var runCmd = &cli.Command{
 		ctx, cancel := context.WithCancel(ctx)
 		defer cancel()
 
+		conf, err := newConfigReloader(cctx)
+		if err != nil {
+			return err
+		}
+
+		apiVersion := build.FullAPIVersion
+		if cctx.IsSet("api-version") {
+			apiVersion, err = parseAPIVersion(cctx.String("api-version"))
+			if err != nil {
+				return err
+			}
+		}
+
 		// Register all metric views
 		if err := view.Register(
 			metrics.DefaultViews...,
@@ -85,65 +387,462 @@ var runCmd = &cli.Command{
 			log.Fatalf("Cannot register the view: %v", err)
 		}
 
-		repoPath := cctx.String(FlagWalletRepo)
-		r, err := repo.NewFS(repoPath)
+		var je *jaeger.Exporter
+		if agent := cctx.String("jaeger-agent"); agent != "" {
+			je = tracing.SetupJaegerTracingAgent("lotus-wallet", agent)
+		} else {
+			je = tracing.SetupJaegerTracing("lotus-wallet")
+		}
+		if je != nil {
+			defer je.Flush()
+		}
+
+		var r repo.Repo
+		if cctx.Bool("in-memory") {
+			if cctx.IsSet("vault-addr") {
+				return xerrors.Errorf("--in-memory can't be combined with --vault-addr")
+			}
+			log.Warn("running with an in-memory repo, keys and metadata are lost on exit")
+			r = repo.NewMemory(nil)
+		} else {
+			fsr, err := repo.NewFS(cctx.String(FlagWalletRepo))
+			if err != nil {
+				return err
+			}
+
+			ok, err := fsr.Exists()
+			if err != nil {
+				return err
+			}
+			if !ok {
+				if err := fsr.Init(repo.Worker); err != nil {
+					return err
+				}
+			}
+			r = fsr
+		}
+
+		lr, err := r.Lock(repo.Wallet)
 		if err != nil {
 			return err
 		}
+		// also removes the temporary directory of in-memory repos
+		defer lr.Close() //nolint:errcheck
 
-		ok, err := r.Exists()
+		ks, err := lr.KeyStore()
 		if err != nil {
 			return err
 		}
-		if !ok {
-			if err := r.Init(repo.Worker); err != nil {
+
+		var wks types.KeyStore = ks
+		if cctx.IsSet("vault-addr") {
+			wks, err = vault.NewKeyStore(vault.Config{
+				Address: cctx.String("vault-addr"),
+				Token:   cctx.String("vault-token"),
+				Mount:   cctx.String("vault-mount"),
+				Path:    cctx.String("vault-path"),
+			})
+			if err != nil {
+				return xerrors.Errorf("opening vault key store: %w", err)
+			}
+		}
+
+		lw, err := wallet.NewWallet(wks)
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("in-memory") {
+			if err := seedInMemoryKeys(ctx, lw); err != nil {
 				return err
 			}
 		}
 
-		lr, err := r.Lock(repo.Wallet)
+		ds, err := lr.Datastore("/metadata")
 		if err != nil {
 			return err
 		}
 
-		ks, err := lr.KeyStore()
+		backup, err := wallet.MigrateRepo(ds, filepath.Join(lr.Path(), repoBackupDir))
 		if err != nil {
+			return xerrors.Errorf("migrating repo: %w", err)
+		}
+		if backup != "" {
+			log.Infow("migrated wallet repo", "version", wallet.RepoVersion, "backup", backup)
+		}
+
+		// also used by backends added at runtime with WalletBackendAdd
+		if err := (cliutil.TLSClientConfig{
+			Cert: cctx.String("remote-tls-cert"),
+			Key:  cctx.String("remote-tls-key"),
+			CA:   cctx.String("remote-tls-ca"),
+		}).Apply(); err != nil {
 			return err
 		}
 
-		lw, err := wallet.NewWallet(ks)
+		lazy := map[string]bool{}
+		for _, b := range cctx.StringSlice("lazy-backends") {
+			if b != "remote" && b != "ledger" {
+				return xerrors.Errorf("unknown lazy backend %q, expected remote or ledger", b)
+			}
+			lazy[b] = true
+		}
+
+		backends := []namedBackend{{name: "local", w: lw}}
+		closers := map[string]func(){}
+		if cctx.Int("backend-failure-threshold") < 1 {
+			return xerrors.Errorf("--backend-failure-threshold must be at least 1")
+		}
+		if cctx.Duration("backend-cooldown") <= 0 {
+			return xerrors.Errorf("--backend-cooldown must be positive")
+		}
+		breakers := wallet.NewBreakers(wallet.BreakerConfig{
+			Threshold: cctx.Int("backend-failure-threshold"),
+			Cooldown:  cctx.Duration("backend-cooldown"),
+		})
+		index, err := wallet.NewBackendIndex(ds)
 		if err != nil {
 			return err
 		}
+		routing, err := wallet.NewRouter(ds)
+		if err != nil {
+			return err
+		}
+		mw := wallet.MultiWallet{Local: lw, Breakers: breakers, Index: index, Routing: routing}
+		if cctx.Bool("ledger") || cctx.IsSet("ledger-speculos") {
+			mw.Ledger = ledgerwallet.NewWallet(ds)
+			if addr := cctx.String("ledger-speculos"); addr != "" {
+				log.Warnw("using the speculos ledger emulator, keys are not secure", "address", addr)
+				mw.Ledger = ledgerwallet.NewSpeculosWallet(ds, addr)
+			}
 
-		var w api.WalletAPI = lw
-		if cctx.Bool("ledger") {
-			ds, err := lr.Datastore("/metadata")
+			if !lazy["ledger"] {
+				if err := mw.Ledger.CheckDevice(); err != nil {
+					return err
+				}
+			}
+
+			backends = append(backends, namedBackend{name: "ledger", w: mw.Ledger, endpoint: cctx.String("ledger-speculos")})
+		}
+		if infos := cctx.StringSlice("remote"); len(infos) > 0 {
+			var rw *remotewallet.RemoteWallet
+			var closer jsonrpc.ClientCloser
+			var endpoints []string
+			for _, info := range infos {
+				endpoints = append(endpoints, cliutil.ParseApiInfo(info).Addr)
+			}
+
+			info := infos[0]
+			if len(infos) > 1 {
+				// upstreams being down at startup shouldn't keep the wallet
+				// from failing over to the others
+				rw, closer = remotewallet.NewFailoverRemoteWallet(infos, cctx.StringSlice("remote-verify")...)
+				log.Infow("failing over between remote wallets", "remotes", endpoints)
+			} else if lazy["remote"] {
+				rw, closer = remotewallet.NewLazyRemoteWallet(info, cctx.StringSlice("remote-verify")...)
+			} else {
+				rw, closer, err = remotewallet.NewRemoteWallet(ctx, info, cctx.StringSlice("remote-verify")...)
+				if err != nil {
+					return xerrors.Errorf("connecting to remote wallet: %w", err)
+				}
+			}
+			closers["remote"] = closer
+
+			mw.Remote = rw
+			backends = append(backends, namedBackend{name: "remote", w: rw, endpoint: strings.Join(endpoints, ",")})
+		}
+		if rf := cctx.String("routing-file"); rf != "" {
+			cfg, err := wallet.LoadRoutingFile(rf)
 			if err != nil {
 				return err
 			}
+			if err := routing.Set(*cfg, routableBackends); err != nil {
+				return xerrors.Errorf("applying %s: %w", rf, err)
+			}
+			log.Infow("loaded backend routing", "file", rf)
+		}
 
-			w = wallet.MultiWallet{
-				Local:  lw,
-				Ledger: ledgerwallet.NewWallet(ds),
+		// requests go through the MultiWallet even with only the local
+		// backend, so that signing metrics are recorded per backend
+		set := newBackendSet(ds, mw, backends, closers)
+		defer set.close()
+		var w api.WalletAPI = set
+
+		if shards := cctx.StringSlice("shard"); len(shards) > 0 {
+			if cctx.Bool("ledger") || cctx.IsSet("ledger-speculos") || cctx.IsSet("remote") {
+				return xerrors.Errorf("--shard can't be combined with --ledger or --remote")
+			}
+			if cctx.IsSet("routing-file") {
+				return xerrors.Errorf("--shard can't be combined with --routing-file, shards are chosen by address")
+			}
+			routing = nil
+
+			var ss []wallet.Shard
+			backends = nil
+			for _, s := range shards {
+				parts := strings.SplitN(s, "=", 2)
+				if len(parts) != 2 || parts[0] == "" {
+					return xerrors.Errorf("invalid shard %q, expected name=token:multiaddr", s)
+				}
+
+				// a shard being down at startup shouldn't keep the coordinator
+				// from serving keys of the other shards
+				rw, closer := remotewallet.NewLazyRemoteWallet(parts[1])
+				defer closer()
+
+				ss = append(ss, wallet.Shard{Name: parts[0], WalletAPI: rw})
+				backends = append(backends, namedBackend{name: parts[0], w: rw, endpoint: cliutil.ParseApiInfo(parts[1]).Addr})
+			}
+
+			sw, err := wallet.NewShardedWallet(ss, breakers, index)
+			if err != nil {
+				return err
+			}
+			w = sw
+			set = newFixedBackendSet(backends)
+
+			log.Infow("running as signing coordinator, local keys are not used", "shards", len(ss))
+		}
+
+		for _, s := range cctx.StringSlice("preload") {
+			addr, err := address.NewFromString(s)
+			if err != nil {
+				return xerrors.Errorf("parsing preload address %q: %w", s, err)
+			}
+
+			have, err := w.WalletHas(ctx, addr)
+			if err != nil {
+				return xerrors.Errorf("checking preload address %s: %w", addr, err)
+			}
+			if !have {
+				return xerrors.Errorf("preload address %s not found in any wallet backend", addr)
 			}
 		}
 
 		address := cctx.String("listen")
+		monMux := mux.NewRouter()
 		mux := mux.NewRouter()
 
 		log.Info("Setting up API endpoint at " + address)
 
+		ik, err := wallet.LoadInstanceKey(ks)
+		if err != nil {
+			return err
+		}
+
+		// Generates the secret and writes an admin token to the repo on first run
+		secret, err := modules.APISecret(ks, lr)
+		if err != nil {
+			return xerrors.Errorf("getting api secret: %w", err)
+		}
+
+		var signer api.WalletAPI = &LoggedWallet{under: w}
+		{
+			weights, err := parseCallerWeights(cctx.StringSlice("caller-weight"))
+			if err != nil {
+				return err
+			}
+			slo := map[string]time.Duration{
+				wallet.PriorityCritical: cctx.Duration("critical-sign-slo"),
+				wallet.PriorityBulk:     cctx.Duration("bulk-sign-slo"),
+			}
+			signer = newFairScheduler(signer, cctx.Int("sign-concurrency"), cctx.Int("sign-queue-limit"), weights, slo)
+		}
+
+		summaries := wallet.NewMsgSummaries(ds)
+		if maxAge, maxEntries := cctx.Duration("summary-max-age"), cctx.Int("summary-max-entries"); maxAge != 0 || maxEntries != 0 {
+			go summaries.RunRetention(ctx, time.Hour, maxAge, maxEntries)
+		}
+
+		var approval *wallet.ApprovalWallet
+		if cctx.Bool("manual-approval") || cctx.IsSet("approval-threshold") {
+			acfg := wallet.ApprovalConfig{
+				All:       cctx.Bool("manual-approval"),
+				Approvers: cctx.StringSlice("approver"),
+				Required:  cctx.Int("approvals-required"),
+			}
+			if specs := cctx.StringSlice("approval-webhook"); len(specs) > 0 {
+				var hooks []wallet.Webhook
+				for _, s := range specs {
+					wh, err := wallet.ParseWebhook(s)
+					if err != nil {
+						return err
+					}
+					hooks = append(hooks, wh)
+				}
+				acfg.OnPending = wallet.NewWebhookNotifier(hooks).NotifyPending
+			}
+			if cctx.IsSet("approval-threshold") {
+				f, err := types.ParseFIL(cctx.String("approval-threshold"))
+				if err != nil {
+					return xerrors.Errorf("parsing approval-threshold: %w", err)
+				}
+				t := types.BigInt(f)
+				acfg.Threshold = &t
+			}
+			if len(acfg.Approvers) > 0 && acfg.Required > len(acfg.Approvers) {
+				return xerrors.Errorf("approvals-required (%d) exceeds the number of approvers (%d)", acfg.Required, len(acfg.Approvers))
+			}
+			if len(acfg.Approvers) == 0 && acfg.Required > 1 {
+				// decisions of admin tokens are all recorded as the same
+				// operator, so a second approval could never be given
+				return xerrors.Errorf("approvals-required (%d) needs named approvers, see --approver", acfg.Required)
+			}
+
+			log.Warnw("sign request approvals enabled", "all", acfg.All, "threshold", cctx.String("approval-threshold"), "required", acfg.Required, "approvers", acfg.Approvers)
+			approval = wallet.NewApprovalWallet(signer, ds, summaries, acfg)
+			signer = approval
+		}
+
+		policy := wallet.NewPolicyWallet(signer, ds)
+		if pf := cctx.String("policy-file"); pf != "" {
+			conf.filePolicies, err = applyPolicyFile(ctx, pf, policy.WalletPolicySet)
+			if err != nil {
+				return err
+			}
+		}
+
+		journal := wallet.NewSignatureJournal(policy, ds)
+		if maxAge, maxEntries := cctx.Duration("journal-max-age"), cctx.Int("journal-max-entries"); maxAge != 0 || maxEntries != 0 {
+			go journal.RunRetention(ctx, time.Hour, maxAge, maxEntries)
+		}
+		audit := wallet.NewAuditWallet(journal, ds, summaries)
+		if maxAge, maxEntries := cctx.Duration("audit-max-age"), cctx.Int("audit-max-entries"); maxAge != 0 || maxEntries != 0 {
+			go audit.RunRetention(ctx, time.Hour, maxAge, maxEntries)
+		}
+		seclog := wallet.NewSecurityLog(audit, ds)
+		watch, err := wallet.NewAddressWatch(seclog, ds, seclog, index)
+		if err != nil {
+			return err
+		}
+		if iv := cctx.Duration("address-watch-interval"); iv > 0 {
+			go watch.Run(ctx, iv)
+		}
+		wa := &walletAuth{secret: (*jwt.HMACSHA)(secret), ds: ds, seclog: seclog}
+		if err := wa.ensureRepoToken(ctx, r, lr); err != nil {
+			return err
+		}
+		failures := new(recentFailures)
+		wd := &walletDaemon{
+			WalletAPI:     &failureRecorder{WalletAPI: watch, failures: failures},
+			walletAuth:    wa,
+			BLSAggregator: wallet.NewBLSAggregator(ds),
+			HDWallet:      wallet.NewHDWallet(watch, ds, ik),
+			instanceKey:   ik,
+			journal:       journal,
+			audit:         audit,
+			summaries:     summaries,
+			seclog:        seclog,
+			policy:        policy,
+			approval:      approval,
+			backends:      set,
+			breakers:      breakers,
+			index:         index,
+			routing:       routing,
+			started:       time.Now(),
+			clients:       &wsClients{},
+			apiVersion:    apiVersion,
+			session:       uuid.New(),
+			owned:         ownershipProofs{ledger: cctx.Bool("ownership-proofs-ledger")},
+			custody: custodyConfig{
+				keystore:       "repo",
+				tls:            cctx.IsSet("tls-cert"),
+				tlsClientAuth:  cctx.IsSet("tls-client-ca"),
+				authRequired:   !cctx.Bool("disable-auth"),
+				requestSigning: cctx.IsSet("request-signing-key"),
+			},
+		}
+		if cctx.IsSet("vault-addr") {
+			wd.custody.keystore = "vault"
+		}
+		go conf.run(ctx, wd.WalletPolicySet)
+		if cctx.Bool("enable-service-keys") {
+			log.Warn("service keys are enabled")
+			wd.services = wallet.NewServiceKeys(wks)
+		}
+
 		rpcServer := jsonrpc.NewServer()
-		rpcServer.Register("Filecoin", &LoggedWallet{under: metrics.MetricedWalletAPI(w)})
+		rpcServer.Register("Filecoin", apistruct.PermissionedWalletDaemonAPI(metrics.MetricedWalletDaemonAPI(wd)))
 
-		mux.Handle("/rpc/v0", rpcServer)
-		mux.PathPrefix("/").Handler(http.DefaultServeMux) // pprof
+		var rpcHandler http.Handler = rpcServer
+		if key := cctx.String("request-signing-key"); key != "" {
+			rpcHandler = &reqsign.Verifier{
+				Key:  []byte(key),
+				Skew: cctx.Duration("request-skew"),
+				Next: rpcHandler.ServeHTTP,
+			}
+		}
+		if cctx.Bool("disable-auth") {
+			log.Warn("API authentication is disabled")
 
-		/*ah := &auth.Handler{
-			Verify: nodeApi.AuthVerify,
-			Next:   mux.ServeHTTP,
-		}*/
+			// calls without a token are treated as coming from an admin
+			next := rpcHandler
+			rpcHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				next.ServeHTTP(w, r.WithContext(auth.WithPerm(r.Context(), apistruct.AllPermissions)))
+			})
+		} else {
+			rpcHandler = &auth.Handler{
+				Verify: wd.AuthVerify,
+				Next:   rpcHandler.ServeHTTP,
+			}
+		}
+		mux.Handle("/rpc/v0", wd.clients.count(withCaller(wd.walletAuth.withTokenClaims(rpcHandler))))
+		health := &healthChecks{ks: wks, backends: set, breakers: breakers}
+		monMux.HandleFunc("/healthz", health.healthz)
+		monMux.HandleFunc("/readyz", health.readyz)
+		if tok := cctx.String("status-token"); tok != "" {
+			monMux.Handle("/status", &statusPage{
+				token:    tok,
+				started:  wd.started,
+				backends: set,
+				daemon:   wd,
+				failures: failures,
+			})
+		}
+
+		// Prometheus globals are exposed as interfaces, but the prometheus
+		// OpenCensus exporter expects a concrete *Registry
+		registry, ok := promclient.DefaultRegisterer.(*promclient.Registry)
+		if !ok {
+			log.Warnf("failed to export default prometheus registry; some metrics will be unavailable; unexpected type: %T", promclient.DefaultRegisterer)
+		}
+		exporter, err := prometheus.NewExporter(prometheus.Options{
+			Registry:  registry,
+			Namespace: "lotus_wallet",
+		})
+		if err != nil {
+			return xerrors.Errorf("creating prometheus exporter: %w", err)
+		}
+		monMux.Handle("/metrics", exporter)
+
+		monMux.PathPrefix("/").Handler(http.DefaultServeMux) // pprof
+
+		// Monitoring endpoints are served next to the signing api, unless
+		// a separate listener is configured for them
+		if maddr := cctx.String("monitoring-listen"); maddr != "" {
+			log.Info("Setting up monitoring endpoint at " + maddr)
+
+			monSrv := &http.Server{Handler: monMux}
+			go func() {
+				<-ctx.Done()
+				if err := monSrv.Shutdown(context.TODO()); err != nil {
+					log.Errorf("shutting down monitoring server failed: %s", err)
+				}
+			}()
+
+			mnl, err := net.Listen("tcp", maddr)
+			if err != nil {
+				return xerrors.Errorf("monitoring listener: %w", err)
+			}
+
+			go func() {
+				if err := monSrv.Serve(mnl); err != nil && err != http.ErrServerClosed {
+					log.Errorf("monitoring server failed: %s", err)
+				}
+			}()
+		} else {
+			mux.PathPrefix("/").Handler(monMux)
+		}
 
 		srv := &http.Server{
 			Handler: mux,
@@ -167,6 +866,60 @@ var runCmd = &cli.Command{
 			return err
 		}
 
+		if cctx.IsSet("tls-cert") || cctx.IsSet("tls-key") {
+			if !cctx.IsSet("tls-cert") || !cctx.IsSet("tls-key") {
+				return xerrors.Errorf("--tls-cert and --tls-key must be set together")
+			}
+
+			cr, err := newCertReloader(ctx, cctx.String("tls-cert"), cctx.String("tls-key"))
+			if err != nil {
+				return err
+			}
+
+			tcfg := cr.config()
+			if ca := cctx.String("tls-client-ca"); ca != "" {
+				if err := requireClientCerts(tcfg, ca, cctx.StringSlice("tls-client-fingerprint")); err != nil {
+					return err
+				}
+			} else if cctx.IsSet("tls-client-fingerprint") {
+				return xerrors.Errorf("--tls-client-fingerprint requires --tls-client-ca")
+			}
+
+			nl = tls.NewListener(nl, tcfg)
+		} else if cctx.IsSet("tls-client-ca") {
+			return xerrors.Errorf("--tls-client-ca requires --tls-cert and --tls-key")
+		}
+
+		{
+			a, err := net.ResolveTCPAddr("tcp", address)
+			if err != nil {
+				return xerrors.Errorf("parsing address: %w", err)
+			}
+
+			ma, err := manet.FromNetAddr(a)
+			if err != nil {
+				return xerrors.Errorf("creating api multiaddress: %w", err)
+			}
+			if cctx.IsSet("tls-cert") {
+				// let clients know to dial with wss
+				ma = ma.Encapsulate(multiaddr.StringCast("/wss"))
+			}
+
+			if err := lr.SetAPIEndpoint(ma); err != nil {
+				return xerrors.Errorf("setting api endpoint: %w", err)
+			}
+
+			if cctx.Bool("in-memory") {
+				// there is no repo for clients to read the endpoint and token
+				// from, hand them to whoever started the wallet
+				tok, err := r.APIToken()
+				if err != nil {
+					return xerrors.Errorf("getting api token: %w", err)
+				}
+				fmt.Printf("WALLET_API_INFO=%s:%s\n", tok, ma)
+			}
+		}
+
 		return srv.Serve(nl)
 	},
 }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/wallet"
+)
+
+// envInMemoryKeys holds whitespace separated hex-lotus encoded keys (the
+// 'lotus wallet export' format) imported into in-memory wallets at startup
+const envInMemoryKeys = "LOTUS_WALLET_KEYS"
+
+func seedInMemoryKeys(ctx context.Context, lw *wallet.LocalWallet) error {
+	for i, s := range strings.Fields(os.Getenv(envInMemoryKeys)) {
+		data, err := hex.DecodeString(s)
+		if err != nil {
+			return xerrors.Errorf("decoding key %d of $%s: %w", i, envInMemoryKeys, err)
+		}
+
+		var ki types.KeyInfo
+		if err := json.Unmarshal(data, &ki); err != nil {
+			return xerrors.Errorf("unmarshaling key %d of $%s: %w", i, envInMemoryKeys, err)
+		}
+
+		a, err := lw.WalletImport(ctx, &ki)
+		if err != nil {
+			return xerrors.Errorf("importing key %d of $%s: %w", i, envInMemoryKeys, err)
+		}
+		log.Infow("seeded in-memory wallet key", "address", a)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/wallet"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var mnemonicFlags = []cli.Flag{
+	&cli.IntFlag{
+		Name:  "count",
+		Usage: "number of keys to derive and import",
+		Value: 1,
+	},
+	&cli.IntFlag{
+		Name:  "start",
+		Usage: "index of the first key to derive",
+	},
+	&cli.BoolFlag{
+		Name:  "passphrase",
+		Usage: "prompt for a BIP39 passphrase protecting the mnemonic",
+	},
+}
+
+var walletNewMnemonic = &cli.Command{
+	Name:  "new-mnemonic",
+	Usage: "Generate a BIP39 mnemonic and import secp256k1 keys derived from it",
+	Description: `Keys are derived at m/44'/461'/0'/0/<index>. Write the mnemonic down and keep
+   it safe, anyone who knows it can recreate the keys with restore-mnemonic.`,
+	Flags: append([]cli.Flag{
+		&cli.IntFlag{
+			Name:  "words",
+			Usage: "number of mnemonic words, 12 or 24",
+			Value: 24,
+		},
+	}, mnemonicFlags...),
+	Action: func(cctx *cli.Context) error {
+		mnemonic, err := wallet.NewMnemonic(cctx.Int("words"))
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(mnemonic)
+		_, _ = fmt.Fprintln(os.Stderr, "\nWrite down the mnemonic above, it is the only backup of the imported keys")
+
+		return importMnemonicKeys(cctx, mnemonic)
+	},
+}
+
+var walletRestoreMnemonic = &cli.Command{
+	Name:        "restore-mnemonic",
+	Usage:       "Import secp256k1 keys derived from a BIP39 mnemonic read from stdin",
+	Description: `Keys are derived at m/44'/461'/0'/0/<index>.`,
+	Flags:       mnemonicFlags,
+	Action: func(cctx *cli.Context) error {
+		mnemonic, err := readLine("Enter mnemonic: ")
+		if err != nil {
+			return err
+		}
+
+		return importMnemonicKeys(cctx, mnemonic)
+	},
+}
+
+func readLine(prompt string) (string, error) {
+	_, _ = fmt.Fprint(os.Stderr, prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func importMnemonicKeys(cctx *cli.Context, mnemonic string) error {
+	if cctx.Int("count") < 1 || cctx.Int("start") < 0 {
+		return xerrors.Errorf("count must be positive and start can't be negative")
+	}
+
+	var passphrase string
+	if cctx.Bool("passphrase") {
+		var err error
+		if passphrase, err = readLine("Enter passphrase: "); err != nil {
+			return err
+		}
+	}
+
+	api, closer, err := lcli.GetWalletAPI(cctx)
+	if err != nil {
+		return err
+	}
+	defer closer()
+	ctx := lcli.ReqContext(cctx)
+
+	for i := 0; i < cctx.Int("count"); i++ {
+		path := wallet.MnemonicPath(uint32(cctx.Int("start") + i))
+
+		k, err := wallet.KeyFromMnemonic(mnemonic, passphrase, path)
+		if err != nil {
+			return err
+		}
+
+		addr, err := api.WalletImport(ctx, &k.KeyInfo)
+		if err != nil {
+			return xerrors.Errorf("importing key %s: %w", wallet.FormatPath(path), err)
+		}
+
+		fmt.Printf("%s\t%s\n", wallet.FormatPath(path), addr)
+	}
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/wallet"
+)
+
+// how long ownership proofs are reused before new ones are produced, how
+// long failures to produce one are remembered, and how long producing one
+// may take
+const (
+	ownershipProofMaxAge  = time.Hour
+	ownershipProofRetry   = time.Minute
+	ownershipProofTimeout = time.Minute
+)
+
+// ownershipProofs caches ownership proofs, so that listing doesn't sign with
+// every key on every call. Proofs are produced in the background, listing
+// only returns the cached ones.
+type ownershipProofs struct {
+	// also sign proofs with ledger keys, which needs every proof confirmed on
+	// the device
+	ledger bool
+
+	lk         sync.Mutex
+	proofs     map[address.Address]api.OwnedAddress
+	refreshing bool
+}
+
+func (d *walletDaemon) WalletListOwned(ctx context.Context) ([]api.OwnedAddress, error) {
+	if hasScope(ctx, api.TokenScopeHasOnly) {
+		return nil, xerrors.Errorf("token is restricted to checking for specific addresses")
+	}
+
+	held, err := d.listBackends(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out, stale := d.owned.cached(held)
+	if len(stale) > 0 {
+		go d.owned.refresh(stale)
+	}
+	return out, nil
+}
+
+// cached returns the cached proofs of the addresses, and the addresses which
+// need a new proof
+func (o *ownershipProofs) cached(held []heldAddress) ([]api.OwnedAddress, []heldAddress) {
+	o.lk.Lock()
+	defer o.lk.Unlock()
+
+	out := make([]api.OwnedAddress, 0, len(held))
+	var stale []heldAddress
+	for _, h := range held {
+		if h.backend.name == "ledger" && !o.ledger {
+			out = append(out, api.OwnedAddress{
+				Address:    h.addr,
+				Backend:    h.backend.name,
+				ProofError: "ownership proofs of ledger keys are disabled (see run --ownership-proofs-ledger)",
+			})
+			continue
+		}
+
+		oa, ok := o.proofs[h.addr]
+		if !ok || oa.Backend != h.backend.name {
+			out = append(out, api.OwnedAddress{
+				Address:    h.addr,
+				Backend:    h.backend.name,
+				ProofError: "ownership proof is being produced, list again shortly",
+			})
+			stale = append(stale, h)
+			continue
+		}
+
+		maxAge := ownershipProofMaxAge
+		if oa.Proof == nil {
+			maxAge = ownershipProofRetry
+		}
+		if time.Since(oa.ProofTime) > maxAge {
+			stale = append(stale, h)
+		}
+		out = append(out, oa)
+	}
+
+	return out, stale
+}
+
+// refresh produces new proofs for the addresses, unless a refresh is already
+// running
+func (o *ownershipProofs) refresh(held []heldAddress) {
+	o.lk.Lock()
+	if o.refreshing {
+		o.lk.Unlock()
+		return
+	}
+	o.refreshing = true
+	o.lk.Unlock()
+
+	defer func() {
+		o.lk.Lock()
+		o.refreshing = false
+		o.lk.Unlock()
+	}()
+
+	for _, h := range held {
+		ctx, cancel := context.WithTimeout(context.Background(), ownershipProofTimeout)
+		oa := proveOwnership(ctx, h.backend, h.addr)
+		cancel()
+
+		o.lk.Lock()
+		if o.proofs == nil {
+			o.proofs = map[address.Address]api.OwnedAddress{}
+		}
+		o.proofs[h.addr] = oa
+		o.lk.Unlock()
+	}
+}
+
+// proveOwnership signs the ownership proof directly with the backend. The
+// proof bytes can't be mistaken for anything else, so signing policies and
+// approvals don't apply.
+func proveOwnership(ctx context.Context, b namedBackend, addr address.Address) api.OwnedAddress {
+	oa := api.OwnedAddress{
+		Address:   addr,
+		Backend:   b.name,
+		ProofTime: time.Now().Truncate(time.Second),
+	}
+
+	sig, err := b.w.WalletSign(ctx, addr, wallet.OwnershipProofBytes(addr, oa.ProofTime), api.MsgMeta{Type: api.MTUnknown})
+	if err != nil {
+		log.Warnw("producing ownership proof", "address", addr, "backend", b.name, "error", err)
+		oa.ProofError = err.Error()
+		return oa
+	}
+
+	oa.Proof = sig
+	return oa
+}
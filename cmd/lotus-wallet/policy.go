@@ -0,0 +1,425 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/wallet"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var policyCmd = &cli.Command{
+	Name:  "policy",
+	Usage: "Manage per-address signing policies of a running lotus wallet",
+	Subcommands: []*cli.Command{
+		policyListCmd,
+		policyGetCmd,
+		policySetCmd,
+		policyRemoveCmd,
+		policySpendCmd,
+		policyResetSpendCmd,
+		policySimulateCmd,
+	},
+}
+
+var policyListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "List addresses with a signing policy",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		pols, err := api.WalletPolicyList(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, ap := range pols {
+			fmt.Printf("%s\t%s\n", ap.Address, formatPolicy(&ap.Policy))
+		}
+		return nil
+	},
+}
+
+var policyGetCmd = &cli.Command{
+	Name:      "get",
+	Usage:     "Show the signing policy of an address",
+	ArgsUsage: "<address>",
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return xerrors.Errorf("must specify an address")
+		}
+
+		addr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		pol, err := api.WalletPolicyGet(ctx, addr)
+		if err != nil {
+			return err
+		}
+		if pol == nil {
+			fmt.Println("no policy, address is unrestricted")
+			return nil
+		}
+
+		fmt.Println(formatPolicy(pol))
+		return nil
+	},
+}
+
+var policySetCmd = &cli.Command{
+	Name:      "set",
+	Usage:     "Set the signing policy of an address, replacing the existing one",
+	ArgsUsage: "<address>",
+	Flags:     append([]cli.Flag{typoCheckFlag}, policyFlags...),
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return xerrors.Errorf("must specify an address")
+		}
+
+		addr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		pol, err := policyFromFlags(cctx)
+		if err != nil {
+			return err
+		}
+
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		if err := checkAddressTypos(ctx, cctx, api, append([]address.Address{addr}, pol.AllowedTo...)...); err != nil {
+			return err
+		}
+
+		return api.WalletPolicySet(ctx, addr, pol)
+	},
+}
+
+var policyFlags = []cli.Flag{
+	&cli.StringSliceFlag{
+		Name:  "type",
+		Usage: "allowed sign request type (message, block, dealproposal, ...), can be repeated",
+	},
+	&cli.StringSliceFlag{
+		Name:  "to",
+		Usage: "allowed message recipient, can be repeated",
+	},
+	&cli.StringFlag{
+		Name:  "max-value",
+		Usage: "maximum value of a single message, e.g. '10 FIL'",
+	},
+	&cli.StringFlag{
+		Name:  "max-fee-cap",
+		Usage: "maximum gas fee cap of a single message, e.g. '100000 attoFIL'",
+	},
+	&cli.StringFlag{
+		Name:  "daily-limit",
+		Usage: "maximum total value of messages signed in a rolling 24h window, e.g. '100 FIL'",
+	},
+	&cli.IntFlag{
+		Name:  "deadline-messages",
+		Usage: "maximum number of messages signed per WindowPoSt deadline",
+	},
+	&cli.Int64Flag{
+		Name:  "deadline-gas",
+		Usage: "maximum total gas limit of messages signed per WindowPoSt deadline",
+	},
+	&cli.BoolFlag{
+		Name:  "deadline-alert-only",
+		Usage: "only log and count messages exceeding the deadline budget instead of denying them",
+	},
+}
+
+func policyFromFlags(cctx *cli.Context) (*api.SigningPolicy, error) {
+	var pol api.SigningPolicy
+	for _, t := range cctx.StringSlice("type") {
+		pol.AllowedTypes = append(pol.AllowedTypes, api.MsgType(t))
+	}
+	for _, s := range cctx.StringSlice("to") {
+		to, err := address.NewFromString(s)
+		if err != nil {
+			return nil, xerrors.Errorf("parsing recipient %q: %w", s, err)
+		}
+		pol.AllowedTo = append(pol.AllowedTo, to)
+	}
+	if cctx.IsSet("max-value") {
+		f, err := types.ParseFIL(cctx.String("max-value"))
+		if err != nil {
+			return nil, xerrors.Errorf("parsing max-value: %w", err)
+		}
+		v := types.BigInt(f)
+		pol.MaxValue = &v
+	}
+	if cctx.IsSet("max-fee-cap") {
+		f, err := types.ParseFIL(cctx.String("max-fee-cap"))
+		if err != nil {
+			return nil, xerrors.Errorf("parsing max-fee-cap: %w", err)
+		}
+		v := types.BigInt(f)
+		pol.MaxGasFeeCap = &v
+	}
+	if cctx.IsSet("daily-limit") {
+		f, err := types.ParseFIL(cctx.String("daily-limit"))
+		if err != nil {
+			return nil, xerrors.Errorf("parsing daily-limit: %w", err)
+		}
+		v := types.BigInt(f)
+		pol.DailyLimit = &v
+	}
+	if cctx.Int("deadline-messages") < 0 || cctx.Int64("deadline-gas") < 0 {
+		return nil, xerrors.Errorf("deadline budgets can't be negative")
+	}
+	if cctx.IsSet("deadline-messages") || cctx.IsSet("deadline-gas") {
+		pol.DeadlineBudget = &api.DeadlineBudget{
+			Messages:  cctx.Int("deadline-messages"),
+			Gas:       cctx.Int64("deadline-gas"),
+			AlertOnly: cctx.Bool("deadline-alert-only"),
+		}
+	}
+
+	return &pol, nil
+}
+
+var policyRemoveCmd = &cli.Command{
+	Name:      "remove",
+	Usage:     "Remove the signing policy of an address, leaving it unrestricted",
+	ArgsUsage: "<address>",
+	Flags:     []cli.Flag{typoCheckFlag},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return xerrors.Errorf("must specify an address")
+		}
+
+		addr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		if err := checkAddressTypos(ctx, cctx, api, addr); err != nil {
+			return err
+		}
+
+		return api.WalletPolicySet(ctx, addr, nil)
+	},
+}
+
+var policySpendCmd = &cli.Command{
+	Name:      "spend",
+	Usage:     "Show the value signed by an address in the rolling daily limit window",
+	ArgsUsage: "<address>",
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return xerrors.Errorf("must specify an address")
+		}
+
+		addr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		st, err := api.WalletSpendGet(ctx, addr)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Spent in last %s: %s (%d messages)\n", st.Window, types.FIL(st.Spent), st.Count)
+		if st.Limit != nil {
+			fmt.Printf("Limit: %s\n", types.FIL(*st.Limit))
+		} else {
+			fmt.Println("Limit: none")
+		}
+		return nil
+	},
+}
+
+var policyResetSpendCmd = &cli.Command{
+	Name:      "reset-spend",
+	Usage:     "Clear the spend counter of an address",
+	ArgsUsage: "<address>",
+	Flags:     []cli.Flag{typoCheckFlag},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return xerrors.Errorf("must specify an address")
+		}
+
+		addr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		if err := checkAddressTypos(ctx, cctx, api, addr); err != nil {
+			return err
+		}
+
+		return api.WalletSpendReset(ctx, addr)
+	},
+}
+
+var policySimulateCmd = &cli.Command{
+	Name:  "simulate",
+	Usage: "Replay recorded sign requests against proposed policies and report which would be denied",
+	Description: `Successful sign requests are read from the audit log of the running wallet and
+   checked against the policies in a policy file (the format of 'run --policy-file').
+   Addresses not in the file are treated as unrestricted, group policies are not
+   applied.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "config",
+			Usage:    "TOML file with the proposed policies",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "since",
+			Usage: "replay requests recorded after this time (RFC3339, or a duration like '30d' meaning that long ago)",
+			Value: "30d",
+		},
+		&lcli.CidBaseFlag,
+	},
+	Action: func(cctx *cli.Context) error {
+		enc, err := lcli.GetCidEncoder(cctx)
+		if err != nil {
+			return err
+		}
+
+		pols, err := wallet.LoadPolicyFile(cctx.String("config"))
+		if err != nil {
+			return err
+		}
+
+		since, err := parseAuditTime(cctx.String("since"))
+		if err != nil {
+			return xerrors.Errorf("parsing --since: %w", err)
+		}
+
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		entries, err := napi.WalletAuditQuery(ctx, api.AuditFilter{Since: since})
+		if err != nil {
+			return err
+		}
+
+		var replayed int
+		for _, e := range entries {
+			if e.Op == wallet.AuditOpSign && e.Error == "" {
+				replayed++
+			}
+		}
+
+		denied := wallet.SimulatePolicies(entries, pols)
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Time\tAddress\tType\tCID\tCaller\tReason")
+		for _, d := range denied {
+			c := "-"
+			if d.Entry.Cid != nil {
+				c = enc.Encode(*d.Entry.Cid)
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				d.Entry.Time.Format("2006-01-02 15:04:05"), d.Entry.Address, d.Entry.Type, c, d.Entry.Caller, d.Reason)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+
+		fmt.Printf("\n%d of %d sign requests since %s would be denied\n", len(denied), replayed, since.Format(time.RFC3339))
+		return nil
+	},
+}
+
+func formatPolicy(p *api.SigningPolicy) string {
+	var parts []string
+	if len(p.AllowedTypes) > 0 {
+		ts := make([]string, len(p.AllowedTypes))
+		for i, t := range p.AllowedTypes {
+			ts[i] = string(t)
+		}
+		parts = append(parts, "types="+strings.Join(ts, ","))
+	}
+	if len(p.AllowedTo) > 0 {
+		tos := make([]string, len(p.AllowedTo))
+		for i, a := range p.AllowedTo {
+			tos[i] = a.String()
+		}
+		parts = append(parts, "to="+strings.Join(tos, ","))
+	}
+	if p.MaxValue != nil {
+		parts = append(parts, "max-value="+types.FIL(*p.MaxValue).String())
+	}
+	if p.MaxGasFeeCap != nil {
+		parts = append(parts, "max-fee-cap="+p.MaxGasFeeCap.String()+" attoFIL")
+	}
+	if p.DailyLimit != nil {
+		parts = append(parts, "daily-limit="+types.FIL(*p.DailyLimit).String())
+	}
+	if b := p.DeadlineBudget; b != nil {
+		if b.Messages > 0 {
+			parts = append(parts, fmt.Sprintf("deadline-messages=%d", b.Messages))
+		}
+		if b.Gas > 0 {
+			parts = append(parts, fmt.Sprintf("deadline-gas=%d", b.Gas))
+		}
+		if b.AlertOnly {
+			parts = append(parts, "deadline-alert-only")
+		}
+	}
+	if len(parts) == 0 {
+		return "unrestricted"
+	}
+	return strings.Join(parts, " ")
+}
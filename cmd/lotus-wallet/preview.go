@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-address"
+	builtin2 "github.com/filecoin-project/specs-actors/v2/actors/builtin"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/stmgr"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/wallet"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+// singletonActors maps addresses of singleton builtin actors to their code.
+// The wallet has no chain access, so methods of other actors can't be named.
+var singletonActors = map[address.Address]cid.Cid{
+	builtin2.InitActorAddr:             builtin2.InitActorCodeID,
+	builtin2.CronActorAddr:             builtin2.CronActorCodeID,
+	builtin2.RewardActorAddr:           builtin2.RewardActorCodeID,
+	builtin2.StoragePowerActorAddr:     builtin2.StoragePowerActorCodeID,
+	builtin2.StorageMarketActorAddr:    builtin2.StorageMarketActorCodeID,
+	builtin2.VerifiedRegistryActorAddr: builtin2.VerifiedRegistryActorCodeID,
+}
+
+// maxPreviewParams is the number of raw params bytes shown when they can't be
+// decoded
+const maxPreviewParams = 64
+
+// printSignPreview writes a human readable description of a pending sign
+// request
+func printSignPreview(w io.Writer, p api.PendingSign) error {
+	tw := tabwriter.NewWriter(w, 2, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintf(tw, "Request:\t%d\n", p.ID)
+	_, _ = fmt.Fprintf(tw, "Signer:\t%s\n", p.Signer)
+	_, _ = fmt.Fprintf(tw, "Type:\t%s\n", p.Type)
+	_, _ = fmt.Fprintf(tw, "Caller:\t%s\n", p.Caller)
+	if p.Reason != "" {
+		_, _ = fmt.Fprintf(tw, "Reason:\t%s\n", p.Reason)
+	}
+	if p.Cid != nil {
+		_, _ = fmt.Fprintf(tw, "CID:\t%s\n", p.Cid)
+		if e, err := wallet.DescribeCid(*p.Cid); err == nil {
+			_, _ = fmt.Fprintf(tw, "CID (base16):\t%s\n", e.Base16)
+		}
+	}
+	if p.Summary != "" {
+		_, _ = fmt.Fprintf(tw, "Payload:\t%s\n", p.Summary)
+	}
+
+	if m := p.Message; m != nil {
+		_, _ = fmt.Fprintf(tw, "From:\t%s\n", m.From)
+		_, _ = fmt.Fprintf(tw, "To:\t%s\n", m.To)
+		_, _ = fmt.Fprintf(tw, "Value:\t%s\n", types.FIL(m.Value))
+		_, _ = fmt.Fprintf(tw, "Method:\t%s\n", methodName(m))
+		_, _ = fmt.Fprintf(tw, "Nonce:\t%d\n", m.Nonce)
+		_, _ = fmt.Fprintf(tw, "GasLimit:\t%d\n", m.GasLimit)
+		_, _ = fmt.Fprintf(tw, "GasFeeCap:\t%s\n", types.FIL(m.GasFeeCap))
+		_, _ = fmt.Fprintf(tw, "GasPremium:\t%s\n", types.FIL(m.GasPremium))
+		_, _ = fmt.Fprintf(tw, "Max fee:\t%s\n", types.FIL(types.BigMul(m.GasFeeCap, types.NewInt(uint64(m.GasLimit)))))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if m := p.Message; m != nil && len(m.Params) > 0 {
+		_, _ = fmt.Fprintf(w, "Params:\n%s\n", paramsPreview(m))
+	}
+	return nil
+}
+
+func methodName(m *types.Message) string {
+	if m.Method == builtin2.MethodSend {
+		return "0 (Send)"
+	}
+	if code, ok := singletonActors[m.To]; ok {
+		if mm, ok := stmgr.MethodsMap[code][m.Method]; ok {
+			return fmt.Sprintf("%d (%s)", m.Method, mm.Name)
+		}
+	}
+	return fmt.Sprint(m.Method)
+}
+
+func paramsPreview(m *types.Message) string {
+	if code, ok := singletonActors[m.To]; ok {
+		if s, err := lcli.JsonParams(code, m.Method, m.Params); err == nil {
+			return s
+		}
+	}
+
+	s := hex.EncodeToString(m.Params)
+	if len(m.Params) > maxPreviewParams {
+		s = hex.EncodeToString(m.Params[:maxPreviewParams]) + fmt.Sprintf("... (%d bytes)", len(m.Params))
+	}
+	return "  " + s
+}
+
+// confirm asks a yes/no question on the terminal, defaulting to no
+func confirm(prompt string) (bool, error) {
+	answer, err := readLine(prompt + " [y/N]: ")
+	if err != nil {
+		return false, err
+	}
+
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
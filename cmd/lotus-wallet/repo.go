@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/wallet"
+	"github.com/filecoin-project/lotus/node/repo"
+)
+
+// repoBackupDir is where metadata backups are written, relative to the repo
+const repoBackupDir = "backups"
+
+var repoCmd = &cli.Command{
+	Name:  "repo",
+	Usage: "Manage the wallet repo layout version and backups (the wallet must not be running)",
+	Subcommands: []*cli.Command{
+		repoVersionCmd,
+		repoBackupCmd,
+		repoRollbackCmd,
+		repoSnapshotCmd,
+	},
+}
+
+// withRepoDatastore locks the wallet repo and opens its metadata datastore
+func withRepoDatastore(cctx *cli.Context, cb func(repoPath string, ds datastore.Batching) error) error {
+	return withLockedRepo(cctx, func(lr repo.LockedRepo) error {
+		ds, err := lr.Datastore("/metadata")
+		if err != nil {
+			return err
+		}
+
+		return cb(lr.Path(), ds)
+	})
+}
+
+// withLockedRepo locks the wallet repo, so that the wallet can't run while cb
+// is using it
+func withLockedRepo(cctx *cli.Context, cb func(lr repo.LockedRepo) error) error {
+	r, err := repo.NewFS(cctx.String(FlagWalletRepo))
+	if err != nil {
+		return err
+	}
+
+	ok, err := r.Exists()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return xerrors.Errorf("repo at '%s' is not initialized", cctx.String(FlagWalletRepo))
+	}
+
+	lr, err := r.Lock(repo.Wallet)
+	if err != nil {
+		return xerrors.Errorf("locking repo (is the wallet running?): %w", err)
+	}
+	defer lr.Close() //nolint:errcheck
+
+	return cb(lr)
+}
+
+var repoVersionCmd = &cli.Command{
+	Name:  "version",
+	Usage: "Print the repo layout version",
+	Action: func(cctx *cli.Context) error {
+		return withRepoDatastore(cctx, func(_ string, ds datastore.Batching) error {
+			v, err := wallet.GetRepoVersion(ds)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("repo version: %d\n", v)
+			fmt.Printf("supported version: %d\n", wallet.RepoVersion)
+			return nil
+		})
+	},
+}
+
+var repoBackupCmd = &cli.Command{
+	Name:        "backup",
+	Usage:       "Back up the wallet metadata (journal, audit log, policies, approvals, ...)",
+	Description: `Keys are not part of the backup, back up the keystore separately.`,
+	Action: func(cctx *cli.Context) error {
+		return withRepoDatastore(cctx, func(repoPath string, ds datastore.Batching) error {
+			v, err := wallet.GetRepoVersion(ds)
+			if err != nil {
+				return err
+			}
+
+			path, err := wallet.BackupRepo(ds, filepath.Join(repoPath, repoBackupDir), v)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(path)
+			return nil
+		})
+	},
+}
+
+var repoRollbackCmd = &cli.Command{
+	Name:      "rollback",
+	Usage:     "Replace the wallet metadata with a backup",
+	ArgsUsage: "[backup file]",
+	Description: `Backups are written to the backups directory of the repo before each
+   migration, and by 'repo backup'. Rolling back to a backup taken before a
+   migration allows running the previous lotus-wallet release again.`,
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("expected 1 argument")
+		}
+
+		return withRepoDatastore(cctx, func(_ string, ds datastore.Batching) error {
+			if err := wallet.RestoreRepo(ds, cctx.Args().First()); err != nil {
+				return err
+			}
+
+			v, err := wallet.GetRepoVersion(ds)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("restored repo at version %d\n", v)
+			return nil
+		})
+	},
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var securityLogCmd = &cli.Command{
+	Name:  "security-log",
+	Usage: "Show the timeline of security relevant changes to a running lotus wallet",
+	Description: `Lists keys being added and removed, signing policy and key group changes,
+   freezes, and api tokens being issued and revoked. Sign requests are in the
+   audit log instead.`,
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "kind",
+			Usage: "only show events of these kinds (e.g. key-delete, policy-set, group-freeze, token-new)",
+		},
+		&cli.StringFlag{
+			Name:  "since",
+			Usage: "only show events recorded after this time (RFC3339, or a duration like '24h' meaning that long ago)",
+		},
+		&cli.StringFlag{
+			Name:  "until",
+			Usage: "only show events recorded before this time (RFC3339, or a duration like '1h' meaning that long ago)",
+		},
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "show at most this many of the most recent matching events (0 for no limit)",
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "print events as JSON",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		f := api.SecurityEventFilter{
+			Kinds: cctx.StringSlice("kind"),
+			Limit: cctx.Int("limit"),
+		}
+
+		var err error
+		if f.Since, err = parseAuditTime(cctx.String("since")); err != nil {
+			return xerrors.Errorf("parsing --since: %w", err)
+		}
+		if f.Until, err = parseAuditTime(cctx.String("until")); err != nil {
+			return xerrors.Errorf("parsing --until: %w", err)
+		}
+
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		events, err := napi.WalletSecurityEvents(ctx, f)
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("json") {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(events)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Time\tKind\tSubject\tCaller\tDetail")
+		for _, ev := range events {
+			subject, caller := ev.Subject, ev.Caller
+			if subject == "" {
+				subject = "-"
+			}
+			if caller == "" {
+				caller = "-"
+			}
+
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+				ev.Time.Format("2006-01-02 15:04:05"), ev.Kind, subject, caller, ev.Detail)
+		}
+		return tw.Flush()
+	},
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/wallet"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var errServiceKeysDisabled = xerrors.New("service keys are not enabled, start the daemon with --enable-service-keys")
+
+func (d *walletDaemon) WalletServiceKeyNew(ctx context.Context, name string, typ types.KeyType) (*api.ServiceKey, error) {
+	if d.services == nil {
+		return nil, errServiceKeysDisabled
+	}
+
+	sk, err := d.services.WalletServiceKeyNew(ctx, name, typ)
+	if err != nil {
+		return nil, err
+	}
+
+	d.seclog.Record(ctx, wallet.SecEvServiceKeyNew, name, string(typ))
+	return sk, nil
+}
+
+func (d *walletDaemon) WalletServiceKeyList(ctx context.Context) ([]api.ServiceKey, error) {
+	if d.services == nil {
+		return nil, errServiceKeysDisabled
+	}
+	return d.services.WalletServiceKeyList(ctx)
+}
+
+func (d *walletDaemon) WalletServiceKeySign(ctx context.Context, name string, data []byte) ([]byte, error) {
+	if d.services == nil {
+		return nil, errServiceKeysDisabled
+	}
+
+	log.Infow("signing with service key", "name", name, "caller", wallet.CallerFromContext(ctx))
+	return d.services.WalletServiceKeySign(ctx, name, data)
+}
+
+func (d *walletDaemon) WalletServiceKeyDelete(ctx context.Context, name string) error {
+	if d.services == nil {
+		return errServiceKeysDisabled
+	}
+
+	if err := d.services.WalletServiceKeyDelete(ctx, name); err != nil {
+		return err
+	}
+
+	d.seclog.Record(ctx, wallet.SecEvServiceKeyDel, name, "")
+	return nil
+}
+
+var walletServiceKey = &cli.Command{
+	Name:  "service-key",
+	Usage: "Manage non-Filecoin service keys (requires run --enable-service-keys)",
+	Description: `Service keys are ed25519 or secp256r1 (p256) keys, for example for service
+   identities. They are named instead of addressed and can't sign Filecoin
+   messages.`,
+	Subcommands: []*cli.Command{
+		walletServiceKeyNew,
+		walletServiceKeyList,
+		walletServiceKeySign,
+		walletServiceKeyDelete,
+	},
+}
+
+var walletServiceKeyNew = &cli.Command{
+	Name:      "new",
+	Usage:     "Generate a service key",
+	ArgsUsage: "<name>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "type",
+			Usage: "key type: ed25519 or p256",
+			Value: "ed25519",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return xerrors.Errorf("must specify the key name")
+		}
+
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		sk, err := napi.WalletServiceKeyNew(ctx, cctx.Args().First(), wallet.KServiceKeyPrefix+types.KeyType(cctx.String("type")))
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(hex.EncodeToString(sk.PublicKey))
+		return nil
+	},
+}
+
+var walletServiceKeyList = &cli.Command{
+	Name:  "list",
+	Usage: "List service keys",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		keys, err := napi.WalletServiceKeyList(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Name\tType\tPublic Key")
+		for _, k := range keys {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", k.Name, k.Type, hex.EncodeToString(k.PublicKey))
+		}
+		return tw.Flush()
+	},
+}
+
+var walletServiceKeySign = &cli.Command{
+	Name:      "sign",
+	Usage:     "Sign data with a service key, printing the hex encoded signature",
+	ArgsUsage: "<name> [<file> (optional, will read from stdin if omitted)]",
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() < 1 || cctx.NArg() > 2 {
+			return xerrors.Errorf("must specify the key name and optionally a file")
+		}
+
+		var data []byte
+		var err error
+		if path := cctx.Args().Get(1); path != "" {
+			data, err = ioutil.ReadFile(path)
+		} else {
+			data, err = ioutil.ReadAll(os.Stdin)
+		}
+		if err != nil {
+			return xerrors.Errorf("reading data: %w", err)
+		}
+
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		sig, err := napi.WalletServiceKeySign(ctx, cctx.Args().First(), data)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(hex.EncodeToString(sig))
+		return nil
+	},
+}
+
+var walletServiceKeyDelete = &cli.Command{
+	Name:      "delete",
+	Usage:     "Delete a service key",
+	ArgsUsage: "<name>",
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return xerrors.Errorf("must specify the key name")
+		}
+
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		return napi.WalletServiceKeyDelete(ctx, cctx.Args().First())
+	},
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/chain/wallet"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+// default suffix of detached file signatures
+const fileSignatureSuffix = ".sig.json"
+
+var walletSignFile = &cli.Command{
+	Name:      "sign-file",
+	Usage:     "Sign the sha256 digest of a file, writing a detached signature",
+	ArgsUsage: "<signer address> <file>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "out",
+			Usage: "where to write the signature, '-' for stdout (default: <file>" + fileSignatureSuffix + ")",
+		},
+		&cli.StringFlag{
+			Name:  "reason",
+			Usage: "reason for signing, recorded in the audit log and the signature",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 2 {
+			return xerrors.Errorf("must specify the signer address and file")
+		}
+
+		signer, err := address.NewFromString(cctx.Args().Get(0))
+		if err != nil {
+			return xerrors.Errorf("parsing signer address: %w", err)
+		}
+
+		path := cctx.Args().Get(1)
+		digest, err := digestFile(path)
+		if err != nil {
+			return err
+		}
+
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		fs, err := wallet.SignFileDigest(ctx, api, signer, digest, cctx.String("reason"))
+		if err != nil {
+			return err
+		}
+		fs.Name = filepath.Base(path)
+
+		out := cctx.String("out")
+		if out == "" {
+			out = path + fileSignatureSuffix
+		}
+		if err := writeFileSignature(out, fs); err != nil {
+			return err
+		}
+		if out == "-" {
+			return nil
+		}
+
+		fmt.Printf("signed %s (sha256 %s) with %s, signature written to %s\n", path, fs.SHA256, signer, out)
+		return nil
+	},
+}
+
+var walletVerifyFile = &cli.Command{
+	Name:      "verify-file",
+	Usage:     "Verify a detached file signature written by sign-file",
+	ArgsUsage: "<file> [<signature> (default: <file>" + fileSignatureSuffix + ")]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "signer",
+			Usage: "also require the signature to be made by this address",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Args().Present() || cctx.Args().Len() > 2 {
+			return xerrors.Errorf("must specify the file and optionally the signature")
+		}
+
+		path := cctx.Args().Get(0)
+		sigPath := path + fileSignatureSuffix
+		if cctx.Args().Len() == 2 {
+			sigPath = cctx.Args().Get(1)
+		}
+
+		b, err := ioutil.ReadFile(sigPath)
+		if err != nil {
+			return xerrors.Errorf("reading signature: %w", err)
+		}
+
+		var fs wallet.FileSignature
+		if err := json.Unmarshal(b, &fs); err != nil {
+			return xerrors.Errorf("parsing signature: %w", err)
+		}
+
+		if s := cctx.String("signer"); s != "" {
+			want, err := address.NewFromString(s)
+			if err != nil {
+				return xerrors.Errorf("parsing signer address: %w", err)
+			}
+			if fs.Signer != want {
+				return xerrors.Errorf("signed by %s, not %s", fs.Signer, want)
+			}
+		}
+
+		digest, err := digestFile(path)
+		if err != nil {
+			return err
+		}
+
+		if err := wallet.VerifyFileSignature(&fs, digest); err != nil {
+			return xerrors.Errorf("invalid signature: %w", err)
+		}
+
+		fmt.Printf("valid signature by %s over %s (sha256 %s)\n", fs.Signer, path, fs.SHA256)
+		return nil
+	},
+}
+
+// writeFileSignature writes a detached signature as JSON, to stdout if the
+// path is '-'
+func writeFileSignature(path string, fs *wallet.FileSignature) error {
+	b, err := json.MarshalIndent(fs, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	if path == "-" {
+		_, err := os.Stdout.Write(b)
+		return err
+	}
+
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		return xerrors.Errorf("writing signature: %w", err)
+	}
+	return nil
+}
+
+func digestFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, xerrors.Errorf("opening file: %w", err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	return wallet.FileDigest(f)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/wallet"
+	"github.com/filecoin-project/lotus/node/repo"
+)
+
+// envSnapshotPassphrase can hold the snapshot passphrase, for taking
+// snapshots from scheduled jobs
+const envSnapshotPassphrase = "LOTUS_WALLET_SNAPSHOT_PASSPHRASE"
+
+var repoSnapshotCmd = &cli.Command{
+	Name:  "snapshot",
+	Usage: "Create and restore encrypted snapshots of keys and metadata",
+	Description: `Snapshots hold the keystore together with the wallet metadata (journal,
+   audit log, policies, approvals, ...), taken while the repo is locked so
+   both are consistent with each other. Snapshots are encrypted with a
+   passphrase, read from $` + envSnapshotPassphrase + ` or prompted for.`,
+	Subcommands: []*cli.Command{
+		repoSnapshotCreateCmd,
+		repoSnapshotRestoreCmd,
+	},
+}
+
+func snapshotPassphrase(repeat bool) ([]byte, error) {
+	if p, ok := os.LookupEnv(envSnapshotPassphrase); ok {
+		return []byte(p), nil
+	}
+
+	p, err := readLine("Snapshot passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if repeat {
+		again, err := readLine("Repeat passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if again != p {
+			return nil, xerrors.Errorf("passphrases don't match")
+		}
+	}
+	return []byte(p), nil
+}
+
+var repoSnapshotCreateCmd = &cli.Command{
+	Name:  "create",
+	Usage: "Write an encrypted snapshot of the repo",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "out",
+			Usage: "file to write the snapshot to (default: a new file in the backups directory of the repo)",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		return withLockedRepo(cctx, func(lr repo.LockedRepo) error {
+			ks, err := lr.KeyStore()
+			if err != nil {
+				return err
+			}
+			ds, err := lr.Datastore("/metadata")
+			if err != nil {
+				return err
+			}
+
+			pass, err := snapshotPassphrase(true)
+			if err != nil {
+				return err
+			}
+
+			path := cctx.String("out")
+			if path == "" {
+				if path, err = newSnapshotPath(lr, "snapshot"); err != nil {
+					return err
+				}
+			}
+
+			if err := writeSnapshotFile(path, ks, ds, pass); err != nil {
+				return err
+			}
+
+			fmt.Println(path)
+			return nil
+		})
+	},
+}
+
+// newSnapshotPath returns a path for a new snapshot in the backups directory
+// of the repo
+func newSnapshotPath(lr repo.LockedRepo, prefix string) (string, error) {
+	dir := filepath.Join(lr.Path(), repoBackupDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", xerrors.Errorf("creating backup dir: %w", err)
+	}
+	return filepath.Join(dir, fmt.Sprintf("%s-%d.json", prefix, time.Now().Unix())), nil
+}
+
+func writeSnapshotFile(path string, ks types.KeyStore, ds datastore.Batching, pass []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return xerrors.Errorf("creating snapshot file: %w", err)
+	}
+	if err := wallet.WriteSnapshot(f, ks, ds, pass); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return xerrors.Errorf("syncing snapshot: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return xerrors.Errorf("closing snapshot: %w", err)
+	}
+	return nil
+}
+
+var repoSnapshotRestoreCmd = &cli.Command{
+	Name:      "restore",
+	Usage:     "Replace all keys and metadata of the repo with a snapshot",
+	ArgsUsage: "[snapshot file]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "yes",
+			Usage: "don't ask for confirmation",
+		},
+		&cli.BoolFlag{
+			Name:  "prune-keys",
+			Usage: "remove keys which are not in the snapshot from the keystore",
+		},
+	},
+	Description: `Before restoring, a snapshot of the current keys and metadata is written to
+   the backups directory of the repo, encrypted with the same passphrase.
+   Keys which are not in the snapshot are kept unless --prune-keys is set.`,
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("expected 1 argument")
+		}
+
+		f, err := os.Open(cctx.Args().First())
+		if err != nil {
+			return xerrors.Errorf("opening snapshot: %w", err)
+		}
+		defer f.Close() //nolint:errcheck
+
+		if !cctx.Bool("yes") {
+			q := "Replace all metadata with the snapshot and restore its keys?"
+			if cctx.Bool("prune-keys") {
+				q = "Replace all keys and metadata with the snapshot, removing keys not in it?"
+			}
+			ok, err := confirm(q)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return xerrors.Errorf("aborted")
+			}
+		}
+
+		return withLockedRepo(cctx, func(lr repo.LockedRepo) error {
+			ks, err := lr.KeyStore()
+			if err != nil {
+				return err
+			}
+			ds, err := lr.Datastore("/metadata")
+			if err != nil {
+				return err
+			}
+
+			pass, err := snapshotPassphrase(false)
+			if err != nil {
+				return err
+			}
+
+			prev, err := newSnapshotPath(lr, "pre-restore")
+			if err != nil {
+				return err
+			}
+			if err := writeSnapshotFile(prev, ks, ds, pass); err != nil {
+				return xerrors.Errorf("writing snapshot of the current repo: %w", err)
+			}
+			fmt.Printf("wrote snapshot of the current repo to %s\n", prev)
+
+			s, err := wallet.RestoreSnapshot(f, ks, ds, pass, cctx.Bool("prune-keys"))
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("restored snapshot taken %s at repo version %d\n", s.Created.Format(time.RFC3339), s.RepoVersion)
+			return nil
+		})
+	},
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/wallet"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+const recentFailuresKept = 20
+
+type namedBackend struct {
+	name string
+	w    api.WalletAPI
+
+	// remote wallet or ledger emulator address, if any
+	endpoint string
+}
+
+type signFailure struct {
+	Time    time.Time
+	Address address.Address
+	Type    api.MsgType
+	Reason  string
+	Error   string
+}
+
+// recentFailures keeps the last few failed sign requests for display
+type recentFailures struct {
+	lk   sync.Mutex
+	list []signFailure
+}
+
+func (r *recentFailures) add(f signFailure) {
+	if r == nil {
+		return
+	}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	r.list = append(r.list, f)
+	if len(r.list) > recentFailuresKept {
+		r.list = r.list[len(r.list)-recentFailuresKept:]
+	}
+}
+
+func (r *recentFailures) get() []signFailure {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	out := make([]signFailure, len(r.list))
+	for i := range r.list {
+		out[i] = r.list[len(r.list)-1-i]
+	}
+	return out
+}
+
+// failureRecorder records failed sign requests. It wraps the outermost wallet
+// of the daemon, so that requests denied by policies, approvals or the
+// scheduler are recorded along with backend errors.
+type failureRecorder struct {
+	api.WalletAPI
+
+	failures *recentFailures
+}
+
+func (r *failureRecorder) WalletSign(ctx context.Context, k address.Address, msg []byte, meta api.MsgMeta) (*crypto.Signature, error) {
+	sig, err := r.WalletAPI.WalletSign(ctx, k, msg, meta)
+	if err != nil {
+		r.failures.add(signFailure{
+			Time:    time.Now(),
+			Address: k,
+			Type:    meta.Type,
+			Reason:  meta.Reason,
+			Error:   err.Error(),
+		})
+	}
+
+	return sig, err
+}
+
+// statusPage serves a read-only, auto-refreshing HTML overview of the wallet
+// meant for operations wall displays.
+type statusPage struct {
+	token    string
+	started  time.Time
+	backends *backendSet
+	daemon   api.WalletDaemonAPI
+	failures *recentFailures
+}
+
+type backendStatus struct {
+	Name string
+	Keys int
+	// Keys is the backend index count, the backend wasn't listed
+	Indexed bool
+	State   string
+	Error   string
+}
+
+type statusData struct {
+	Version    string
+	Now        time.Time
+	Uptime     time.Duration
+	Backends   []backendStatus
+	PendingAgg int
+	Failures   []signFailure
+
+	WSClients        int
+	PendingApprovals int
+	FrozenGroups     []string
+}
+
+func (s *statusPage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.WriteHeader(401)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	d := statusData{
+		Version:  build.UserVersion(),
+		Now:      time.Now(),
+		Uptime:   time.Since(s.started).Truncate(time.Second),
+		Failures: s.failures.get(),
+	}
+
+	states := map[string]api.BackendStatus{}
+	if st, err := s.daemon.WalletStatus(ctx); err != nil {
+		log.Warnf("status page: getting wallet status: %s", err)
+	} else {
+		for _, bs := range st.Backends {
+			states[bs.Name] = bs
+		}
+		d.WSClients = st.WSClients
+		d.PendingApprovals = st.PendingApprovals
+		d.FrozenGroups = st.FrozenGroups
+	}
+
+	for _, b := range s.backends.list() {
+		bs := backendStatus{Name: b.name, Keys: states[b.name].IndexedKeys, Indexed: true, State: states[b.name].State}
+		if bs.State == wallet.BackendMaintenance {
+			bs.Error = states[b.name].MaintenanceReason
+			d.Backends = append(d.Backends, bs)
+			continue
+		}
+		if bs.State == wallet.BackendDegraded {
+			// don't wait on a backend which is known to be down, show what
+			// the backend index knows instead
+			bs.Error = states[b.name].LastError
+			d.Backends = append(d.Backends, bs)
+			continue
+		}
+
+		l, err := b.w.WalletList(ctx)
+		if err != nil {
+			bs.Error = err.Error()
+		}
+		if err == nil {
+			bs.Keys, bs.Indexed = len(l), false
+		}
+		d.Backends = append(d.Backends, bs)
+	}
+
+	aggs, err := s.daemon.WalletBLSAggregateList(ctx)
+	if err != nil {
+		log.Warnf("status page: listing bls aggregations: %s", err)
+	}
+	for _, a := range aggs {
+		if a.Signature == nil {
+			d.PendingAgg++
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := statusTemplate.Execute(w, d); err != nil {
+		log.Errorf("status page: rendering: %s", err)
+	}
+}
+
+// authorized checks the read-only status token, passed either as a bearer
+// token or in the `token` query parameter, as wall displays often can't set
+// headers.
+func (s *statusPage) authorized(r *http.Request) bool {
+	tok := r.URL.Query().Get("token")
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		tok = strings.TrimPrefix(h, "Bearer ")
+	}
+
+	return subtle.ConstantTimeCompare([]byte(tok), []byte(s.token)) == 1
+}
+
+var statusTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="10">
+<title>lotus-wallet status</title>
+<style>
+body { font-family: monospace; background: #111; color: #ddd; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+td, th { border: 1px solid #444; padding: 2px 8px; text-align: left; }
+.err { color: #f55; }
+</style>
+</head>
+<body>
+<h2>lotus-wallet {{.Version}}</h2>
+<p>Uptime: {{.Uptime}} &middot; Updated: {{.Now.Format "2006-01-02 15:04:05"}} &middot; WS clients: {{.WSClients}}</p>
+<p>Signing: {{if .FrozenGroups}}<span class="err">locked for frozen groups {{range $i, $g := .FrozenGroups}}{{if $i}}, {{end}}{{$g}}{{end}}</span>{{else}}unlocked{{end}}</p>
+
+<h3>Backends</h3>
+<table>
+<tr><th>Backend</th><th>Keys</th><th>State</th><th>Error</th></tr>
+{{range .Backends}}<tr><td>{{.Name}}</td><td>{{.Keys}}{{if .Indexed}} (indexed){{end}}</td><td>{{.State}}</td><td class="err">{{.Error}}</td></tr>
+{{end}}</table>
+
+<h3>Pending</h3>
+<p>Approvals: {{.PendingApprovals}} &middot; BLS aggregations: {{.PendingAgg}}</p>
+
+<h3>Recent sign failures</h3>
+<table>
+<tr><th>Time</th><th>Address</th><th>Type</th><th>Reason</th><th>Error</th></tr>
+{{range .Failures}}<tr><td>{{.Time.Format "2006-01-02 15:04:05"}}</td><td>{{.Address}}</td><td>{{.Type}}</td><td>{{.Reason}}</td><td class="err">{{.Error}}</td></tr>
+{{else}}<tr><td colspan="5">none</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+var statusCmd = &cli.Command{
+	Name:  "status",
+	Usage: "Show the state of the running wallet",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		st, err := napi.WalletStatus(ctx)
+		if err != nil {
+			return err
+		}
+
+		signing := "unlocked"
+		if len(st.FrozenGroups) > 0 {
+			signing = "locked for frozen groups " + strings.Join(st.FrozenGroups, ", ")
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(tw, "Version:\t%s\n", st.Version)
+		_, _ = fmt.Fprintf(tw, "Uptime:\t%s (since %s)\n", st.Uptime, st.Started.Format(time.RFC3339))
+		_, _ = fmt.Fprintf(tw, "WS clients:\t%d\n", st.WSClients)
+		_, _ = fmt.Fprintf(tw, "Pending approvals:\t%d\n", st.PendingApprovals)
+		_, _ = fmt.Fprintf(tw, "Signing:\t%s\n", signing)
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Println()
+
+		tw = tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Backend\tState\tIndexed Keys")
+		for _, b := range st.Backends {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\n", b.Name, b.State, b.IndexedKeys)
+		}
+		return tw.Flush()
+	},
+}
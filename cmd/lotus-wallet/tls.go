@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/xerrors"
+)
+
+// certReloader serves a TLS certificate loaded from disk, re-reading it on
+// SIGHUP so certificates can be rotated without restarting the wallet.
+type certReloader struct {
+	certPath, keyPath string
+
+	lk   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func newCertReloader(ctx context.Context, certPath, keyPath string) (*certReloader, error) {
+	cr := &certReloader{
+		certPath: certPath,
+		keyPath:  keyPath,
+	}
+	if err := cr.reload(); err != nil {
+		return nil, err
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(sigCh)
+		for {
+			select {
+			case <-sigCh:
+				if err := cr.reload(); err != nil {
+					log.Errorf("reloading tls certificate, keeping the old one: %s", err)
+					continue
+				}
+				log.Info("reloaded tls certificate")
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return cr, nil
+}
+
+func (cr *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(cr.certPath, cr.keyPath)
+	if err != nil {
+		return xerrors.Errorf("loading tls key pair: %w", err)
+	}
+
+	cr.lk.Lock()
+	cr.cert = &cert
+	cr.lk.Unlock()
+	return nil
+}
+
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.lk.RLock()
+	defer cr.lk.RUnlock()
+
+	return cr.cert, nil
+}
+
+func (cr *certReloader) config() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: cr.GetCertificate,
+	}
+}
+
+// requireClientCerts makes the server verify client certificates against the
+// CA bundle at caPath. When pins are given, the client leaf certificate must
+// additionally match one of the hex sha256 fingerprints.
+func requireClientCerts(cfg *tls.Config, caPath string, pins []string) error {
+	caPEM, err := ioutil.ReadFile(caPath)
+	if err != nil {
+		return xerrors.Errorf("reading client CA: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return xerrors.Errorf("no certificates found in client CA file %s", caPath)
+	}
+
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+
+	if len(pins) == 0 {
+		return nil
+	}
+
+	pinned := map[string]struct{}{}
+	for _, p := range pins {
+		p = strings.ToLower(strings.ReplaceAll(p, ":", ""))
+		if b, err := hex.DecodeString(p); err != nil || len(b) != sha256.Size {
+			return xerrors.Errorf("invalid client certificate fingerprint %q", p)
+		}
+		pinned[p] = struct{}{}
+	}
+
+	cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return xerrors.Errorf("no client certificate")
+		}
+
+		fp := sha256.Sum256(rawCerts[0])
+		if _, ok := pinned[hex.EncodeToString(fp[:])]; !ok {
+			log.Warnw("rejecting client certificate which isn't pinned", "fingerprint", hex.EncodeToString(fp[:]))
+			return xerrors.Errorf("client certificate not pinned")
+		}
+		return nil
+	}
+
+	return nil
+}
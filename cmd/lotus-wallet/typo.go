@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+var typoCheckFlag = &cli.BoolFlag{
+	Name:  "yes",
+	Usage: "don't ask for confirmation when an address looks like a typo of a known address",
+}
+
+// knownAddresses returns addresses the wallet knows about: its keys, addresses
+// with policies, allowed recipients and key group members
+func knownAddresses(ctx context.Context, napi api.WalletDaemonAPI) (map[address.Address]struct{}, error) {
+	known := map[address.Address]struct{}{}
+
+	keys, err := napi.WalletList(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("listing keys: %w", err)
+	}
+	for _, a := range keys {
+		known[a] = struct{}{}
+	}
+
+	pols, err := napi.WalletPolicyList(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("listing policies: %w", err)
+	}
+	for _, ap := range pols {
+		known[ap.Address] = struct{}{}
+		for _, a := range ap.Policy.AllowedTo {
+			known[a] = struct{}{}
+		}
+	}
+
+	groups, err := napi.WalletGroupList(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("listing key groups: %w", err)
+	}
+	for _, g := range groups {
+		for _, a := range g.Members {
+			known[a] = struct{}{}
+		}
+		if g.Policy != nil {
+			for _, a := range g.Policy.AllowedTo {
+				known[a] = struct{}{}
+			}
+		}
+	}
+
+	return known, nil
+}
+
+// checkAddressTypos warns about addresses which aren't known to the wallet but
+// are a single character away from a known one. ID addresses have no
+// checksum, so f01234 entered as f01243 parses fine. Unless --yes is set the
+// operator has to confirm before continuing.
+func checkAddressTypos(ctx context.Context, cctx *cli.Context, napi api.WalletDaemonAPI, addrs ...address.Address) error {
+	if len(addrs) == 0 || cctx.Bool(typoCheckFlag.Name) {
+		return nil
+	}
+
+	known, err := knownAddresses(ctx, napi)
+	if err != nil {
+		return err
+	}
+
+	var suspicious bool
+	for _, a := range addrs {
+		if _, ok := known[a]; ok {
+			continue
+		}
+		for k := range known {
+			if oneEditApart(a.String(), k.String()) {
+				_, _ = fmt.Fprintf(os.Stderr, "WARNING: %s is not known to this wallet, but differs from known address %s by one character\n", a, k)
+				suspicious = true
+			}
+		}
+	}
+	if !suspicious {
+		return nil
+	}
+
+	ok, err := confirm("Continue with the address as entered?")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return xerrors.Errorf("aborted")
+	}
+	return nil
+}
+
+// oneEditApart returns true if a and b differ by exactly one substituted,
+// inserted or deleted character
+func oneEditApart(a, b string) bool {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	if len(a)-len(b) > 1 || a == b {
+		return false
+	}
+
+	i := 0
+	for i < len(b) && a[i] == b[i] {
+		i++
+	}
+	if len(a) == len(b) {
+		return a[i+1:] == b[i+1:]
+	}
+	return a[i+1:] == b[i:]
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ipfs/go-cid"
+	blst "github.com/supranational/blst/bindings/go"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/wallet"
+	"github.com/filecoin-project/lotus/lib/sigs"
+)
+
+// SignVector is a canonical signing test case for a fixed key. Chain messages
+// are signed over the bytes of their CID.
+type SignVector struct {
+	KeyType    types.KeyType
+	PrivateKey string // hex-lotus encoded key info, as accepted by `wallet import`
+	Address    string
+
+	Message         *types.Message
+	UnsignedMessage string // hex of the CBOR encoded message
+	Cid             cid.Cid
+	SigningBytes    string // hex of the bytes passed to the signer
+
+	Signature     *crypto.Signature
+	SignedMessage string // hex of the CBOR encoded signed message
+	SignedCid     cid.Cid
+}
+
+// vectorKey derives the idx-th fixed test key of the given type. Never use
+// these keys for anything holding funds.
+func vectorKey(typ types.KeyType, idx int) (*wallet.Key, error) {
+	seed := sha256.Sum256([]byte(fmt.Sprintf("lotus-wallet test vector key %s %d", typ, idx)))
+
+	var pk []byte
+	switch typ {
+	case types.KTSecp256k1:
+		pk = seed[:]
+	case types.KTBLS:
+		pk = blst.KeyGen(seed[:]).ToLEndian()
+	default:
+		return nil, xerrors.Errorf("unsupported key type: %s", typ)
+	}
+
+	return wallet.NewKey(types.KeyInfo{
+		Type:       typ,
+		PrivateKey: pk,
+	})
+}
+
+func vectorMessages(from address.Address) ([]*types.Message, error) {
+	to, err := address.NewIDAddress(1000)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*types.Message{
+		{
+			// plain send
+			Version:    0,
+			To:         to,
+			From:       from,
+			Nonce:      0,
+			Value:      types.FromFil(1),
+			GasLimit:   1000000,
+			GasFeeCap:  abi.NewTokenAmount(100000),
+			GasPremium: abi.NewTokenAmount(1000),
+			Method:     0,
+		},
+		{
+			// method call with params and a zero value
+			Version:    0,
+			To:         to,
+			From:       from,
+			Nonce:      42,
+			Value:      big.Zero(),
+			GasLimit:   25000000,
+			GasFeeCap:  abi.NewTokenAmount(1500000000),
+			GasPremium: abi.NewTokenAmount(1200000),
+			Method:     2,
+			Params:     []byte{0x82, 0x01, 0x42, 0xca, 0xfe},
+		},
+	}, nil
+}
+
+func genVectors(keysPerType int) ([]SignVector, error) {
+	var out []SignVector
+
+	for _, typ := range []types.KeyType{types.KTSecp256k1, types.KTBLS} {
+		for i := 0; i < keysPerType; i++ {
+			k, err := vectorKey(typ, i)
+			if err != nil {
+				return nil, xerrors.Errorf("deriving %s key %d: %w", typ, i, err)
+			}
+
+			kib, err := json.Marshal(k.KeyInfo)
+			if err != nil {
+				return nil, err
+			}
+
+			msgs, err := vectorMessages(k.Address)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, msg := range msgs {
+				mb, err := msg.Serialize()
+				if err != nil {
+					return nil, xerrors.Errorf("serializing message: %w", err)
+				}
+
+				mcid := msg.Cid()
+				sig, err := sigs.Sign(wallet.ActSigType(typ), k.PrivateKey, mcid.Bytes())
+				if err != nil {
+					return nil, xerrors.Errorf("signing with %s: %w", k.Address, err)
+				}
+
+				smsg := &types.SignedMessage{
+					Message:   *msg,
+					Signature: *sig,
+				}
+				smb, err := smsg.Serialize()
+				if err != nil {
+					return nil, xerrors.Errorf("serializing signed message: %w", err)
+				}
+
+				out = append(out, SignVector{
+					KeyType:    typ,
+					PrivateKey: hex.EncodeToString(kib),
+					Address:    k.Address.String(),
+
+					Message:         msg,
+					UnsignedMessage: hex.EncodeToString(mb),
+					Cid:             mcid,
+					SigningBytes:    hex.EncodeToString(mcid.Bytes()),
+
+					Signature:     sig,
+					SignedMessage: hex.EncodeToString(smb),
+					SignedCid:     smsg.Cid(),
+				})
+			}
+		}
+	}
+
+	return out, nil
+}
+
+var genVectorsCmd = &cli.Command{
+	Name:  "gen-vectors",
+	Usage: "Print canonical signing test vectors for fixed test keys as JSON",
+	Description: `Emits unsigned message bytes, expected CIDs and expected signatures for
+   fixed secp256k1 and BLS test keys, so other signer implementations can check
+   they produce the same results as this wallet. Addresses are printed with the
+   network prefix this binary was built for.`,
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "keys",
+			Usage: "number of test keys per key type",
+			Value: 2,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		vectors, err := genVectors(cctx.Int("keys"))
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(vectors)
+	},
+}
@@ -0,0 +1,541 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/client"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/wallet"
+	lcli "github.com/filecoin-project/lotus/cli"
+	cliutil "github.com/filecoin-project/lotus/cli/util"
+	"github.com/filecoin-project/lotus/lib/sigs"
+)
+
+var walletCmd = &cli.Command{
+	Name:  "wallet",
+	Usage: "Manage keys held by a running lotus wallet",
+	Subcommands: []*cli.Command{
+		walletImport,
+		walletInstancePubkey,
+		walletWrapKey,
+		walletMigrateKeys,
+		walletFindSignature,
+		walletCid,
+		walletSignFile,
+		walletVerifyFile,
+		walletList,
+		walletBackends,
+		walletListOwned,
+		walletNewMnemonic,
+		walletRestoreMnemonic,
+		walletLedgerShow,
+		walletHD,
+		walletDev,
+		walletServiceKey,
+	},
+}
+
+var walletImport = &cli.Command{
+	Name:      "import",
+	Usage:     "Import a hex-lotus encoded key, optionally wrapped to the wallet instance key",
+	ArgsUsage: "[<path> (optional, will read from stdin if omitted)]",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		ki, err := readHexKeyInfo(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		addr, err := api.WalletImport(ctx, ki)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("imported key %s successfully!\n", addr)
+		return nil
+	},
+}
+
+var walletInstancePubkey = &cli.Command{
+	Name:  "instance-pubkey",
+	Usage: "Print the public key which keys can be wrapped to for import into this wallet",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		pk, err := api.WalletInstancePubkey(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(hex.EncodeToString(pk))
+		return nil
+	},
+}
+
+var walletFindSignature = &cli.Command{
+	Name:      "find-signature",
+	Usage:     "Check whether this wallet signed the message with the given CID",
+	ArgsUsage: "<message cid>",
+	Flags: []cli.Flag{
+		&lcli.CidBaseFlag,
+	},
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Args().Present() {
+			return xerrors.Errorf("must specify the message cid")
+		}
+
+		c, err := wallet.ParseCid(cctx.Args().First())
+		if err != nil {
+			return xerrors.Errorf("parsing message cid: %w", err)
+		}
+		enc, err := lcli.GetCidEncoder(cctx)
+		if err != nil {
+			return err
+		}
+
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		rec, err := api.WalletFindSignature(ctx, c)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Message:   %s\n", enc.Encode(rec.Cid))
+		fmt.Printf("Signer:    %s\n", rec.Signer)
+		fmt.Printf("Time:      %s\n", rec.Time.Format(time.RFC3339))
+		fmt.Printf("Caller:    %s\n", rec.Caller)
+		if rec.Reason != "" {
+			fmt.Printf("Reason:    %s\n", rec.Reason)
+		}
+		fmt.Printf("Signature: %s\n", hex.EncodeToString(rec.Signature.Data))
+		return nil
+	},
+}
+
+var walletWrapKey = &cli.Command{
+	Name:      "wrap-key",
+	Usage:     "Wrap a hex-lotus encoded key to a wallet instance public key (works offline)",
+	ArgsUsage: "<instance pubkey> [<path> (optional, will read from stdin if omitted)]",
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Args().Present() {
+			return xerrors.Errorf("must specify the instance public key")
+		}
+
+		pk, err := hex.DecodeString(cctx.Args().First())
+		if err != nil {
+			return xerrors.Errorf("decoding instance public key: %w", err)
+		}
+
+		ki, err := readHexKeyInfo(cctx.Args().Get(1))
+		if err != nil {
+			return err
+		}
+
+		wki, err := wallet.WrapKey(pk, ki)
+		if err != nil {
+			return err
+		}
+
+		b, err := json.Marshal(wki)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(hex.EncodeToString(b))
+		return nil
+	},
+}
+
+var walletMigrateKeys = &cli.Command{
+	Name:      "migrate-keys",
+	Usage:     "Move keys to another wallet over RPC",
+	ArgsUsage: "[addresses (all exportable keys if omitted)]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "to",
+			Usage:    "api info (TOKEN:URL) of the destination wallet",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "to-pubkey",
+			Usage: "instance public key of the destination wallet ('wallet instance-pubkey' there), needed to migrate over an unencrypted connection to a non-loopback host",
+		},
+		&cli.BoolFlag{
+			Name:  "insecure",
+			Usage: "allow sending keys over an unencrypted connection to a non-loopback host without a pinned --to-pubkey",
+		},
+		&cli.BoolFlag{
+			Name:  "delete-source",
+			Usage: "delete migrated keys from this wallet after they were verified at the destination",
+		},
+		&cli.BoolFlag{
+			Name:  "yes",
+			Usage: "don't ask for confirmation before deleting source keys",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		src, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		ainfo := cliutil.ParseApiInfo(cctx.String("to"))
+		dialAddr, err := ainfo.DialArgs()
+		if err != nil {
+			return xerrors.Errorf("parsing destination api info: %w", err)
+		}
+
+		dst, dcloser, err := client.NewWalletDaemonRPC(ctx, dialAddr, ainfo.AuthHeader())
+		if err != nil {
+			return xerrors.Errorf("connecting to destination wallet: %w", err)
+		}
+		defer dcloser()
+
+		// If the destination is a lotus-wallet, keys are wrapped to its
+		// instance key. A key fetched over the connection can only be
+		// trusted when the connection is secure; otherwise it must match
+		// the pinned key.
+		var dstPub []byte
+		if pin := cctx.String("to-pubkey"); pin != "" {
+			dstPub, err = hex.DecodeString(pin)
+			if err != nil {
+				return xerrors.Errorf("decoding --to-pubkey: %w", err)
+			}
+			got, err := dst.WalletInstancePubkey(ctx)
+			if err != nil {
+				return xerrors.Errorf("getting destination instance public key: %w", err)
+			}
+			if !bytes.Equal(got, dstPub) {
+				return xerrors.Errorf("destination instance public key %x doesn't match --to-pubkey", got)
+			}
+		} else {
+			if !cctx.Bool("insecure") {
+				if err := checkSecureEndpoint(dialAddr); err != nil {
+					return err
+				}
+			}
+
+			dstPub, err = dst.WalletInstancePubkey(ctx)
+			if err != nil {
+				log.Infof("destination doesn't support key wrapping: %s", err)
+				dstPub = nil
+			}
+		}
+
+		var addrs []address.Address
+		if cctx.Args().Present() {
+			for _, s := range cctx.Args().Slice() {
+				a, err := lcli.ParseWalletAddress(ctx, src, s)
+				if err != nil {
+					return err
+				}
+				addrs = append(addrs, a)
+			}
+		} else {
+			addrs, err = src.WalletList(ctx)
+			if err != nil {
+				return xerrors.Errorf("listing source wallet: %w", err)
+			}
+		}
+
+		var migrated []address.Address
+		for _, a := range addrs {
+			ki, err := src.WalletExport(ctx, a)
+			if err != nil {
+				fmt.Printf("%s: skipping, export failed: %s\n", a, err)
+				continue
+			}
+
+			if dstPub != nil {
+				ki, err = wallet.WrapKey(dstPub, ki)
+				if err != nil {
+					return xerrors.Errorf("wrapping %s: %w", a, err)
+				}
+			}
+
+			na, err := dst.WalletImport(ctx, ki)
+			if err != nil {
+				return xerrors.Errorf("importing %s at destination: %w", a, err)
+			}
+			if na != a {
+				return xerrors.Errorf("destination imported key as %s, expected %s", na, a)
+			}
+
+			if err := verifyOwnership(ctx, dst, a); err != nil {
+				return xerrors.Errorf("verifying %s at destination: %w", a, err)
+			}
+
+			fmt.Printf("%s: migrated and verified\n", a)
+			migrated = append(migrated, a)
+		}
+
+		if !cctx.Bool("delete-source") || len(migrated) == 0 {
+			return nil
+		}
+
+		if !cctx.Bool("yes") {
+			fmt.Printf("Delete %d migrated keys from the source wallet? (yes/no): ", len(migrated))
+			yn, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil {
+				return xerrors.Errorf("reading confirmation: %w", err)
+			}
+			if strings.TrimSpace(yn) != "yes" {
+				fmt.Println("Source keys were not deleted")
+				return nil
+			}
+		}
+
+		for _, a := range migrated {
+			if err := src.WalletDelete(ctx, a); err != nil {
+				return xerrors.Errorf("deleting %s from source wallet: %w", a, err)
+			}
+			fmt.Printf("%s: deleted from source wallet\n", a)
+		}
+
+		return nil
+	},
+}
+
+// verifyOwnership asks the wallet to sign a random challenge with the given
+// address and checks the signature locally.
+func verifyOwnership(ctx context.Context, w api.WalletAPI, a address.Address) error {
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return xerrors.Errorf("generating challenge: %w", err)
+	}
+
+	sig, err := w.WalletSign(ctx, a, challenge, api.MsgMeta{Type: api.MTUnknown})
+	if err != nil {
+		return xerrors.Errorf("signing challenge: %w", err)
+	}
+
+	return sigs.Verify(sig, a, challenge)
+}
+
+// checkSecureEndpoint returns an error unless the endpoint is either
+// TLS-protected or on a loopback interface.
+func checkSecureEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return xerrors.Errorf("parsing endpoint: %w", err)
+	}
+
+	switch u.Scheme {
+	case "wss", "https":
+		return nil
+	}
+
+	host := u.Hostname()
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+
+	return xerrors.Errorf("refusing to send key material over unencrypted connection to %s; use a wss:// or https:// endpoint, pin the destination with --to-pubkey, or pass --insecure", u.Host)
+}
+
+// readHexKeyInfo reads a hex-lotus encoded KeyInfo from the given file, or
+// from stdin if path is empty.
+func readHexKeyInfo(path string) (*types.KeyInfo, error) {
+	var inpdata []byte
+	var err error
+	if path == "" || path == "-" {
+		reader := bufio.NewReader(os.Stdin)
+		fmt.Print("Enter private key: ")
+		indata, err := reader.ReadBytes('\n')
+		if err != nil {
+			return nil, err
+		}
+		inpdata = indata
+	} else {
+		inpdata, err = ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := hex.DecodeString(strings.TrimSpace(string(inpdata)))
+	if err != nil {
+		return nil, xerrors.Errorf("decoding key: %w", err)
+	}
+
+	var ki types.KeyInfo
+	if err := json.Unmarshal(data, &ki); err != nil {
+		return nil, xerrors.Errorf("unmarshaling key: %w", err)
+	}
+
+	return &ki, nil
+}
+
+var walletBackends = &cli.Command{
+	Name:  "backends",
+	Usage: "List which backends hold the key of each address",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "duplicates",
+			Usage: "only list addresses held by more than one backend",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		held, err := napi.WalletBackends(ctx)
+		if err != nil {
+			return err
+		}
+
+		copies := map[address.Address]int{}
+		for _, h := range held {
+			copies[h.Address]++
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Address\tBackend\tEndpoint\tCopies")
+		for _, h := range held {
+			if cctx.Bool("duplicates") && copies[h.Address] < 2 {
+				continue
+			}
+			endpoint := h.Endpoint
+			if endpoint == "" {
+				endpoint = "-"
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", h.Address, h.Backend, endpoint, copies[h.Address])
+		}
+		return tw.Flush()
+	},
+}
+
+var walletList = &cli.Command{
+	Name:  "list",
+	Usage: "List addresses, sorted by address",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "backend",
+			Usage: "only list addresses held by this backend (local, ledger, remote)",
+		},
+		&cli.StringFlag{
+			Name:  "type",
+			Usage: "only list addresses of this type (secp256k1, bls)",
+		},
+		&cli.IntFlag{
+			Name:  "offset",
+			Usage: "skip this many addresses",
+		},
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "list at most this many addresses (0 for no limit)",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		f := api.WalletListFilter{
+			Backend: cctx.String("backend"),
+			Offset:  cctx.Int("offset"),
+			Limit:   cctx.Int("limit"),
+		}
+		switch cctx.String("type") {
+		case "":
+		case "secp256k1":
+			p := address.SECP256K1
+			f.Protocol = &p
+		case "bls":
+			p := address.BLS
+			f.Protocol = &p
+		default:
+			return xerrors.Errorf("unknown address type %q, expected secp256k1 or bls", cctx.String("type"))
+		}
+
+		napi, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		page, err := napi.WalletListPage(ctx, f)
+		if err != nil {
+			return err
+		}
+
+		for _, a := range page.Addresses {
+			fmt.Println(a)
+		}
+		if len(page.Addresses) < page.Total {
+			_, _ = fmt.Fprintf(os.Stderr, "listed %d of %d addresses\n", len(page.Addresses), page.Total)
+		}
+		return nil
+	},
+}
+
+var walletListOwned = &cli.Command{
+	Name:  "list-owned",
+	Usage: "List addresses with the backend holding them and verify ownership proofs",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := lcli.GetWalletAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		owned, err := api.WalletListOwned(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Address\tBackend\tProof Time\tProof")
+		for _, oa := range owned {
+			proof := "ok"
+			if err := wallet.VerifyOwnershipProof(oa, 0); err != nil {
+				proof = err.Error()
+			}
+
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", oa.Address, oa.Backend, oa.ProofTime.Format("2006-01-02 15:04:05"), proof)
+		}
+		return tw.Flush()
+	},
+}
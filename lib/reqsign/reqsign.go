@@ -0,0 +1,154 @@
+// Package reqsign implements optional HMAC signing of RPC requests with a
+// timestamp and a nonce, so that captured requests can't be replayed against
+// the server later.
+package reqsign
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/xerrors"
+)
+
+var log = logging.Logger("reqsign")
+
+const (
+	HeaderTimestamp = "X-Lotus-Timestamp"
+	HeaderNonce     = "X-Lotus-Nonce"
+	HeaderSignature = "X-Lotus-Signature"
+)
+
+// Headers returns headers authenticating a request with the given method, path
+// and body.
+func Headers(key []byte, method, path string, body []byte) (http.Header, error) {
+	var nb [16]byte
+	if _, err := rand.Read(nb[:]); err != nil {
+		return nil, xerrors.Errorf("generating nonce: %w", err)
+	}
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := hex.EncodeToString(nb[:])
+
+	h := http.Header{}
+	h.Set(HeaderTimestamp, ts)
+	h.Set(HeaderNonce, nonce)
+	h.Set(HeaderSignature, hex.EncodeToString(mac(key, method, path, ts, nonce, body)))
+	return h, nil
+}
+
+func mac(key []byte, method, path, ts, nonce string, body []byte) []byte {
+	bh := sha256.Sum256(body)
+
+	m := hmac.New(sha256.New, key)
+	_, _ = m.Write([]byte(method + "\n" + path + "\n" + ts + "\n" + nonce + "\n"))
+	_, _ = m.Write(bh[:])
+	return m.Sum(nil)
+}
+
+// DefaultMaxBody is the largest request body a Verifier reads by default
+const DefaultMaxBody = 16 << 20
+
+// Verifier checks request signatures, rejecting requests with a timestamp
+// outside of the allowed clock skew or with a nonce seen before.
+type Verifier struct {
+	Key  []byte
+	Skew time.Duration
+	// Largest request body read to check the signature, DefaultMaxBody when 0
+	MaxBody int64
+
+	Next http.HandlerFunc
+
+	lk     sync.Mutex
+	nonces map[string]time.Time
+}
+
+func (v *Verifier) maxBody() int64 {
+	if v.MaxBody > 0 {
+		return v.MaxBody
+	}
+	return DefaultMaxBody
+}
+
+func (v *Verifier) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, v.maxBody())
+	}
+	if err := v.Verify(r); err != nil {
+		log.Warnf("rejecting request from %s: %s", r.RemoteAddr, err)
+		w.WriteHeader(401)
+		return
+	}
+
+	v.Next(w, r)
+}
+
+// Verify checks the signature headers of the request. The request body is
+// restored after being read; requests with a body larger than MaxBody are
+// rejected.
+func (v *Verifier) Verify(r *http.Request) error {
+	ts := r.Header.Get(HeaderTimestamp)
+	nonce := r.Header.Get(HeaderNonce)
+	sig, err := hex.DecodeString(r.Header.Get(HeaderSignature))
+	if err != nil {
+		return xerrors.Errorf("decoding signature: %w", err)
+	}
+	if ts == "" || nonce == "" || len(sig) == 0 {
+		return xerrors.New("request isn't signed")
+	}
+
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return xerrors.Errorf("parsing timestamp: %w", err)
+	}
+	now := time.Now()
+	if d := now.Sub(time.Unix(sec, 0)); d > v.Skew || d < -v.Skew {
+		return xerrors.Errorf("request timestamp off by %s, allowed skew is %s", d, v.Skew)
+	}
+
+	var body []byte
+	if r.Body != nil {
+		max := v.maxBody()
+		body, err = ioutil.ReadAll(io.LimitReader(r.Body, max+1))
+		if err != nil {
+			return xerrors.Errorf("reading body: %w", err)
+		}
+		if int64(len(body)) > max {
+			return xerrors.Errorf("request body exceeds %d bytes", max)
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	if !hmac.Equal(sig, mac(v.Key, r.Method, r.URL.Path, ts, nonce, body)) {
+		return xerrors.New("invalid request signature")
+	}
+
+	v.lk.Lock()
+	defer v.lk.Unlock()
+
+	if v.nonces == nil {
+		v.nonces = map[string]time.Time{}
+	}
+	for n, exp := range v.nonces {
+		if now.After(exp) {
+			delete(v.nonces, n)
+		}
+	}
+	if _, seen := v.nonces[nonce]; seen {
+		return xerrors.New("nonce was already used")
+	}
+	// a nonce only needs to be remembered until its timestamp falls out of the
+	// skew window
+	v.nonces[nonce] = time.Unix(sec, 0).Add(v.Skew)
+
+	return nil
+}
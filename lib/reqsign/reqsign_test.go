@@ -0,0 +1,67 @@
+package reqsign
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifier(t *testing.T) {
+	key := []byte("secret")
+	body := []byte(`{"method":"Filecoin.WalletList"}`)
+
+	v := &Verifier{
+		Key:  key,
+		Skew: time.Minute,
+		Next: func(w http.ResponseWriter, r *http.Request) {},
+	}
+
+	req := func(h http.Header, body []byte) *http.Request {
+		r := httptest.NewRequest("POST", "/rpc/v0", bytes.NewReader(body))
+		for k, vs := range h {
+			r.Header[k] = vs
+		}
+		return r
+	}
+
+	h, err := Headers(key, "POST", "/rpc/v0", body)
+	require.NoError(t, err)
+
+	require.NoError(t, v.Verify(req(h, body)))
+
+	// replay
+	require.Error(t, v.Verify(req(h, body)))
+
+	// tampered body
+	h, err = Headers(key, "POST", "/rpc/v0", body)
+	require.NoError(t, err)
+	require.Error(t, v.Verify(req(h, []byte(`{"method":"Filecoin.WalletExport"}`))))
+
+	// wrong key
+	h, err = Headers([]byte("other"), "POST", "/rpc/v0", body)
+	require.NoError(t, err)
+	require.Error(t, v.Verify(req(h, body)))
+
+	// unsigned
+	require.Error(t, v.Verify(req(http.Header{}, body)))
+
+	// old timestamp
+	h, err = Headers(key, "POST", "/rpc/v0", body)
+	require.NoError(t, err)
+	ts := strconv.FormatInt(time.Now().Add(-2*time.Minute).Unix(), 10)
+	h.Set(HeaderTimestamp, ts)
+	h.Set(HeaderSignature, hex.EncodeToString(mac(key, "POST", "/rpc/v0", ts, h.Get(HeaderNonce), body)))
+	require.Error(t, v.Verify(req(h, body)))
+
+	// oversized body
+	v.MaxBody = int64(len(body)) - 1
+	h, err = Headers(key, "POST", "/rpc/v0", body)
+	require.NoError(t, err)
+	require.Error(t, v.Verify(req(h, body)))
+}
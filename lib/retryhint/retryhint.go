@@ -0,0 +1,66 @@
+// Package retryhint attaches retry-after hints to errors of requests refused
+// for a temporary reason, in a form which survives the JSON-RPC transport.
+package retryhint
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// Error is returned when a request is refused for a temporary reason, like a
+// full sign queue, a degraded backend or an exhausted budget. Its message ends
+// with a retry-after hint, so the hint survives the JSON-RPC transport, which
+// only carries error messages, and can be read back by clients with After.
+type Error struct {
+	Err   error
+	After time.Duration
+}
+
+// Later wraps err with a hint to retry after the given time, rounded up to
+// whole seconds
+func Later(after time.Duration, err error) error {
+	if r := after % time.Second; r != 0 {
+		after += time.Second - r
+	}
+	if after < time.Second {
+		after = time.Second
+	}
+
+	return &Error{Err: err, After: after}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (retry-after: %s)", e.Err, e.After)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+var retryAfterRe = regexp.MustCompile(`\(retry-after: ([0-9hms.]+)\)`)
+
+// After returns the retry-after hint of an error, if it has one. Errors
+// received over JSON-RPC are recognised by their message.
+func After(err error) (time.Duration, bool) {
+	if err == nil {
+		return 0, false
+	}
+
+	var re *Error
+	if xerrors.As(err, &re) {
+		return re.After, true
+	}
+
+	m := retryAfterRe.FindAllStringSubmatch(err.Error(), -1)
+	if len(m) == 0 {
+		return 0, false
+	}
+	d, perr := time.ParseDuration(m[len(m)-1][1])
+	if perr != nil {
+		return 0, false
+	}
+	return d, true
+}
@@ -0,0 +1,34 @@
+package retryhint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+func TestLaterRoundsUp(t *testing.T) {
+	after, ok := After(Later(1500*time.Millisecond, xerrors.New("queue full")))
+	require.True(t, ok)
+	require.Equal(t, 2*time.Second, after)
+
+	after, ok = After(Later(0, xerrors.New("queue full")))
+	require.True(t, ok)
+	require.Equal(t, time.Second, after)
+}
+
+func TestAfterFromMessage(t *testing.T) {
+	err := Later(90*time.Second, xerrors.New("sign queue is full"))
+
+	// JSON-RPC clients only get the message, possibly wrapped
+	rpcErr := xerrors.Errorf("remote: %s", err.Error())
+	after, ok := After(rpcErr)
+	require.True(t, ok)
+	require.Equal(t, 90*time.Second, after)
+
+	_, ok = After(xerrors.New("key group is frozen"))
+	require.False(t, ok)
+	_, ok = After(nil)
+	require.False(t, ok)
+}
@@ -18,6 +18,7 @@ type SecretKey = blst.SecretKey
 type PublicKey = blst.P1Affine
 type Signature = blst.P2Affine
 type AggregateSignature = blst.P2Aggregate
+type AggregatePublicKey = blst.P1Aggregate
 
 type blsSigner struct{}
 
@@ -56,6 +57,26 @@ func (blsSigner) Verify(sig []byte, a address.Address, msg []byte) error {
 	return nil
 }
 
+// AggregateSignatures combines compressed signatures into a single compressed
+// signature.
+func AggregateSignatures(sigs [][]byte) ([]byte, error) {
+	agg := new(AggregateSignature).AggregateCompressed(sigs)
+	if agg == nil {
+		return nil, fmt.Errorf("bls signature aggregation failed")
+	}
+	return agg.ToAffine().Compress(), nil
+}
+
+// AggregatePublicKeys combines compressed public keys into a single compressed
+// public key, which verifies signatures aggregated with AggregateSignatures.
+func AggregatePublicKeys(pks [][]byte) ([]byte, error) {
+	agg := new(AggregatePublicKey).AggregateCompressed(pks)
+	if agg == nil {
+		return nil, fmt.Errorf("bls public key aggregation failed")
+	}
+	return agg.ToAffine().Compress(), nil
+}
+
 func init() {
 	sigs.RegisterSignature(crypto.SigTypeBLS, blsSigner{})
 }
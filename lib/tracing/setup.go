@@ -17,6 +17,12 @@ func SetupJaegerTracing(serviceName string) *jaeger.Exporter {
 	}
 	agentEndpointURI := os.Getenv("LOTUS_JAEGER")
 
+	return SetupJaegerTracingAgent(serviceName, agentEndpointURI)
+}
+
+// SetupJaegerTracingAgent exports all traces to the jaeger agent at the given
+// host:port.
+func SetupJaegerTracingAgent(serviceName string, agentEndpointURI string) *jaeger.Exporter {
 	je, err := jaeger.NewExporter(jaeger.Options{
 		AgentEndpoint: agentEndpointURI,
 		ServiceName:   serviceName,
@@ -16,18 +16,22 @@ var defaultMillisecondsDistribution = view.Distribution(0.01, 0.05, 0.1, 0.3, 0.
 
 // Global Tags
 var (
-	Version, _      = tag.NewKey("version")
-	Commit, _       = tag.NewKey("commit")
-	PeerID, _       = tag.NewKey("peer_id")
-	MinerID, _      = tag.NewKey("miner_id")
-	FailureType, _  = tag.NewKey("failure_type")
-	Local, _        = tag.NewKey("local")
-	MessageFrom, _  = tag.NewKey("message_from")
-	MessageTo, _    = tag.NewKey("message_to")
-	MessageNonce, _ = tag.NewKey("message_nonce")
-	ReceivedFrom, _ = tag.NewKey("received_from")
-	Endpoint, _     = tag.NewKey("endpoint")
-	APIInterface, _ = tag.NewKey("api") // to distinguish between gateway api and full node api endpoint calls
+	Version, _        = tag.NewKey("version")
+	Commit, _         = tag.NewKey("commit")
+	PeerID, _         = tag.NewKey("peer_id")
+	MinerID, _        = tag.NewKey("miner_id")
+	FailureType, _    = tag.NewKey("failure_type")
+	Local, _          = tag.NewKey("local")
+	MessageFrom, _    = tag.NewKey("message_from")
+	MessageTo, _      = tag.NewKey("message_to")
+	MessageNonce, _   = tag.NewKey("message_nonce")
+	ReceivedFrom, _   = tag.NewKey("received_from")
+	Endpoint, _       = tag.NewKey("endpoint")
+	APIInterface, _   = tag.NewKey("api") // to distinguish between gateway api and full node api endpoint calls
+	WalletCaller, _   = tag.NewKey("caller")
+	WalletBackend, _  = tag.NewKey("backend")
+	WalletSigner, _   = tag.NewKey("signer")
+	WalletPriority, _ = tag.NewKey("priority")
 )
 
 // Measures
@@ -57,6 +61,20 @@ var (
 	APIRequestDuration                  = stats.Float64("api/request_duration_ms", "Duration of API requests", stats.UnitMilliseconds)
 	VMFlushCopyDuration                 = stats.Float64("vm/flush_copy_ms", "Time spent in VM Flush Copy", stats.UnitMilliseconds)
 	VMFlushCopyCount                    = stats.Int64("vm/flush_copy_count", "Number of copied objects", stats.UnitDimensionless)
+	WalletJournalEntries                = stats.Int64("wallet/journal_entries", "Number of signatures recorded in the wallet signature journal", stats.UnitDimensionless)
+	WalletJournalPruned                 = stats.Int64("wallet/journal_pruned", "Counter for signatures pruned from the wallet signature journal", stats.UnitDimensionless)
+	WalletSignQueueDepth                = stats.Int64("wallet/sign_queue_depth", "Number of sign requests waiting for a signing slot, per caller", stats.UnitDimensionless)
+	WalletSign                          = stats.Int64("wallet/sign", "Counter for signatures produced, per backend", stats.UnitDimensionless)
+	WalletSignFailure                   = stats.Int64("wallet/sign_failure", "Counter for failed sign requests, per backend", stats.UnitDimensionless)
+	WalletSignDuration                  = stats.Float64("wallet/sign_ms", "Duration of sign requests, per backend", stats.UnitMilliseconds)
+	WalletWSClients                     = stats.Int64("wallet/ws_clients", "Number of api clients connected over websocket", stats.UnitDimensionless)
+	WalletSignVerifyFailure             = stats.Int64("wallet/sign_verify_failure", "Counter for signatures returned by wallet backends which failed verification, per backend", stats.UnitDimensionless)
+	WalletSignLatency                   = stats.Float64("wallet/sign_latency_ms", "Time from receiving a sign request to returning it, including time spent waiting for a signing slot, per priority", stats.UnitMilliseconds)
+	WalletSignSLOMissed                 = stats.Int64("wallet/sign_slo_missed", "Counter for sign requests taking longer than the latency objective of their priority", stats.UnitDimensionless)
+	WalletUnexpectedAddress             = stats.Int64("wallet/unexpected_address", "Counter for addresses listed by wallet backends which weren't created or imported through the wallet daemon", stats.UnitDimensionless)
+	WalletDeadlineBudgetExceeded        = stats.Int64("wallet/deadline_budget_exceeded", "Counter for sign requests exceeding the deadline budget of the signer", stats.UnitDimensionless)
+	WalletBackendState                  = stats.Int64("wallet/backend_state", "State of a wallet backend: 0 ok, 1 degraded, 2 in maintenance", stats.UnitDimensionless)
+	WalletBackendTrips                  = stats.Int64("wallet/backend_trips", "Counter for wallet backends being marked degraded after repeated failures", stats.UnitDimensionless)
 )
 
 var (
@@ -176,6 +194,72 @@ var (
 		Measure:     VMFlushCopyCount,
 		Aggregation: view.Sum(),
 	}
+	WalletJournalEntriesView = &view.View{
+		Measure:     WalletJournalEntries,
+		Aggregation: view.LastValue(),
+	}
+	WalletJournalPrunedView = &view.View{
+		Measure:     WalletJournalPruned,
+		Aggregation: view.Sum(),
+	}
+	WalletSignQueueDepthView = &view.View{
+		Measure:     WalletSignQueueDepth,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{WalletCaller},
+	}
+	WalletSignView = &view.View{
+		Measure:     WalletSign,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{WalletBackend},
+	}
+	WalletSignFailureView = &view.View{
+		Measure:     WalletSignFailure,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{WalletBackend},
+	}
+	WalletSignDurationView = &view.View{
+		Measure:     WalletSignDuration,
+		Aggregation: defaultMillisecondsDistribution,
+		TagKeys:     []tag.Key{WalletBackend},
+	}
+	WalletWSClientsView = &view.View{
+		Measure:     WalletWSClients,
+		Aggregation: view.Sum(),
+	}
+	WalletSignVerifyFailureView = &view.View{
+		Measure:     WalletSignVerifyFailure,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{WalletBackend},
+	}
+	WalletSignLatencyView = &view.View{
+		Measure:     WalletSignLatency,
+		Aggregation: defaultMillisecondsDistribution,
+		TagKeys:     []tag.Key{WalletPriority},
+	}
+	WalletSignSLOMissedView = &view.View{
+		Measure:     WalletSignSLOMissed,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{WalletPriority},
+	}
+	WalletUnexpectedAddressView = &view.View{
+		Measure:     WalletUnexpectedAddress,
+		Aggregation: view.Sum(),
+	}
+	WalletDeadlineBudgetExceededView = &view.View{
+		Measure:     WalletDeadlineBudgetExceeded,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{WalletSigner},
+	}
+	WalletBackendStateView = &view.View{
+		Measure:     WalletBackendState,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{WalletBackend},
+	}
+	WalletBackendTripsView = &view.View{
+		Measure:     WalletBackendTrips,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{WalletBackend},
+	}
 )
 
 // DefaultViews is an array of OpenCensus views for metric gathering purposes
@@ -204,6 +288,20 @@ var DefaultViews = append([]*view.View{
 	APIRequestDurationView,
 	VMFlushCopyCountView,
 	VMFlushCopyDurationView,
+	WalletJournalEntriesView,
+	WalletJournalPrunedView,
+	WalletSignQueueDepthView,
+	WalletSignView,
+	WalletSignFailureView,
+	WalletSignDurationView,
+	WalletWSClientsView,
+	WalletSignVerifyFailureView,
+	WalletSignLatencyView,
+	WalletSignSLOMissedView,
+	WalletUnexpectedAddressView,
+	WalletDeadlineBudgetExceededView,
+	WalletBackendStateView,
+	WalletBackendTripsView,
 },
 	rpcmetrics.DefaultViews...)
 
@@ -36,6 +36,13 @@ func MetricedWalletAPI(a api.WalletAPI) api.WalletAPI {
 	return &out
 }
 
+func MetricedWalletDaemonAPI(a api.WalletDaemonAPI) api.WalletDaemonAPI {
+	var out apistruct.WalletDaemonStruct
+	proxy(a, &out.Internal)
+	proxy(a, &out.WalletStruct.Internal)
+	return &out
+}
+
 func MetricedGatewayAPI(a api.GatewayAPI) api.GatewayAPI {
 	var out apistruct.GatewayStruct
 	proxy(a, &out.Internal)
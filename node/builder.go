@@ -52,6 +52,7 @@ import (
 	"github.com/filecoin-project/lotus/chain/types"
 	ledgerwallet "github.com/filecoin-project/lotus/chain/wallet/ledger"
 	"github.com/filecoin-project/lotus/chain/wallet/remotewallet"
+	cliutil "github.com/filecoin-project/lotus/cli/util"
 	sectorstorage "github.com/filecoin-project/lotus/extern/sector-storage"
 	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
 	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
@@ -490,7 +491,11 @@ func ConfigFullNode(c interface{}) Option {
 		),
 
 		If(cfg.Wallet.RemoteBackend != "",
-			Override(new(*remotewallet.RemoteWallet), remotewallet.SetupRemoteWallet(cfg.Wallet.RemoteBackend)),
+			Override(new(*remotewallet.RemoteWallet), remotewallet.SetupRemoteWallet(cfg.Wallet.RemoteBackend, cliutil.TLSClientConfig{
+				Cert: cfg.Wallet.RemoteBackendTLSCert,
+				Key:  cfg.Wallet.RemoteBackendTLSKey,
+				CA:   cfg.Wallet.RemoteBackendTLSCA,
+			}, cfg.Wallet.RemoteBackendVerifiers...)),
 		),
 		If(cfg.Wallet.EnableLedger,
 			Override(new(*ledgerwallet.LedgerWallet), ledgerwallet.NewWallet),
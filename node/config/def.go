@@ -122,8 +122,18 @@ type Client struct {
 
 type Wallet struct {
 	RemoteBackend string
-	EnableLedger  bool
-	DisableLocal  bool
+	// Api infos of independent upstreams holding the same keys as
+	// RemoteBackend. When set, WalletHas and WalletList answers from
+	// RemoteBackend must be confirmed by each of them.
+	RemoteBackendVerifiers []string
+	// PEM client certificate and key presented to remote wallets serving the
+	// api over TLS with client certificates required, and a CA bundle their
+	// certificates are verified against on top of the system roots
+	RemoteBackendTLSCert string
+	RemoteBackendTLSKey  string
+	RemoteBackendTLSCA   string
+	EnableLedger         bool
+	DisableLocal         bool
 }
 
 type FeeConfig struct {